@@ -20,11 +20,33 @@ var (
 	cacheMapper = map[string]func(context.Context, string) utils.Cache{
 		"orderBookL2":    utils.NewMBLCache,
 		"orderBookL2_25": utils.NewMBLCache,
+		"orderBook10":    utils.NewOrderBook10Cache,
 		"trade":          utils.NewTradeCache,
 		"instrument":     utils.NewInstrumentCache,
+		"quote":          utils.NewQuoteCache,
+		"quoteBin1m":     newQuoteBinCache("1m"),
+		"quoteBin5m":     newQuoteBinCache("5m"),
+		"quoteBin1h":     newQuoteBinCache("1h"),
+		"quoteBin1d":     newQuoteBinCache("1d"),
+		"tradeBin1m":     newTradeBinCache("1m"),
+		"tradeBin5m":     newTradeBinCache("5m"),
+		"tradeBin1h":     newTradeBinCache("1h"),
+		"tradeBin1d":     newTradeBinCache("1d"),
 	}
 )
 
+func newQuoteBinCache(binSize string) func(context.Context, string) utils.Cache {
+	return func(ctx context.Context, symbol string) utils.Cache {
+		return utils.NewQuoteBinCache(ctx, symbol, binSize)
+	}
+}
+
+func newTradeBinCache(binSize string) func(context.Context, string) utils.Cache {
+	return func(ctx context.Context, symbol string) utils.Cache {
+		return utils.NewTradeBinCache(ctx, symbol, binSize)
+	}
+}
+
 // Client client instance
 type Client interface {
 	Host() string
@@ -61,6 +83,9 @@ type client struct {
 	closeFlag chan struct{}
 	closeOnce sync.Once
 
+	// topicLock guard subscribed topics & response caches,
+	// which are modified in message handler while read by other goroutines
+	topicLock        sync.RWMutex
 	SubscribedTopics map[string]*models.SubscribeResponse
 }
 
@@ -128,6 +153,9 @@ func (c *client) getHeader() http.Header {
 }
 
 func (c *client) isSubscribed(topic string) bool {
+	c.topicLock.RLock()
+	defer c.topicLock.RUnlock()
+
 	rsp, exist := c.SubscribedTopics[topic]
 
 	return exist && rsp != nil && rsp.Success
@@ -175,7 +203,9 @@ func (c *client) Subscribe(topics ...string) {
 			continue
 		}
 
+		c.topicLock.Lock()
 		c.SubscribedTopics[topic] = nil
+		c.topicLock.Unlock()
 
 		subArgs = append(subArgs, c.normalizeTopic(topic))
 	}
@@ -183,23 +213,58 @@ func (c *client) Subscribe(topics ...string) {
 
 // UnSubscribe unsubscribe topic
 func (c *client) UnSubscribe(topics ...string) {
+	var unsubArgs []string
+
+	defer func() {
+		if c.connected && len(unsubArgs) > 0 {
+			unsub := models.OperationRequest{
+				Operation: "unsubscribe",
+				Args:      unsubArgs,
+			}
+
+			c.SendJSONMessage(unsub)
+		}
+	}()
+
 	for _, topic := range topics {
 		if !IsValidTopic(topic) {
 			log.Warn("Invalid topic name: ", topic)
 			continue
 		}
 
+		if !c.connected {
+			// not connected yet, just remove topic from subscribe list
+			c.topicLock.Lock()
+			delete(c.SubscribedTopics, topic)
+			c.topicLock.Unlock()
+			continue
+		}
+
 		if !c.isSubscribed(topic) {
 			log.Warnf("Topic[%s] is not subscribed.", topic)
 			continue
 		}
 
-		c.SubscribedTopics[topic] = nil
+		unsubArgs = append(unsubArgs, c.normalizeTopic(topic))
+	}
+}
+
+func (c *client) removeCache(topic string) {
+	c.topicLock.Lock()
+	cache, exist := c.rspCache[topic]
+	delete(c.rspCache, topic)
+	c.topicLock.Unlock()
+
+	if !exist {
+		return
+	}
 
-		// TODO: real unsubscribe action
+	if err := cache.Stop(); err != nil {
+		log.Warnf("Stop cache for topic[%s] failed: %v", topic, err)
 	}
 }
 
+// createCache create response cache for topic, topicLock must be held
 func (c *client) createCache(topic string) {
 	if _, exist := c.rspCache[topic]; exist {
 		return
@@ -219,6 +284,7 @@ func (c *client) Connect(ctx context.Context) error {
 
 	var subList []string
 
+	c.topicLock.Lock()
 	for topic := range c.SubscribedTopics {
 		if IsPublicTopic(topic) {
 			c.createCache(topic)
@@ -234,6 +300,7 @@ func (c *client) Connect(ctx context.Context) error {
 			subList = append(subList, c.normalizeTopic(topic))
 		}
 	}
+	c.topicLock.Unlock()
 
 	if len(subList) > 0 {
 		remote.RawQuery = "subscribe=" + strings.Join(subList, ",")
@@ -305,15 +372,29 @@ func (c *client) SetSubHandler(fn func(*models.SubscribeResponse)) {
 	}
 }
 
+func (c *client) getCache(topic string) utils.Cache {
+	c.topicLock.RLock()
+	defer c.topicLock.RUnlock()
+
+	return c.rspCache[topic]
+}
+
 func (c *client) GetResponse(topic string) <-chan models.TableResponse {
-	if _, exist := c.SubscribedTopics[topic]; !exist {
+	c.topicLock.RLock()
+	_, subscribed := c.SubscribedTopics[topic]
+	cache, exist := c.rspCache[topic]
+	c.topicLock.RUnlock()
+
+	if !subscribed {
 		log.Infof("Topic[%s] not subscribed.", topic)
 		return nil
 	}
 
-	if cache, exist := c.rspCache[topic]; exist {
-		_, ch := cache.GetDefaultChannel().RetriveData()
-		return ch
+	if exist {
+		if channel := cache.GetDefaultChannel(); channel != nil {
+			_, ch := channel.RetriveData()
+			return ch
+		}
 	}
 
 	return nil
@@ -417,9 +498,26 @@ HEARTBEAT:
 		}
 	}
 
+	if c.cfg.recorder != nil {
+		c.recordMessage(msg)
+	}
+
 	return msg, err
 }
 
+func (c *client) recordMessage(msg []byte) {
+	var frame struct {
+		Table string `json:"table"`
+	}
+
+	// non-table frames also recorded with empty table
+	json.Unmarshal(msg, &frame)
+
+	if err := c.cfg.recorder.Record(time.Now(), frame.Table, c.cfg.Symbol, msg); err != nil {
+		log.Error("Record frame failed: ", err)
+	}
+}
+
 func (c *client) handleInfoMsg(msg []byte) (*models.InfoResponse, error) {
 	var info models.InfoResponse
 
@@ -466,11 +564,13 @@ func (c *client) handlSubMsg(msg []byte) (*models.SubscribeResponse, error) {
 	defer func() {
 		topic := strings.Split(sub.Subscribe, ":")[0]
 
+		c.topicLock.Lock()
 		if sub.Success {
 			c.SubscribedTopics[topic] = &sub
 		} else {
 			delete(c.SubscribedTopics, topic)
 		}
+		c.topicLock.Unlock()
 
 		if c.subHandler != nil {
 			c.subHandler(&sub)
@@ -482,6 +582,30 @@ func (c *client) handlSubMsg(msg []byte) (*models.SubscribeResponse, error) {
 	return &sub, nil
 }
 
+func (c *client) handleUnsubMsg(msg []byte) (*models.UnsubscribeResponse, error) {
+	var unsub models.UnsubscribeResponse
+
+	if err := json.Unmarshal(msg, &unsub); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		topic := strings.Split(unsub.Unsubscribe, ":")[0]
+
+		if unsub.Success {
+			c.topicLock.Lock()
+			delete(c.SubscribedTopics, topic)
+			c.topicLock.Unlock()
+
+			c.removeCache(topic)
+		}
+
+		log.Info("Unsubscribe: ", unsub.String())
+	}()
+
+	return &unsub, nil
+}
+
 func (c *client) handleInsMsg(msg []byte) (*models.InstrumentResponse, error) {
 	var insRsp models.InstrumentResponse
 
@@ -490,11 +614,11 @@ func (c *client) handleInsMsg(msg []byte) (*models.InstrumentResponse, error) {
 	}
 
 	defer func() {
-		if insCache, exist := c.rspCache[insRsp.Table]; exist && insCache != nil {
+		if insCache := c.getCache(insRsp.Table); insCache != nil {
 			if !c.cfg.disableCache {
 				insCache.Append(utils.NewCacheInput(&insRsp))
-			} else {
-				insCache.GetDefaultChannel().PublishData(&insRsp)
+			} else if ch := insCache.GetDefaultChannel(); ch != nil {
+				ch.PublishData(&insRsp)
 			}
 		}
 	}()
@@ -510,11 +634,11 @@ func (c *client) handleTdMsg(msg []byte) (*models.TradeResponse, error) {
 	}
 
 	defer func() {
-		if tdCache, exist := c.rspCache[tdRsp.Table]; exist && tdCache != nil {
+		if tdCache := c.getCache(tdRsp.Table); tdCache != nil {
 			if !c.cfg.disableCache {
 				tdCache.Append(utils.NewCacheInput(&tdRsp))
-			} else {
-				tdCache.GetDefaultChannel().PublishData(&tdRsp)
+			} else if ch := tdCache.GetDefaultChannel(); ch != nil {
+				ch.PublishData(&tdRsp)
 			}
 		}
 	}()
@@ -530,11 +654,11 @@ func (c *client) handleMblMsg(msg []byte) (*models.MBLResponse, error) {
 	}
 
 	defer func() {
-		if mblCache, exist := c.rspCache[mblRsp.Table]; exist && mblCache != nil {
+		if mblCache := c.getCache(mblRsp.Table); mblCache != nil {
 			if !c.cfg.disableCache {
 				mblCache.Append(utils.NewCacheInput(&mblRsp))
-			} else {
-				mblCache.GetDefaultChannel().PublishData(&mblRsp)
+			} else if ch := mblCache.GetDefaultChannel(); ch != nil {
+				ch.PublishData(&mblRsp)
 			}
 		}
 	}()
@@ -542,6 +666,66 @@ func (c *client) handleMblMsg(msg []byte) (*models.MBLResponse, error) {
 	return &mblRsp, nil
 }
 
+func (c *client) handleBook10Msg(msg []byte) (*models.OrderBook10Response, error) {
+	var bookRsp models.OrderBook10Response
+
+	if err := json.Unmarshal(msg, &bookRsp); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if bookCache := c.getCache(bookRsp.Table); bookCache != nil {
+			if !c.cfg.disableCache {
+				bookCache.Append(utils.NewCacheInput(&bookRsp))
+			} else if ch := bookCache.GetDefaultChannel(); ch != nil {
+				ch.PublishData(&bookRsp)
+			}
+		}
+	}()
+
+	return &bookRsp, nil
+}
+
+func (c *client) handleQuoteMsg(msg []byte) (*models.QuoteResponse, error) {
+	var quoteRsp models.QuoteResponse
+
+	if err := json.Unmarshal(msg, &quoteRsp); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if quoteCache := c.getCache(quoteRsp.Table); quoteCache != nil {
+			if !c.cfg.disableCache {
+				quoteCache.Append(utils.NewCacheInput(&quoteRsp))
+			} else if ch := quoteCache.GetDefaultChannel(); ch != nil {
+				ch.PublishData(&quoteRsp)
+			}
+		}
+	}()
+
+	return &quoteRsp, nil
+}
+
+func (c *client) handleTradeBinMsg(msg []byte) (*models.TradeBinResponse, error) {
+	var binRsp models.TradeBinResponse
+
+	if err := json.Unmarshal(msg, &binRsp); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if binCache := c.getCache(binRsp.Table); binCache != nil {
+			if !c.cfg.disableCache {
+				binCache.Append(utils.NewCacheInput(&binRsp))
+			} else if ch := binCache.GetDefaultChannel(); ch != nil {
+				ch.PublishData(&binRsp)
+			}
+		}
+	}()
+
+	return &binRsp, nil
+}
+
 func (c *client) handleErrMsg(msg []byte) (*models.ErrResponse, error) {
 	var errRsp models.ErrResponse
 
@@ -584,6 +768,12 @@ func (c *client) messageHandler() {
 					log.Errorf("Fail to parse info msg: %s, %s", err.Error(), string(msg))
 				}
 
+				continue
+			case models.UnsubPattern.Match(msg):
+				if rsp, err = c.handleUnsubMsg(msg); err != nil {
+					log.Errorf("Fail to parse unsubscribe response: %s, %s", err.Error(), string(msg))
+				}
+
 				continue
 			case models.SubPattern.Match(msg):
 				if rsp, err = c.handlSubMsg(msg); err != nil {
@@ -607,6 +797,11 @@ func (c *client) messageHandler() {
 					log.Errorf("Fail to parse instrument response: %s, %s", err.Error(), string(msg))
 					continue
 				}
+			case models.OrderBook10Pattern.Match(msg):
+				if rsp, err = c.handleBook10Msg(msg); err != nil {
+					log.Error("Fail to parse orderBook10 response:", err, string(msg))
+					continue
+				}
 			case models.MBLPattern.Match(msg):
 				if rsp, err = c.handleMblMsg(msg); err != nil {
 					log.Error("Fail to parse MBL response:", err, string(msg))
@@ -617,6 +812,16 @@ func (c *client) messageHandler() {
 					log.Error("Fail to parse trade response:", err, string(msg))
 					continue
 				}
+			case models.TradeBinPattern.Match(msg):
+				if rsp, err = c.handleTradeBinMsg(msg); err != nil {
+					log.Error("Fail to parse trade bin response:", err, string(msg))
+					continue
+				}
+			case models.QuotePattern.Match(msg):
+				if rsp, err = c.handleQuoteMsg(msg); err != nil {
+					log.Error("Fail to parse quote response:", err, string(msg))
+					continue
+				}
 			default:
 				log.Error("Unkonw response type:", string(msg))
 				continue
@@ -18,12 +18,18 @@ var (
 	// ContextAPIKey takes an APIKeyAuth as authentication for websocket
 	ContextAPIKey = contextKey("apikey")
 
-	symbolSubs = []string{"instrument", "orderBookL2", "trade", "order"}
+	symbolSubs = []string{
+		"instrument", "orderBookL2", "orderBook10", "trade", "order",
+		"quote", "quoteBin1m", "quoteBin5m", "quoteBin1h", "quoteBin1d",
+		"tradeBin1m", "tradeBin5m", "tradeBin1h", "tradeBin1d"}
 
 	// PublicTopics public topics for subscribe without authentication
-	PublicTopics = []string{"instrument", "orderBookL2", "orderBookL2_25", "trade", "quote"}
+	PublicTopics = []string{
+		"instrument", "orderBookL2", "orderBookL2_25", "orderBook10", "trade",
+		"quote", "quoteBin1m", "quoteBin5m", "quoteBin1h", "quoteBin1d",
+		"tradeBin1m", "tradeBin5m", "tradeBin1h", "tradeBin1d"}
 	// PrivateTopics private topics for subscribe must authenticated
-	PrivateTopics = []string{"order", "execution", "position"}
+	PrivateTopics = []string{"order", "execution", "position", "margin"}
 )
 
 // APIKeyAuth structure for api auth
@@ -44,6 +50,7 @@ type Config struct {
 	ReversHeartbeat    bool
 	HeartbeatFailCount int
 	disableCache       bool
+	recorder           *Recorder
 }
 
 // ChangeHost change configuration's host
@@ -85,6 +92,11 @@ func (c *Config) DisableCache() {
 	c.disableCache = true
 }
 
+// SetRecorder record all raw frames received by client
+func (c *Config) SetRecorder(recorder *Recorder) {
+	c.recorder = recorder
+}
+
 // NewConfig to make a default new config
 func NewConfig() *Config {
 	cfg := Config{
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const recordTimeFormat = "20060102150405"
+
+// Record one raw frame in recording file
+type Record struct {
+	// Timestamp frame receive time
+	Timestamp time.Time `json:"ts"`
+	// Table table name of frame, empty for non-table frames
+	Table string `json:"table"`
+	// Symbol symbol of client which received the frame
+	Symbol string `json:"symbol,omitempty"`
+	// Frame raw frame received from websocket
+	Frame json.RawMessage `json:"frame"`
+}
+
+// Recorder write raw frames to JSONL file, one frame per line,
+// recorder can be shared by multiple clients.
+type Recorder struct {
+	path   string
+	lock   sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	closed bool
+}
+
+// Path recording file path
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Record write frame with receive time & table, frames recorded after closed are discarded
+func (r *Recorder) Record(ts time.Time, table, symbol string, frame []byte) error {
+	data, err := json.Marshal(&Record{
+		Timestamp: ts,
+		Table:     table,
+		Symbol:    symbol,
+		Frame:     json.RawMessage(frame),
+	})
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.closed {
+		return nil
+	}
+
+	if _, err = r.writer.Write(data); err != nil {
+		return err
+	}
+
+	return r.writer.WriteByte('\n')
+}
+
+// Flush flush buffered frames to file
+func (r *Recorder) Flush() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.closed {
+		return os.ErrClosed
+	}
+
+	return r.writer.Flush()
+}
+
+// Close flush buffered frames & close recording file
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+
+	if err := r.writer.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+
+	return r.file.Close()
+}
+
+// NewRecorder create a recorder writing to a new timestamped file in dir
+func NewRecorder(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, "record_"+time.Now().Format(recordTimeFormat)+".jsonl")
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	recorder := Recorder{
+		path:   path,
+		file:   file,
+		writer: bufio.NewWriter(file),
+	}
+
+	return &recorder, nil
+}
@@ -21,6 +21,16 @@ var (
 		"instrument":     new(ngerest.Instrument),
 		"orderBookL2":    new(ngerest.OrderBookL2),
 		"orderBookL2_25": new(ngerest.OrderBookL2),
+		"orderBook10":    new(models.OrderBook10),
+		"quote":          new(ngerest.Quote),
+		"quoteBin1m":     new(ngerest.Quote),
+		"quoteBin5m":     new(ngerest.Quote),
+		"quoteBin1h":     new(ngerest.Quote),
+		"quoteBin1d":     new(ngerest.Quote),
+		"tradeBin1m":     new(ngerest.TradeBin),
+		"tradeBin5m":     new(ngerest.TradeBin),
+		"tradeBin1h":     new(ngerest.TradeBin),
+		"tradeBin1d":     new(ngerest.TradeBin),
 		"order":          new(ngerest.Order),
 		"margin":         new(ngerest.Margin),
 		"position":       new(ngerest.Position),
@@ -63,6 +63,8 @@ var (
 	apiKey    string
 	apiSecret string
 
+	recordDir string
+
 	cacheMap = make(map[string]utils.Cache)
 )
 
@@ -182,6 +184,8 @@ func init() {
 	flag.StringVar(&apiKey, "key", "", "API Key for authentication request.")
 	flag.StringVar(&apiSecret, "secret", "", "API Secret for authentication request.")
 
+	flag.StringVar(&recordDir, "record", "", "Directory for recording raw frames to timestamped JSONL file.")
+
 	// log.SetFlags(log.Lmicroseconds | log.Ldate)
 }
 
@@ -246,10 +250,22 @@ func main() {
 		log.Fatal(err)
 	}
 
+	var recorder *client.Recorder
+	if recordDir != "" {
+		var err error
+
+		if recorder, err = client.NewRecorder(recordDir); err != nil {
+			log.Fatal(err)
+		}
+		defer recorder.Close()
+
+		log.Info("Recording frames to: ", recorder.Path())
+	}
+
 	roundCount := 1
 	failCount := 0
 
-	sigChan := make(chan os.Signal)
+	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, os.Kill)
 
 	running := true
@@ -290,6 +306,7 @@ func main() {
 		cfg.HeartbeatInterval = hbInterval
 		cfg.HeartbeatFailCount = hbFailCount
 		cfg.Symbol = symbol
+		cfg.SetRecorder(recorder)
 
 		ins := client.NewClient(cfg)
 		ins.Subscribe(topics...)
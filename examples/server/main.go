@@ -2,17 +2,206 @@ package main
 
 import (
 	"context"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/frozenpine/wstester/server"
 	"github.com/frozenpine/wstester/utils/log"
+	flag "github.com/spf13/pflag"
 )
 
+// preScanConfig scan config file path from args before flags bound,
+// so that settings in config file can be overridden by flags.
+func preScanConfig(args []string) string {
+	var path string
+
+	scanner := flag.NewFlagSet("config", flag.ContinueOnError)
+	scanner.ParseErrorsWhitelist.UnknownFlags = true
+	scanner.Usage = func() {}
+	scanner.SetOutput(nopWriter{})
+	scanner.StringVarP(&path, "config", "c", "", "")
+
+	// errors & help flag will be handled in formal parse
+	scanner.Parse(args)
+
+	return path
+}
+
+type nopWriter struct{}
+
+func (w nopWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// bindFlags bind flags to config and local targets of config file & debug level,
+// so flags can be parsed again on reload without touching shared state.
+func bindFlags(flags *flag.FlagSet, cfg *server.Config, configFile *string, dbgLevel *int) {
+	flags.StringVarP(configFile, "config", "c", *configFile, "Config file in toml format, flags will override settings in file.")
+	flags.CountVarP(dbgLevel, "verbose", "v", "Debug level, turn on for detail info.")
+
+	flags.IPVarP(&cfg.Listen, "listen", "l", cfg.Listen, "Listen address.")
+	flags.IntVarP(&cfg.Port, "port", "p", cfg.Port, "Listen port.")
+	flags.StringVar(&cfg.BaseURI, "uri", cfg.BaseURI, "URI for realtime websocket endpoint.")
+	flags.StringVar(&cfg.SignatureURI, "signature-uri", cfg.SignatureURI, "URI for api signature verify.")
+	flags.StringVar(&cfg.AdminKey, "admin-key", cfg.AdminKey, "Key in admin-key header for admin api, empty means admin api only allowed from loopback.")
+
+	flags.StringVar(&cfg.TLS.Cert, "tls-cert", cfg.TLS.Cert, "TLS certificate file in PEM format for wss listener.")
+	flags.StringVar(&cfg.TLS.Key, "tls-key", cfg.TLS.Key, "TLS private key file in PEM format for wss listener.")
+	flags.BoolVar(&cfg.TLS.SelfSigned, "tls-self-signed", cfg.TLS.SelfSigned, "Generate self-signed certificate for wss listener if no certificate specified.")
+	flags.StringVar(&cfg.TLS.SelfSignedOut, "tls-self-signed-out", cfg.TLS.SelfSignedOut, "File to write generated self-signed certificate in PEM format for clients to trust.")
+	flags.StringVar(&cfg.TLS.ClientCA, "tls-client-ca", cfg.TLS.ClientCA, "CA file in PEM format to verify client certificates.")
+
+	flags.StringVar(&cfg.WelcomMsg, "welcome", cfg.WelcomMsg, "Welcome message for new connection.")
+	flags.StringVar(&cfg.DocsURI, "docs", cfg.DocsURI, "Docs url in welcome message.")
+	flags.StringVar(&cfg.FrontID, "front-id", cfg.FrontID, "Front ID for session id's namespace.")
+
+	flags.IntVar(&cfg.ConnectLimit, "connect-limit", cfg.ConnectLimit, "Connection limit for server, 0 means unlimited.")
+	flags.IntVar(&cfg.ConnectLimitPerIP, "connect-limit-ip", cfg.ConnectLimitPerIP, "Connection limit for each client ip, 0 means unlimited.")
+	flags.IntVar(&cfg.ConnectLimitPerKey, "connect-limit-key", cfg.ConnectLimitPerKey, "Connection limit for each api key, 0 means unlimited.")
+	flags.StringSliceVar(&cfg.TrustedProxies, "trusted-proxies", cfg.TrustedProxies, "Proxy ips or cidrs whose X-Forwarded-For header is trusted for client ip.")
+
+	flags.Float64Var(&cfg.RateLimit, "rate-limit", cfg.RateLimit, "Operation rate limit per second for each session, 0 means unlimited.")
+	flags.IntVar(&cfg.RateBurst, "rate-burst", cfg.RateBurst, "Operation burst size for each session.")
+	flags.Float64Var(&cfg.KeyRateLimit, "key-rate-limit", cfg.KeyRateLimit, "Operation rate limit per second for each api key, 0 means unlimited.")
+	flags.IntVar(&cfg.KeyRateBurst, "key-rate-burst", cfg.KeyRateBurst, "Operation burst size for each api key.")
+	flags.IntVar(&cfg.RateViolationLimit, "rate-violation", cfg.RateViolationLimit, "Close session after rate limited operations exceed this count, 0 means never.")
+
+	flags.IntVar(&cfg.SendQueue.Size, "queue-size", cfg.SendQueue.Size, "Max data messages queued in each session.")
+	flags.StringVar(&cfg.SendQueue.Policy, "queue-policy", cfg.SendQueue.Policy, "Slow consumer policy when send queue full: drop_oldest, conflate or disconnect.")
+	flags.StringToStringVar(&cfg.SendQueue.TopicPolicies, "queue-topic-policy", cfg.SendQueue.TopicPolicies, "Slow consumer policy for each table, e.g. orderBook10=conflate.")
+
+	flags.IntVar(&cfg.ConflateInterval, "conflate-interval", cfg.ConflateInterval, "Publish interval in milliseconds for orderBookL2 channels, changes in interval are merged on price level, 0 means publish immediately.")
+	flags.BoolVar(&cfg.PreparedMessage, "prepared-message", cfg.PreparedMessage, "Send table data with websocket prepared message, compressed frames are shared by sessions.")
+
+	flags.StringSliceVar(&cfg.Symbols, "symbols", cfg.Symbols, "Symbols for public flow.")
+	flags.StringVar(&cfg.MockMode, "mock", cfg.MockMode, "Public flow mock mode: upstream, trade, match, generate, replay or none.")
+	flags.StringVar(&cfg.Upstream.URL, "upstream", cfg.Upstream.URL, "Upstream url for upstream mock mode, empty means default host.")
+	flags.StringSliceVar(&cfg.Upstream.Symbols, "upstream-symbols", cfg.Upstream.Symbols, "Symbols cascaded from upstream, empty means all symbols.")
+	flags.StringSliceVar(&cfg.Upstream.Topics, "upstream-topics", cfg.Upstream.Topics, "Topics subscribed from upstream.")
+	flags.StringVar(&cfg.Upstream.APIKey, "upstream-key", cfg.Upstream.APIKey, "API key for upstream authentication.")
+	flags.StringVar(&cfg.Upstream.APISecret, "upstream-secret", cfg.Upstream.APISecret, "API secret for upstream authentication.")
+	flags.StringVar(&cfg.Upstream.AuthURI, "upstream-auth-uri", cfg.Upstream.AuthURI, "URI signed in upstream authentication.")
+	flags.Float64Var(&cfg.Upstream.ReconnectDelay, "upstream-delay", cfg.Upstream.ReconnectDelay, "Initial upstream reconnect delay in seconds.")
+	flags.Float64Var(&cfg.Upstream.MaxReconnectDelay, "upstream-max-delay", cfg.Upstream.MaxReconnectDelay, "Max upstream reconnect delay in seconds.")
+	flags.Float64Var(&cfg.Upstream.BackoffFactor, "upstream-backoff", cfg.Upstream.BackoffFactor, "Upstream reconnect delay multiplier for each continuous failure.")
+	flags.StringVar(&cfg.Record, "record", cfg.Record, "Directory for recording upstream frames in upstream mock mode, empty means no recording.")
+
+	flags.StringVar(&cfg.Replay.File, "replay-file", cfg.Replay.File, "Recording file for replay mock mode.")
+	flags.Float64Var(&cfg.Replay.Speed, "replay-speed", cfg.Replay.Speed, "Replay speed multiple to recording time, 0 means as fast as possible.")
+
+	flags.Int64Var(&cfg.Generator.Seed, "gen-seed", cfg.Generator.Seed, "Random seed for generate mock mode, 0 means seeded by current time.")
+	flags.Float64Var(&cfg.Generator.MidPrice, "gen-mid-price", cfg.Generator.MidPrice, "Initial mid price for generate mock mode.")
+	flags.Float64Var(&cfg.Generator.TickSize, "gen-tick-size", cfg.Generator.TickSize, "Price tick size for match & generate mock mode.")
+	flags.Float64Var(&cfg.Generator.Volatility, "gen-volatility", cfg.Generator.Volatility, "Mid price volatility per second for generate mock mode.")
+	flags.IntVar(&cfg.Generator.Depth, "gen-depth", cfg.Generator.Depth, "Price levels on each side for generate mock mode.")
+	flags.Float64Var(&cfg.Generator.OrderRate, "gen-order-rate", cfg.Generator.OrderRate, "Limit orders per second for generate mock mode.")
+	flags.Float64Var(&cfg.Generator.TradeRate, "gen-trade-rate", cfg.Generator.TradeRate, "Market orders per second for generate mock mode.")
+	flags.IntVar(&cfg.Generator.MaxSize, "gen-max-size", cfg.Generator.MaxSize, "Max order quantity for generate mock mode.")
+
+	flags.Float64Var(&cfg.Chaos.Drop, "chaos-drop", cfg.Chaos.Drop, "Probability to drop table data message.")
+	flags.Float64Var(&cfg.Chaos.Duplicate, "chaos-duplicate", cfg.Chaos.Duplicate, "Probability to send table data message twice.")
+	flags.Float64Var(&cfg.Chaos.Delay, "chaos-delay", cfg.Chaos.Delay, "Probability to delay table data message.")
+	flags.IntVar(&cfg.Chaos.DelayMax, "chaos-delay-max", cfg.Chaos.DelayMax, "Max delay in milliseconds for delayed message.")
+	flags.Float64Var(&cfg.Chaos.Reorder, "chaos-reorder", cfg.Chaos.Reorder, "Probability to send table data message after the next one.")
+	flags.Float64Var(&cfg.Chaos.Malformed, "chaos-malformed", cfg.Chaos.Malformed, "Probability to send table data message as malformed json.")
+	flags.Float64Var(&cfg.Chaos.SkipPartial, "chaos-skip-partial", cfg.Chaos.SkipPartial, "Probability to skip partial of subscribed topic.")
+	flags.Float64Var(&cfg.Chaos.StopHeartbeat, "chaos-stop-heartbeat", cfg.Chaos.StopHeartbeat, "Probability on each heartbeat to stop heartbeat replies for the rest of session.")
+	flags.Float64Var(&cfg.Chaos.Close, "chaos-close", cfg.Chaos.Close, "Probability to close session with abnormal code on table data message.")
+	flags.IntSliceVar(&cfg.Chaos.CloseCodes, "chaos-close-codes", cfg.Chaos.CloseCodes, "Close codes for chaos close, 1006 means closing without close frame.")
+	flags.IntVar(&cfg.Chaos.Start, "chaos-start", cfg.Chaos.Start, "Seconds after session connected before faults injected.")
+	flags.IntVar(&cfg.Chaos.Duration, "chaos-duration", cfg.Chaos.Duration, "Seconds of fault window, 0 means forever.")
+	flags.IntVar(&cfg.Chaos.Period, "chaos-period", cfg.Chaos.Period, "Seconds to repeat fault window, 0 means no repeat.")
+	flags.Int64Var(&cfg.Chaos.Seed, "chaos-seed", cfg.Chaos.Seed, "Random seed for chaos mode, 0 means seeded by current time.")
+
+	flags.StringVar(&cfg.KeyStore, "key-store", cfg.KeyStore, "API key store file in json format.")
+
+	flags.StringSliceVar(&cfg.Notify.Brokers, "kafka-brokers", cfg.Notify.Brokers, "Kafka brokers for private flow, empty means private flow disabled.")
+	flags.StringVar(&cfg.Notify.Topic, "kafka-topic", cfg.Notify.Topic, "Kafka topic for notify.")
+	flags.StringVar(&cfg.Notify.Version, "kafka-version", cfg.Notify.Version, "Kafka protocol version.")
+	flags.StringVar(&cfg.Notify.Offset, "kafka-offset", cfg.Notify.Offset, "Kafka initial offset: newest or oldest.")
+
+	flags.IntVar(&cfg.HeartbeatInterval, "heartbeat", cfg.HeartbeatInterval, "Heartbeat interval in seconds.")
+	flags.BoolVar(&cfg.ReversHeartbeat, "reverse-heartbeat", cfg.ReversHeartbeat, "Wether server send heartbeat ping to client.")
+	flags.IntVar(&cfg.HeartbeatFailCount, "fail", cfg.HeartbeatFailCount, "Heartbeat fail count.")
+}
+
+// parseConfig parse config from config file & flags in args, debug level is returned with config.
+func parseConfig(args []string, handling flag.ErrorHandling) (*server.Config, int, error) {
+	var (
+		cfg        = server.NewConfig()
+		configFile = preScanConfig(args)
+		dbgLevel   int
+	)
+
+	if configFile != "" {
+		if err := cfg.LoadFile(configFile); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	flags := flag.NewFlagSet(os.Args[0], handling)
+	bindFlags(flags, cfg, &configFile, &dbgLevel)
+
+	if err := flags.Parse(args); err != nil {
+		return nil, 0, err
+	}
+
+	return cfg, dbgLevel, cfg.Validate()
+}
+
 func main() {
-	log.SetLogLevel(log.DebugLevel)
+	cfg, dbgLevel, err := parseConfig(os.Args[1:], flag.ExitOnError)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	cfg := server.NewConfig()
+	if dbgLevel > 0 {
+		log.SetLogLevel(log.TraceLevel)
+	} else {
+		log.SetLogLevel(log.DebugLevel)
+	}
 
 	svr := server.NewServer(nil, cfg)
 
-	svr.RunForever(context.Background())
+	loader := func() (*server.Config, error) {
+		// debug level is applied on startup only
+		cfg, _, err := parseConfig(os.Args[1:], flag.ContinueOnError)
+
+		return cfg, err
+	}
+	svr.SetConfigLoader(loader)
+
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGHUP)
+
+		for range sigChan {
+			log.Info("SIGHUP received, reloading config.")
+
+			newCfg, err := loader()
+			if err != nil {
+				log.Error("Reload config failed: ", err)
+				continue
+			}
+
+			if _, err = svr.ReloadCfg(newCfg); err != nil {
+				log.Error("Reload config failed: ", err)
+			}
+		}
+	}()
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+		<-sigChan
+		cancelFn()
+	}()
+
+	if err = svr.RunForever(ctx); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -3,7 +3,8 @@ module github.com/frozenpine/wstester
 go 1.12
 
 require (
-	github.com/Shopify/sarama v1.23.1 // indirect
+	github.com/BurntSushi/toml v0.3.1
+	github.com/Shopify/sarama v1.23.1
 	github.com/ahmetb/go-linq v3.0.0+incompatible
 	github.com/ahmetb/go-linq/v3 v3.1.0 // indirect
 	github.com/antihax/optional v1.0.0 // indirect
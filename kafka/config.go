@@ -1,12 +1,73 @@
 package kafka
 
+import (
+	"fmt"
+
+	"github.com/Shopify/sarama"
+)
+
+const (
+	defaultTopic    = "NOTIFY"
+	defaultClientID = "wstester"
+	defaultOffset   = "newest"
+)
+
 // Config kafka config
 type Config struct {
+	// Brokers kafka broker addrs, empty means kafka disabled
+	Brokers []string `toml:"brokers"`
+	// Topic topic name to consume
+	Topic string `toml:"topic"`
+	// ClientID client id reported to kafka brokers
+	ClientID string `toml:"client_id"`
+	// Version kafka protocol version, empty means sarama's default version
+	Version string `toml:"version"`
+	// Offset initial offset for partitions, "newest" or "oldest"
+	Offset string `toml:"offset"`
+}
+
+// IsEnabled wether kafka brokers configured
+func (c *Config) IsEnabled() bool {
+	return len(c.Brokers) > 0
+}
+
+func (c *Config) saramaConfig() (*sarama.Config, error) {
+	cfg := sarama.NewConfig()
+
+	if c.ClientID != "" {
+		cfg.ClientID = c.ClientID
+	}
+
+	if c.Version != "" {
+		version, err := sarama.ParseKafkaVersion(c.Version)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.Version = version
+	}
+
+	switch c.Offset {
+	case "", "newest":
+		cfg.Consumer.Offsets.Initial = sarama.OffsetNewest
+	case "oldest":
+		cfg.Consumer.Offsets.Initial = sarama.OffsetOldest
+	default:
+		return nil, fmt.Errorf("invalid initial offset: %s", c.Offset)
+	}
+
+	cfg.Consumer.Return.Errors = true
+
+	return cfg, cfg.Validate()
 }
 
 // NewConfig create a new kafka config
 func NewConfig() *Config {
-	cfg := Config{}
+	cfg := Config{
+		Topic:    defaultTopic,
+		ClientID: defaultClientID,
+		Offset:   defaultOffset,
+	}
 
 	return &cfg
 }
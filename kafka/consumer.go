@@ -1 +1,160 @@
 package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/frozenpine/wstester/utils/log"
+)
+
+// Consumer consumer for all partitions in kafka topic
+type Consumer interface {
+	// Messages get message value chan merged from all partitions,
+	// chan will be closed after consumer closed.
+	Messages() <-chan []byte
+	// Close close all partition consumers
+	Close() error
+}
+
+type consumer struct {
+	cfg        *Config
+	client     sarama.Consumer
+	partitions []sarama.PartitionConsumer
+	messages   chan []byte
+
+	ctx       context.Context
+	cancelFn  context.CancelFunc
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func (c *consumer) Messages() <-chan []byte {
+	return c.messages
+}
+
+func (c *consumer) Close() (err error) {
+	c.closeOnce.Do(func() {
+		c.cancelFn()
+
+		for _, partition := range c.partitions {
+			partition.AsyncClose()
+		}
+
+		c.wg.Wait()
+		close(c.messages)
+
+		err = c.client.Close()
+	})
+
+	return
+}
+
+func (c *consumer) consumePartition(partition sarama.PartitionConsumer) {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case err, ok := <-partition.Errors():
+			if !ok {
+				return
+			}
+
+			log.Errorf("Consume topic[%s] partition[%d] failed: %v", err.Topic, err.Partition, err.Err)
+		case msg, ok := <-partition.Messages():
+			if !ok {
+				return
+			}
+
+			select {
+			case c.messages <- msg.Value:
+			case <-c.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (c *consumer) start() error {
+	partitions, err := c.client.Partitions(c.cfg.Topic)
+	if err != nil {
+		return err
+	}
+
+	if len(partitions) < 1 {
+		return errors.New("no partition found in topic: " + c.cfg.Topic)
+	}
+
+	saramaCfg, _ := c.cfg.saramaConfig()
+
+	for _, partition := range partitions {
+		pc, err := c.client.ConsumePartition(
+			c.cfg.Topic, partition, saramaCfg.Consumer.Offsets.Initial)
+
+		if err != nil {
+			for _, started := range c.partitions {
+				started.AsyncClose()
+			}
+
+			return err
+		}
+
+		c.partitions = append(c.partitions, pc)
+	}
+
+	for _, pc := range c.partitions {
+		c.wg.Add(1)
+
+		go c.consumePartition(pc)
+	}
+
+	go func() {
+		<-c.ctx.Done()
+
+		c.Close()
+	}()
+
+	log.Infof("Kafka consumer started on topic[%s] with %d partitions.", c.cfg.Topic, len(c.partitions))
+
+	return nil
+}
+
+// NewConsumer create a consumer for all partitions in configured topic,
+// consumer will be closed when ctx done.
+func NewConsumer(ctx context.Context, cfg *Config) (Consumer, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if cfg == nil || !cfg.IsEnabled() {
+		return nil, errors.New("no kafka broker configured")
+	}
+
+	saramaCfg, err := cfg.saramaConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := sarama.NewConsumer(cfg.Brokers, saramaCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	c := consumer{
+		cfg:      cfg,
+		client:   client,
+		messages: make(chan []byte, 1000),
+	}
+	c.ctx, c.cancelFn = context.WithCancel(ctx)
+
+	if err = c.start(); err != nil {
+		client.Close()
+
+		return nil, err
+	}
+
+	return &c, nil
+}
@@ -0,0 +1,73 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestConsumer(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	cfg := NewConfig()
+	cfg.Brokers = []string{broker.Addr()}
+	cfg.Offset = "oldest"
+
+	notifies := []string{
+		`{"type":"order","clientId":"1","accountId":"1","content":{"table":"order","action":"insert"}}`,
+		`{"type":"execution","clientId":"1","accountId":"1","content":{"table":"execution","action":"insert"}}`,
+	}
+
+	fetchRsp := sarama.NewMockFetchResponse(t, 1)
+	for offset, notify := range notifies {
+		fetchRsp.SetMessage(cfg.Topic, 0, int64(offset), sarama.StringEncoder(notify))
+	}
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader(cfg.Topic, 0, broker.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset(cfg.Topic, 0, sarama.OffsetOldest, 0).
+			SetOffset(cfg.Topic, 0, sarama.OffsetNewest, int64(len(notifies))),
+		"FetchRequest": fetchRsp,
+	})
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+
+	consumer, err := NewConsumer(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, notify := range notifies {
+		select {
+		case msg := <-consumer.Messages():
+			if string(msg) != notify {
+				t.Fatalf("message miss-match, expect: %s, got: %s", notify, string(msg))
+			}
+		case <-time.After(time.Second * 3):
+			t.Fatal("consume message timeout")
+		}
+	}
+
+	cancelFn()
+
+	select {
+	case _, ok := <-consumer.Messages():
+		for ok {
+			_, ok = <-consumer.Messages()
+		}
+	case <-time.After(time.Second * 3):
+		t.Fatal("consumer close timeout")
+	}
+}
+
+func TestNoBroker(t *testing.T) {
+	if _, err := NewConsumer(nil, NewConfig()); err == nil {
+		t.Fatal("consumer created without broker")
+	}
+}
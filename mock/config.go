@@ -0,0 +1,216 @@
+package mock
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/frozenpine/wstester/client"
+)
+
+const (
+	defaultMidPrice   = 9000.0
+	defaultTickSize   = 0.5
+	defaultVolatility = 0.0005
+	defaultDepth      = 25
+	defaultOrderRate  = 20.0
+	defaultTradeRate  = 2.0
+	defaultMaxSize    = 1000
+)
+
+const defaultReplaySpeed = 1.0
+
+const (
+	defaultReconnectDelay    = 3.0
+	defaultMaxReconnectDelay = 60.0
+	defaultBackoffFactor     = 2.0
+	defaultUpstreamAuthURI   = "/api/v1/signature"
+)
+
+var (
+	// UpstreamTopics topics can be cascaded from upstream
+	UpstreamTopics = []string{"orderBookL2", "trade", "instrument"}
+)
+
+// GeneratorConfig synthetic market generator config
+type GeneratorConfig struct {
+	// Seed random seed, 0 means seeded by current time
+	Seed int64 `toml:"seed"`
+	// MidPrice initial mid price for random walk
+	MidPrice float64 `toml:"mid_price"`
+	// TickSize price tick size for matching engine
+	TickSize float64 `toml:"tick_size"`
+	// Volatility standard deviation of mid price's relative change per second
+	Volatility float64 `toml:"volatility"`
+	// Depth price levels around mid price on each side
+	Depth int `toml:"depth"`
+	// OrderRate limit order arrivals per second
+	OrderRate float64 `toml:"order_rate"`
+	// TradeRate market order arrivals per second
+	TradeRate float64 `toml:"trade_rate"`
+	// MaxSize max quantity for generated orders
+	MaxSize int `toml:"max_size"`
+}
+
+// Validate check generator config values
+func (c *GeneratorConfig) Validate() error {
+	if c.MidPrice <= 0 || c.TickSize <= 0 {
+		return errors.New("generator mid price & tick size must be positive")
+	}
+
+	if c.Volatility < 0 || c.OrderRate < 0 || c.TradeRate < 0 {
+		return errors.New("generator volatility & rates can not be negative")
+	}
+
+	if c.Depth <= 0 || c.MaxSize <= 0 {
+		return errors.New("generator depth & max size must be positive")
+	}
+
+	return nil
+}
+
+// NewGeneratorConfig create a new generator config
+func NewGeneratorConfig() *GeneratorConfig {
+	cfg := GeneratorConfig{
+		MidPrice:   defaultMidPrice,
+		TickSize:   defaultTickSize,
+		Volatility: defaultVolatility,
+		Depth:      defaultDepth,
+		OrderRate:  defaultOrderRate,
+		TradeRate:  defaultTradeRate,
+		MaxSize:    defaultMaxSize,
+	}
+
+	return &cfg
+}
+
+// ReplayConfig recording replay config
+type ReplayConfig struct {
+	// File recording file in JSONL format written by client.Recorder
+	File string `toml:"file"`
+	// Speed replay speed multiple to recording time, 0 means as fast as possible
+	Speed float64 `toml:"speed"`
+}
+
+// Validate check replay config values
+func (c *ReplayConfig) Validate() error {
+	if c.Speed < 0 {
+		return errors.New("replay speed can not be negative")
+	}
+
+	return nil
+}
+
+// NewReplayConfig create a new replay config in real time speed
+func NewReplayConfig() *ReplayConfig {
+	cfg := ReplayConfig{
+		Speed: defaultReplaySpeed,
+	}
+
+	return &cfg
+}
+
+// UpstreamConfig upstream source config for upstream mock mode
+type UpstreamConfig struct {
+	// URL upstream websocket url, empty means client's default host
+	URL string `toml:"url"`
+	// Symbols symbols cascaded from upstream, empty means all symbols
+	Symbols []string `toml:"symbols"`
+	// Topics topics subscribed from upstream
+	Topics []string `toml:"topics"`
+	// APIKey api key for upstream authentication, empty means no authentication
+	APIKey string `toml:"api_key"`
+	// APISecret api secret for upstream authentication
+	APISecret string `toml:"api_secret"`
+	// AuthURI uri signed in upstream authentication
+	AuthURI string `toml:"auth_uri"`
+	// ReconnectDelay initial reconnect delay in seconds
+	ReconnectDelay float64 `toml:"reconnect_delay"`
+	// MaxReconnectDelay max reconnect delay in seconds
+	MaxReconnectDelay float64 `toml:"max_reconnect_delay"`
+	// BackoffFactor reconnect delay multiplier for each continuous failure
+	BackoffFactor float64 `toml:"backoff_factor"`
+}
+
+// Validate check upstream config values
+func (c *UpstreamConfig) Validate() error {
+	if c.URL != "" {
+		if err := client.NewConfig().ChangeHost(c.URL); err != nil {
+			return fmt.Errorf("invalid upstream url %s: %v", c.URL, err)
+		}
+	}
+
+	if len(c.Topics) < 1 {
+		return errors.New("no upstream topic configured")
+	}
+
+	for _, topic := range c.Topics {
+		valid := false
+
+		for _, name := range UpstreamTopics {
+			if topic == name {
+				valid = true
+				break
+			}
+		}
+
+		if !valid {
+			return fmt.Errorf("invalid upstream topic: %s", topic)
+		}
+	}
+
+	if (c.APIKey == "") != (c.APISecret == "") {
+		return errors.New("upstream api key & secret must be configured together")
+	}
+
+	if c.APIKey != "" && !strings.HasPrefix(c.AuthURI, "/") {
+		return fmt.Errorf("invalid upstream auth uri: %s", c.AuthURI)
+	}
+
+	if c.ReconnectDelay <= 0 || c.MaxReconnectDelay < c.ReconnectDelay || c.BackoffFactor < 1 {
+		return errors.New("upstream reconnect delay must be positive, max delay & backoff factor can not be less than it & 1")
+	}
+
+	return nil
+}
+
+// HasSymbol check if symbol is cascaded from upstream
+func (c *UpstreamConfig) HasSymbol(symbol string) bool {
+	if len(c.Symbols) < 1 {
+		return true
+	}
+
+	for _, name := range c.Symbols {
+		if name == symbol {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Backoff reconnect delay after continuous failure count
+func (c *UpstreamConfig) Backoff(failures int) time.Duration {
+	delay := c.ReconnectDelay * math.Pow(c.BackoffFactor, float64(failures))
+
+	if delay > c.MaxReconnectDelay {
+		delay = c.MaxReconnectDelay
+	}
+
+	return time.Duration(delay * float64(time.Second))
+}
+
+// NewUpstreamConfig create a new upstream config for client's default host
+func NewUpstreamConfig() *UpstreamConfig {
+	cfg := UpstreamConfig{
+		Topics:            append([]string{}, UpstreamTopics...),
+		AuthURI:           defaultUpstreamAuthURI,
+		ReconnectDelay:    defaultReconnectDelay,
+		MaxReconnectDelay: defaultMaxReconnectDelay,
+		BackoffFactor:     defaultBackoffFactor,
+	}
+
+	return &cfg
+}
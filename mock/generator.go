@@ -0,0 +1,277 @@
+package mock
+
+import (
+	"context"
+	"hash/crc32"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/orderbook"
+	"github.com/frozenpine/wstester/utils"
+	"github.com/frozenpine/wstester/utils/log"
+)
+
+const (
+	generateInterval   = time.Millisecond * 100
+	instrumentInterval = time.Second
+)
+
+// Generator synthetic market generator for one symbol,
+// limit & market orders are placed into matching engine around a random walk mid price,
+// so that book levels are consumed by real trades, instrument is derived from engine's book.
+type Generator struct {
+	engine     *orderbook.Engine
+	instrument utils.Cache
+
+	cfgLock sync.Mutex
+	cfg     GeneratorConfig
+
+	rand     *rand.Rand
+	midPrice float64
+	elapsed  time.Duration
+
+	// resting generated orders' price
+	orders map[string]float64
+}
+
+// SetConfig change generator's volatility, depth, rates & max size
+func (g *Generator) SetConfig(cfg *GeneratorConfig) {
+	g.cfgLock.Lock()
+	defer g.cfgLock.Unlock()
+
+	g.cfg.Volatility = cfg.Volatility
+	g.cfg.Depth = cfg.Depth
+	g.cfg.OrderRate = cfg.OrderRate
+	g.cfg.TradeRate = cfg.TradeRate
+	g.cfg.MaxSize = cfg.MaxSize
+}
+
+func (g *Generator) getConfig() GeneratorConfig {
+	g.cfgLock.Lock()
+	defer g.cfgLock.Unlock()
+
+	return g.cfg
+}
+
+// poisson random arrival count with expected value lambda
+func (g *Generator) poisson(lambda float64) int {
+	if lambda <= 0 {
+		return 0
+	}
+
+	var (
+		limit = math.Exp(-lambda)
+		count = 0
+		prob  = g.rand.Float64()
+	)
+
+	for prob > limit {
+		count++
+		prob *= g.rand.Float64()
+	}
+
+	return count
+}
+
+func (g *Generator) place(ord *ngerest.Order) {
+	rst, err := g.engine.Place(ord)
+	if err != nil {
+		log.Error("Generate order failed: ", err)
+		return
+	}
+
+	for _, fill := range rst.Fills {
+		if fill.Maker.LeavesQty <= 0 {
+			delete(g.orders, fill.Maker.OrderID)
+		}
+	}
+
+	if rst.Order.LeavesQty > 0 {
+		g.orders[rst.Order.OrderID] = rst.Order.Price
+	}
+}
+
+// bestPrices best bid & ask price for generated limit orders around mid price
+func (g *Generator) bestPrices() (float64, float64) {
+	tick := g.engine.TickSize()
+
+	bestBid := math.Floor(g.midPrice/tick) * tick
+
+	return bestBid, bestBid + tick
+}
+
+func (g *Generator) placeLimit(side string, level int, size float32) {
+	tick := g.engine.TickSize()
+	bestBid, bestAsk := g.bestPrices()
+
+	var price float64
+
+	if side == orderbook.SideBuy {
+		price = bestBid - float64(level)*tick
+	} else {
+		price = bestAsk + float64(level)*tick
+	}
+
+	if price <= 0 {
+		return
+	}
+
+	g.place(&ngerest.Order{Side: side, OrderQty: size, Price: price})
+}
+
+func (g *Generator) randomSide() string {
+	if g.rand.Intn(2) == 0 {
+		return orderbook.SideBuy
+	}
+
+	return orderbook.SideSell
+}
+
+func (g *Generator) randomSize(cfg *GeneratorConfig) float32 {
+	return float32(g.rand.Intn(cfg.MaxSize) + 1)
+}
+
+// trim cancel generated orders out of depth range around mid price
+func (g *Generator) trim(depth int) {
+	bound := float64(depth) * g.engine.TickSize()
+
+	for orderID, price := range g.orders {
+		if math.Abs(price-g.midPrice) <= bound {
+			continue
+		}
+
+		delete(g.orders, orderID)
+
+		// order may be filled by other users
+		g.engine.Cancel(orderID)
+	}
+}
+
+func (g *Generator) publishInstrument(action string) {
+	if g.instrument == nil {
+		return
+	}
+
+	ts := ngerest.NGETime(time.Now())
+	markPrice := math.Round(g.midPrice*100) / 100
+
+	ins := ngerest.Instrument{
+		Symbol:                g.engine.Symbol(),
+		LastPrice:             g.engine.LastPrice(),
+		LastTickDirection:     g.engine.LastTickDirection(),
+		MarkPrice:             markPrice,
+		IndicativeSettlePrice: markPrice,
+		FairPrice:             markPrice,
+		Timestamp:             &ts,
+	}
+
+	ins.BidPrice, _ = g.engine.BestBid()
+	ins.AskPrice, _ = g.engine.BestAsk()
+
+	if ins.BidPrice > 0 && ins.AskPrice > 0 {
+		ins.MidPrice = (ins.BidPrice + ins.AskPrice) / 2
+	}
+
+	var rsp *models.InstrumentResponse
+
+	if action == models.PartialAction {
+		rsp = models.NewInstrumentPartial()
+
+		ins.State = "Open"
+		ins.Typ = "FFWCSX"
+		ins.TickSize = g.engine.TickSize()
+		ins.MarkMethod = "FairPrice"
+	} else {
+		rsp = &models.InstrumentResponse{}
+		rsp.Table = "instrument"
+		rsp.Action = action
+	}
+
+	rsp.Data = []*ngerest.Instrument{&ins}
+
+	g.instrument.Append(utils.NewCacheInput(rsp))
+}
+
+// init place limit orders on each depth level of both sides
+func (g *Generator) init() {
+	cfg := g.getConfig()
+
+	for level := 0; level < cfg.Depth; level++ {
+		g.placeLimit(orderbook.SideBuy, level, g.randomSize(&cfg))
+		g.placeLimit(orderbook.SideSell, level, g.randomSize(&cfg))
+	}
+
+	g.publishInstrument(models.PartialAction)
+}
+
+// step move mid price & generate orders arrived in duration
+func (g *Generator) step(duration time.Duration) {
+	cfg := g.getConfig()
+	seconds := duration.Seconds()
+
+	g.midPrice *= math.Exp(cfg.Volatility * math.Sqrt(seconds) * g.rand.NormFloat64())
+
+	for count := g.poisson(cfg.OrderRate * seconds); count > 0; count-- {
+		g.placeLimit(g.randomSide(), g.rand.Intn(cfg.Depth), g.randomSize(&cfg))
+	}
+
+	for count := g.poisson(cfg.TradeRate * seconds); count > 0; count-- {
+		g.place(&ngerest.Order{
+			Side:     g.randomSide(),
+			OrdType:  orderbook.OrdTypeMarket,
+			OrderQty: g.randomSize(&cfg),
+		})
+	}
+
+	g.trim(cfg.Depth)
+
+	if g.elapsed += duration; g.elapsed >= instrumentInterval {
+		g.elapsed = 0
+
+		g.publishInstrument(models.UpdateAction)
+	}
+}
+
+// Run generate market data until ctx done
+func (g *Generator) Run(ctx context.Context) {
+	g.init()
+
+	ticker := time.NewTicker(generateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			g.step(generateInterval)
+		}
+	}
+}
+
+// NewGenerator create synthetic market generator on engine,
+// instrument derived from engine will be appended to instrument cache.
+func NewGenerator(cfg *GeneratorConfig, engine *orderbook.Engine, instrument utils.Cache) *Generator {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	// different random walk for each symbol with same seed
+	seed += int64(crc32.ChecksumIEEE([]byte(engine.Symbol())))
+
+	generator := Generator{
+		engine:     engine,
+		instrument: instrument,
+		cfg:        *cfg,
+		rand:       rand.New(rand.NewSource(seed)),
+		midPrice:   cfg.MidPrice,
+		orders:     make(map[string]float64),
+	}
+
+	return &generator
+}
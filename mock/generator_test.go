@@ -0,0 +1,100 @@
+package mock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/orderbook"
+	"github.com/frozenpine/wstester/utils"
+)
+
+func TestGeneratorSeed(t *testing.T) {
+	cfg := NewGeneratorConfig()
+	cfg.Seed = 1
+
+	var lastPrices [2][]float64
+
+	for idx := range lastPrices {
+		engine := orderbook.NewEngine("XBTUSD", cfg.TickSize, nil, nil)
+		generator := NewGenerator(cfg, engine, nil)
+
+		generator.init()
+
+		for step := 0; step < 100; step++ {
+			generator.step(generateInterval)
+
+			lastPrices[idx] = append(lastPrices[idx], engine.LastPrice())
+		}
+	}
+
+	for step := range lastPrices[0] {
+		if lastPrices[0][step] != lastPrices[1][step] {
+			t.Fatalf("trade price miss-match with same seed at step %d", step)
+		}
+	}
+}
+
+func TestGeneratorBook(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	mbl := utils.NewMBLCache(ctx, "XBTUSD")
+	td := utils.NewTradeCache(ctx, "XBTUSD")
+	ins := utils.NewInstrumentCache(ctx, "XBTUSD")
+
+	cfg := NewGeneratorConfig()
+	cfg.Seed = 1
+	cfg.Volatility = 0.005
+	cfg.TradeRate = 20
+
+	engine := orderbook.NewEngine("XBTUSD", cfg.TickSize, mbl, td)
+	generator := NewGenerator(cfg, engine, ins)
+
+	generator.init()
+
+	for step := 0; step < 50; step++ {
+		generator.step(generateInterval)
+
+		bid, _ := engine.BestBid()
+		ask, _ := engine.BestAsk()
+
+		if bid > 0 && ask > 0 && bid >= ask {
+			t.Fatalf("book crossed: bid %.1f, ask %.1f", bid, ask)
+		}
+	}
+
+	snap := mbl.TakeSnapshot(0, nil, "").(*models.MBLResponse)
+
+	var bids, asks int
+	for _, l2 := range snap.Data {
+		if l2.Side == orderbook.SideBuy {
+			bids++
+		} else {
+			asks++
+		}
+	}
+
+	if bids < 1 || asks < 1 || bids > cfg.Depth*2+1 || asks > cfg.Depth*2+1 {
+		t.Fatalf("book depth out of range: bids %d, asks %d", bids, asks)
+	}
+
+	trades := td.TakeSnapshot(0, nil, "").(*models.TradeResponse)
+	if len(trades.Data) < 1 {
+		t.Fatal("no trade generated")
+	}
+
+	if last := trades.Data[len(trades.Data)-1]; last.Price != engine.LastPrice() {
+		t.Fatal("last trade miss-match with engine")
+	}
+
+	instrument := ins.TakeSnapshot(0, nil, "").(*models.InstrumentResponse)
+	if len(instrument.Data) != 1 {
+		t.Fatal("instrument not generated")
+	}
+
+	if data := instrument.Data[0]; data.LastPrice <= 0 || data.MarkPrice <= 0 ||
+		data.BidPrice <= 0 || data.BidPrice >= data.AskPrice {
+		t.Fatalf("instrument not derived from book: %+v", data)
+	}
+}
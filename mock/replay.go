@@ -0,0 +1,338 @@
+package mock
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/frozenpine/wstester/client"
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils"
+	"github.com/frozenpine/wstester/utils/log"
+)
+
+// max line size in recording file, partial of a deep book can be large
+const maxRecordSize = 64 * 1024 * 1024
+
+var errSeek = errors.New("replay seek requested")
+
+// ReplayStatus replay progress status
+type ReplayStatus struct {
+	File     string    `json:"file"`
+	Speed    float64   `json:"speed"`
+	Paused   bool      `json:"paused"`
+	Finished bool      `json:"finished"`
+	Position string    `json:"position"`
+	FrameTS  time.Time `json:"frameTimestamp"`
+}
+
+// Replay feed frames in recording file back into caches through Cache.Append,
+// frames are paced by their receive time in recording.
+type Replay struct {
+	path string
+	// caches for each symbol & table
+	caches map[string]map[string]utils.Cache
+
+	lock     sync.Mutex
+	wakeup   chan struct{}
+	speed    float64
+	paused   bool
+	finished bool
+	seeking  bool
+	seekTo   time.Duration
+
+	// recording offset & receive time of last played frame
+	position time.Duration
+	frameTS  time.Time
+
+	// pacing anchor, recording offset played at anchor time
+	anchorOffset time.Duration
+	anchorTime   time.Time
+}
+
+func (r *Replay) notify() {
+	select {
+	case r.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// reanchor pace following frames from current position, must be called with lock held
+func (r *Replay) reanchor() {
+	r.anchorOffset = r.position
+	r.anchorTime = time.Now()
+}
+
+// SetSpeed change replay speed multiple, 0 means as fast as possible
+func (r *Replay) SetSpeed(speed float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.reanchor()
+	r.speed = speed
+
+	r.notify()
+}
+
+// Pause pause replay at current position
+func (r *Replay) Pause() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.paused = true
+
+	r.notify()
+}
+
+// Resume resume replay from current position
+func (r *Replay) Resume() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.reanchor()
+	r.paused = false
+
+	r.notify()
+}
+
+// Seek replay from offset to recording start,
+// frames before offset will be fed as fast as possible to rebuild caches.
+func (r *Replay) Seek(offset time.Duration) {
+	if offset < 0 {
+		offset = 0
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.seeking = true
+	r.seekTo = offset
+	r.finished = false
+
+	log.Info("Replay seek to: ", offset)
+
+	r.notify()
+}
+
+// Status get replay progress status
+func (r *Replay) Status() *ReplayStatus {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	status := ReplayStatus{
+		File:     r.path,
+		Speed:    r.speed,
+		Paused:   r.paused,
+		Finished: r.finished,
+		Position: r.position.String(),
+		FrameTS:  r.frameTS,
+	}
+
+	return &status
+}
+
+// check interrupt by seek request or ctx done
+func (r *Replay) check(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.seeking {
+		return errSeek
+	}
+
+	return nil
+}
+
+// wait block until frame at recording offset should be played
+func (r *Replay) wait(ctx context.Context, offset time.Duration) error {
+	for {
+		if err := r.check(ctx); err != nil {
+			return err
+		}
+
+		r.lock.Lock()
+		paused := r.paused
+		var delay time.Duration
+		if r.speed > 0 {
+			delay = time.Duration(float64(offset-r.anchorOffset)/r.speed) - time.Since(r.anchorTime)
+		}
+		r.lock.Unlock()
+
+		if !paused && delay <= 0 {
+			return nil
+		}
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !paused {
+			timer = time.NewTimer(delay)
+			timeout = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-r.wakeup:
+		case <-timeout:
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (r *Replay) feed(record *client.Record) {
+	caches, exist := r.caches[record.Symbol]
+	if !exist {
+		return
+	}
+
+	cache, exist := caches[record.Table]
+	if !exist || cache == nil {
+		return
+	}
+
+	var rsp models.TableResponse
+
+	switch {
+	case strings.HasPrefix(record.Table, "orderBook"):
+		rsp = &models.MBLResponse{}
+	case record.Table == "trade":
+		rsp = &models.TradeResponse{}
+	case record.Table == "instrument":
+		rsp = &models.InstrumentResponse{}
+	default:
+		return
+	}
+
+	if err := json.Unmarshal(record.Frame, rsp); err != nil {
+		log.Errorf("Fail to parse recorded %s frame: %v", record.Table, err)
+		return
+	}
+
+	cache.Append(utils.NewCacheInput(rsp))
+}
+
+// play feed frames in recording, frames before skipTo are fed without pacing
+func (r *Replay) play(ctx context.Context, reader io.Reader, skipTo time.Duration) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxRecordSize)
+
+	var (
+		start    time.Time
+		anchored bool
+	)
+
+	for scanner.Scan() {
+		var record client.Record
+
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Error("Invalid record in replay file: ", err)
+			continue
+		}
+
+		if start.IsZero() {
+			start = record.Timestamp
+		}
+		offset := record.Timestamp.Sub(start)
+
+		if offset < skipTo {
+			if err := r.check(ctx); err != nil {
+				return err
+			}
+		} else {
+			if !anchored {
+				r.lock.Lock()
+				r.position = skipTo
+				r.reanchor()
+				r.lock.Unlock()
+
+				anchored = true
+			}
+
+			if err := r.wait(ctx, offset); err != nil {
+				return err
+			}
+		}
+
+		r.feed(&record)
+
+		r.lock.Lock()
+		r.position = offset
+		r.frameTS = record.Timestamp
+		r.lock.Unlock()
+	}
+
+	return scanner.Err()
+}
+
+// Run replay recording until ctx done, replay can still be sought after finished
+func (r *Replay) Run(ctx context.Context) error {
+	file, err := os.Open(r.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for {
+		r.lock.Lock()
+		skipTo := r.seekTo
+		r.seeking = false
+		r.finished = false
+		r.lock.Unlock()
+
+		if _, err = file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		switch err = r.play(ctx, file, skipTo); err {
+		case errSeek:
+			continue
+		case nil:
+		default:
+			return err
+		}
+
+		r.lock.Lock()
+		r.finished = true
+		r.lock.Unlock()
+
+		log.Info("Replay finished: ", r.path)
+
+		for r.check(ctx) == nil {
+			select {
+			case <-ctx.Done():
+			case <-r.wakeup:
+			}
+		}
+
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// NewReplay create replay for recording file,
+// caches are mapped by symbol & table name in recording.
+func NewReplay(cfg *ReplayConfig, caches map[string]map[string]utils.Cache) *Replay {
+	replay := Replay{
+		path:   cfg.File,
+		caches: caches,
+		wakeup: make(chan struct{}, 1),
+		speed:  cfg.Speed,
+	}
+
+	return &replay
+}
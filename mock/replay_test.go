@@ -0,0 +1,177 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/client"
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils"
+)
+
+type testFrame struct {
+	offset time.Duration
+	table  string
+	symbol string
+	frame  interface{}
+}
+
+func writeTestRecording(t *testing.T, frames []testFrame) string {
+	recorder, err := client.NewRecorder(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+
+	for _, f := range frames {
+		data, _ := json.Marshal(f.frame)
+
+		if err := recorder.Record(start.Add(f.offset), f.table, f.symbol, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return recorder.Path()
+}
+
+func newTestMBL(action string, levels ...*ngerest.OrderBookL2) *models.MBLResponse {
+	var rsp *models.MBLResponse
+
+	if action == models.PartialAction {
+		rsp = models.NewMBLPartial()
+	} else {
+		rsp = &models.MBLResponse{}
+		rsp.Table = "orderBookL2"
+		rsp.Action = action
+	}
+
+	rsp.Data = levels
+
+	return rsp
+}
+
+func waitReplay(t *testing.T, replay *Replay, check func(*ReplayStatus) bool) *ReplayStatus {
+	deadline := time.Now().Add(time.Second * 3)
+
+	for time.Now().Before(deadline) {
+		if status := replay.Status(); check(status) {
+			return status
+		}
+
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	t.Fatalf("replay status miss-match: %+v", replay.Status())
+
+	return nil
+}
+
+func TestReplay(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	trades := models.NewTradePartial()
+	trades.Data = []*ngerest.Trade{{Symbol: "XBTUSD", Side: "Buy", Size: 5, Price: 9000.5}}
+
+	path := writeTestRecording(t, []testFrame{
+		{0, "", "XBTUSD", map[string]string{"info": "Welcome"}},
+		{0, "orderBookL2", "XBTUSD", newTestMBL(models.PartialAction,
+			&ngerest.OrderBookL2{Symbol: "XBTUSD", ID: 1, Side: "Sell", Size: 10, Price: 9000.5},
+			&ngerest.OrderBookL2{Symbol: "XBTUSD", ID: 2, Side: "Buy", Size: 10, Price: 9000})},
+		{time.Hour, "orderBookL2", "ETHUSD", newTestMBL(models.InsertAction,
+			&ngerest.OrderBookL2{Symbol: "ETHUSD", ID: 3, Side: "Buy", Size: 10, Price: 200})},
+		{time.Hour * 2, "trade", "XBTUSD", trades},
+		{time.Hour * 3, "orderBookL2", "XBTUSD", newTestMBL(models.InsertAction,
+			&ngerest.OrderBookL2{Symbol: "XBTUSD", ID: 4, Side: "Buy", Size: 3, Price: 8999.5})},
+	})
+
+	mbl := utils.NewMBLCache(ctx, "XBTUSD")
+	td := utils.NewTradeCache(ctx, "XBTUSD")
+
+	cfg := NewReplayConfig()
+	cfg.File = path
+	cfg.Speed = 0
+
+	replay := NewReplay(cfg, map[string]map[string]utils.Cache{
+		"XBTUSD": {"orderBookL2": mbl, "trade": td},
+	})
+
+	go replay.Run(ctx)
+
+	status := waitReplay(t, replay, func(s *ReplayStatus) bool { return s.Finished })
+	if status.Position != (time.Hour * 3).String() {
+		t.Fatal("replay position miss-match:", status.Position)
+	}
+
+	snap := mbl.TakeSnapshot(0, nil, "").(*models.MBLResponse)
+	if len(snap.Data) != 3 {
+		t.Fatal("mbl not replayed:", snap.String())
+	}
+
+	tdSnap := td.TakeSnapshot(0, nil, "").(*models.TradeResponse)
+	if len(tdSnap.Data) != 1 || tdSnap.Data[0].Price != 9000.5 {
+		t.Fatal("trade not replayed:", tdSnap.String())
+	}
+
+	// seek back to rebuild caches from recording start
+	replay.Seek(time.Hour)
+	waitReplay(t, replay, func(s *ReplayStatus) bool { return s.Finished })
+
+	if snap = mbl.TakeSnapshot(0, nil, "").(*models.MBLResponse); len(snap.Data) != 3 {
+		t.Fatal("mbl miss-match after seek:", snap.String())
+	}
+}
+
+func TestReplayPauseSeek(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	path := writeTestRecording(t, []testFrame{
+		{0, "orderBookL2", "XBTUSD", newTestMBL(models.PartialAction,
+			&ngerest.OrderBookL2{Symbol: "XBTUSD", ID: 1, Side: "Sell", Size: 10, Price: 9000.5})},
+		{time.Hour, "orderBookL2", "XBTUSD", newTestMBL(models.InsertAction,
+			&ngerest.OrderBookL2{Symbol: "XBTUSD", ID: 2, Side: "Buy", Size: 10, Price: 9000})},
+	})
+
+	mbl := utils.NewMBLCache(ctx, "XBTUSD")
+
+	cfg := NewReplayConfig()
+	cfg.File = path
+
+	replay := NewReplay(cfg, map[string]map[string]utils.Cache{
+		"XBTUSD": {"orderBookL2": mbl},
+	})
+	replay.Pause()
+
+	go replay.Run(ctx)
+
+	time.Sleep(time.Millisecond * 50)
+
+	if status := replay.Status(); !status.FrameTS.IsZero() || !status.Paused {
+		t.Fatalf("frame played while paused: %+v", status)
+	}
+
+	// frames before offset fed immediately, frame at offset waits for resume
+	replay.Seek(time.Hour)
+	waitReplay(t, replay, func(s *ReplayStatus) bool { return !s.FrameTS.IsZero() })
+
+	if snap := mbl.TakeSnapshot(0, nil, "").(*models.MBLResponse); len(snap.Data) != 1 {
+		t.Fatal("mbl miss-match before resume:", snap.String())
+	}
+
+	// one hour later frame in real time speed played immediately after seeking to it
+	replay.Resume()
+	waitReplay(t, replay, func(s *ReplayStatus) bool { return s.Finished })
+
+	if snap := mbl.TakeSnapshot(0, nil, "").(*models.MBLResponse); len(snap.Data) != 2 {
+		t.Fatal("mbl miss-match after resume:", snap.String())
+	}
+}
@@ -1,6 +1,7 @@
 package mock
 
 import (
+	"context"
 	"math"
 	"math/rand"
 	"strings"
@@ -13,8 +14,8 @@ import (
 	uuid "github.com/satori/go.uuid"
 )
 
-// Trade mock trade response
-func Trade(cache utils.Cache) {
+// Trade mock trade response for symbol until ctx done
+func Trade(ctx context.Context, symbol string, cache utils.Cache) {
 	var (
 		lastPrice         float64
 		lastTickDirection string
@@ -33,7 +34,12 @@ func Trade(cache utils.Cache) {
 	for {
 		start := time.Now()
 		rand.Seed(start.UnixNano())
-		time.Sleep(time.Second * time.Duration(rand.Intn(2)+1))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second * time.Duration(rand.Intn(2)+1)):
+		}
 		count := rand.Int63n(100) + 1
 
 		mockTrad := models.TradeResponse{}
@@ -75,7 +81,7 @@ func Trade(cache utils.Cache) {
 			lastPrice = price
 
 			td := ngerest.Trade{
-				Symbol:        "XBTUSD",
+				Symbol:        symbol,
 				Side:          sides[choice%2],
 				Size:          size,
 				Price:         price,
@@ -8,32 +8,84 @@ import (
 	"github.com/frozenpine/wstester/models"
 	"github.com/frozenpine/wstester/utils"
 	"github.com/frozenpine/wstester/utils/log"
+	"github.com/frozenpine/wstester/utils/metrics"
 )
 
-// Upstream get mbl|trade|instrument response from upstream www.btcmex.com
-func Upstream(caches map[string]utils.Cache) {
-	for {
-		cfg := client.NewConfig()
-		cfg.DisableCache()
-		ins := client.NewClient(cfg)
+func newUpstreamClient(cfg *UpstreamConfig, symbol string, recorder *client.Recorder) client.Client {
+	clientCfg := client.NewConfig()
+	if cfg.URL != "" {
+		clientCfg.ChangeHost(cfg.URL)
+	}
+	clientCfg.Symbol = symbol
+	clientCfg.DisableCache()
+	clientCfg.SetRecorder(recorder)
+
+	return client.NewClient(clientCfg)
+}
 
-		var topics []string
-		for topic := range caches {
+// Upstream get configured topics' response of symbol from upstream host,
+// reconnect delay grows by backoff policy for continuous failures,
+// upstream will be closed when ctx done,
+// raw frames will be recorded if recorder is not nil.
+func Upstream(
+	ctx context.Context, cfg *UpstreamConfig, symbol string, caches map[string]utils.Cache, recorder *client.Recorder) {
+	reconnects := metrics.DefaultRegistry.Counter(
+		"wstester_upstream_reconnects_total", "Upstream reconnect count.", metrics.Labels{"symbol": symbol})
+
+	if cfg.APIKey != "" {
+		ctx = context.WithValue(ctx, client.ContextAPIKey, client.APIKeyAuth{
+			Key:     cfg.APIKey,
+			Secret:  cfg.APISecret,
+			AuthURI: cfg.AuthURI,
+		})
+	}
+
+	var topics []string
+	for _, topic := range cfg.Topics {
+		if _, exist := caches[topic]; exist {
 			topics = append(topics, topic)
 		}
+	}
+
+	failures := 0
+
+	for round := 0; ; round++ {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		if round > 0 {
+			reconnects.Inc()
+		}
+
+		ins := newUpstreamClient(cfg, symbol, recorder)
 		ins.Subscribe(topics...)
 
-		ctx, cancelFn := context.WithCancel(context.Background())
+		upCtx, cancelFn := context.WithCancel(ctx)
 
-		err := ins.Connect(ctx)
+		err := ins.Connect(upCtx)
 		if err != nil {
+			cancelFn()
 			log.Error(err)
 
-			time.Sleep(time.Second * 3)
+			delay := cfg.Backoff(failures)
+			failures++
+
+			log.Warnf("Reconnect upstream for %s after: %v", symbol, delay)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
 
 			continue
 		}
 
+		connectedAt := time.Now()
+
 		go func() {
 			mblChan := ins.GetResponse("orderBookL2")
 			tdChan := ins.GetResponse("trade")
@@ -47,7 +99,7 @@ func Upstream(caches map[string]utils.Cache) {
 
 			for {
 				select {
-				case <-ctx.Done():
+				case <-upCtx.Done():
 					return
 				case rsp, ok = <-tdChan:
 					topic = "trade"
@@ -70,12 +122,29 @@ func Upstream(caches map[string]utils.Cache) {
 			}
 		}()
 
-		<-ins.Closed()
+		select {
+		case <-ins.Closed():
+		case <-ctx.Done():
+			cancelFn()
+			return
+		}
 
-		log.Warn("Mock MBL upstream closed.")
+		log.Warnf("Mock upstream for %s closed.", symbol)
 
 		cancelFn()
 
-		<-time.After(time.Second * 5)
+		// flapping connection keeps backing off
+		if time.Since(connectedAt).Seconds() > cfg.MaxReconnectDelay {
+			failures = 0
+		}
+
+		delay := cfg.Backoff(failures)
+		failures++
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
 	}
 }
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"regexp"
 	"strings"
 
@@ -32,18 +34,30 @@ var (
 	// SubPattern subscribe message pattern
 	SubPattern = regexp.MustCompile(`"subscribe"`)
 
+	// UnsubPattern unsubscribe message pattern
+	UnsubPattern = regexp.MustCompile(`"unsubscribe"`)
+
 	// AuthPattern auth message pattern
 	AuthPattern = regexp.MustCompile(`"authKeyExpires"|"api-key"`)
 
 	// InstrumentPattern instrument message pattern
 	InstrumentPattern = regexp.MustCompile(`"table": ?"instrument"`)
 
+	// OrderBook10Pattern orderBook10 message pattern, must be matched before MBLPattern
+	OrderBook10Pattern = regexp.MustCompile(`"table": ?"orderBook10"`)
+
 	// MBLPattern mbl message pattern
 	MBLPattern = regexp.MustCompile(`"table": ?"orderBook`)
 
 	// TradePattern trade message pattern
 	TradePattern = regexp.MustCompile(`"table": ?"trade"`)
 
+	// TradeBinPattern trade bin message pattern
+	TradeBinPattern = regexp.MustCompile(`"table": ?"tradeBin`)
+
+	// QuotePattern quote & quote bin message pattern
+	QuotePattern = regexp.MustCompile(`"table": ?"quote`)
+
 	// ErrPattern error message pattern
 	ErrPattern = regexp.MustCompile(`"error"`)
 )
@@ -85,8 +99,21 @@ func (args *Args) UnmarshalJSON(data []byte) error {
 
 	if stringPattern.Match(data) {
 		parsed = strings.Split(strings.Trim(string(data), `"`), ",")
-	} else {
-		err = json.Unmarshal(data, &parsed)
+	} else if err = json.Unmarshal(data, &parsed); err != nil {
+		// args like authKeyExpires has mixed string & number elements
+		var mixed []interface{}
+
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+
+		if err = decoder.Decode(&mixed); err != nil {
+			return err
+		}
+
+		parsed = make([]string, len(mixed))
+		for idx, arg := range mixed {
+			parsed[idx] = fmt.Sprint(arg)
+		}
 	}
 
 	if err == nil {
@@ -208,6 +235,35 @@ func (sub *SubscribeResponse) IsPartialResponse() bool {
 	return false
 }
 
+// UnsubscribeResponse unsubscribe response
+type UnsubscribeResponse struct {
+	Success     bool             `json:"success"`
+	Unsubscribe string           `json:"unsubscribe"`
+	Request     OperationRequest `json:"request"`
+}
+
+// String get structure's string format
+func (unsub *UnsubscribeResponse) String() string {
+	result, _ := json.Marshal(unsub)
+
+	return string(result)
+}
+
+// Format format String output
+func (unsub *UnsubscribeResponse) Format(format string) string {
+	return unsub.String()
+}
+
+// IsTableResponse determinate wether response is a table data
+func (unsub *UnsubscribeResponse) IsTableResponse() bool {
+	return false
+}
+
+// IsPartialResponse determinate wether table response is partial data
+func (unsub *UnsubscribeResponse) IsPartialResponse() bool {
+	return false
+}
+
 // ErrResponse error response
 type ErrResponse struct {
 	Error string `json:"error"`
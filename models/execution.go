@@ -13,6 +13,21 @@ type ExecutionResponse struct {
 	Data []*ngerest.Execution `json:"data"`
 }
 
+// NewExecutionPartial make a new execution partial response
+func NewExecutionPartial() *ExecutionResponse {
+	partial := ExecutionResponse{}
+
+	partial.Table = "execution"
+	partial.Action = PartialAction
+	partial.Keys = []string{}
+	partial.Types = make(map[string]string)
+	partial.ForeignKeys = make(map[string]string)
+	partial.Attributes = make(map[string]string)
+	partial.Filter = make(map[string]string)
+
+	return &partial
+}
+
 func (exec *ExecutionResponse) String() string {
 	result, _ := json.Marshal(exec)
 
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+)
+
+// FilteredResponse table response with rows filtered & columns projected by SQL
+type FilteredResponse struct {
+	tableResponse
+
+	Data []map[string]interface{} `json:"data"`
+}
+
+// NewFilteredResponse make a new filtered response for table & action
+func NewFilteredResponse(table, action string, data []map[string]interface{}) *FilteredResponse {
+	rsp := FilteredResponse{Data: data}
+
+	rsp.Table = table
+	rsp.Action = action
+
+	if rsp.Data == nil {
+		rsp.Data = []map[string]interface{}{}
+	}
+
+	return &rsp
+}
+
+// String get structure's string format
+func (rsp *FilteredResponse) String() string {
+	result, _ := json.Marshal(rsp)
+
+	return string(result)
+}
+
+// Format format String output
+func (rsp *FilteredResponse) Format(format string) string {
+	return rsp.String()
+}
+
+// GetAction get action for response
+func (rsp *FilteredResponse) GetAction() string {
+	return rsp.Action
+}
+
+// GetData get data for reponse
+func (rsp *FilteredResponse) GetData() []interface{} {
+	var data []interface{}
+
+	for _, d := range rsp.Data {
+		data = append(data, d)
+	}
+
+	return data
+}
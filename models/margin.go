@@ -13,6 +13,21 @@ type MarginResponse struct {
 	Data []*ngerest.Margin `json:"data"`
 }
 
+// NewMarginPartial make a new margin partial response
+func NewMarginPartial() *MarginResponse {
+	partial := MarginResponse{}
+
+	partial.Table = "margin"
+	partial.Action = PartialAction
+	partial.Keys = []string{}
+	partial.Types = make(map[string]string)
+	partial.ForeignKeys = make(map[string]string)
+	partial.Attributes = make(map[string]string)
+	partial.Filter = make(map[string]string)
+
+	return &partial
+}
+
 func (margin *MarginResponse) String() string {
 	result, _ := json.Marshal(margin)
 
@@ -13,6 +13,21 @@ type OrderResponse struct {
 	Data []*ngerest.Order `json:"data"`
 }
 
+// NewOrderPartial make a new order partial response
+func NewOrderPartial() *OrderResponse {
+	partial := OrderResponse{}
+
+	partial.Table = "order"
+	partial.Action = PartialAction
+	partial.Keys = []string{}
+	partial.Types = make(map[string]string)
+	partial.ForeignKeys = make(map[string]string)
+	partial.Attributes = make(map[string]string)
+	partial.Filter = make(map[string]string)
+
+	return &partial
+}
+
 func (ord *OrderResponse) String() string {
 	result, _ := json.Marshal(ord)
 
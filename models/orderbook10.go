@@ -0,0 +1,88 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/frozenpine/ngerest"
+)
+
+// OrderBook10 top 10 levels snapshot, levels are encoded as [price, size] arrays from best price
+type OrderBook10 struct {
+	Symbol    string           `json:"symbol"`
+	Bids      [][2]float64     `json:"bids"`
+	Asks      [][2]float64     `json:"asks"`
+	Timestamp *ngerest.NGETime `json:"timestamp"`
+}
+
+// SameLevels check if levels in both snapshot are the same
+func (book *OrderBook10) SameLevels(other *OrderBook10) bool {
+	if other == nil {
+		return false
+	}
+
+	return sameLevels(book.Bids, other.Bids) && sameLevels(book.Asks, other.Asks)
+}
+
+func sameLevels(left, right [][2]float64) bool {
+	if len(left) != len(right) {
+		return false
+	}
+
+	for idx := range left {
+		if left[idx] != right[idx] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// OrderBook10Response orderBook10 response structure
+type OrderBook10Response struct {
+	tableResponse
+
+	Data []*OrderBook10 `json:"data"`
+}
+
+// NewOrderBook10Partial make a new orderBook10 partial response
+func NewOrderBook10Partial() *OrderBook10Response {
+	partial := OrderBook10Response{}
+
+	partial.Table = "orderBook10"
+	partial.Action = PartialAction
+	partial.Keys = []string{"symbol"}
+	partial.Types = make(map[string]string)
+	partial.ForeignKeys = make(map[string]string)
+	partial.Attributes = make(map[string]string)
+	partial.Filter = make(map[string]string)
+
+	return &partial
+}
+
+// String get structure's string format
+func (book *OrderBook10Response) String() string {
+	result, _ := json.Marshal(book)
+
+	return string(result)
+}
+
+// Format format String output
+func (book *OrderBook10Response) Format(format string) string {
+	return book.String()
+}
+
+// GetAction get action for response
+func (book *OrderBook10Response) GetAction() string {
+	return book.Action
+}
+
+// GetData get data for reponse
+func (book *OrderBook10Response) GetData() []interface{} {
+	var data []interface{}
+
+	for _, d := range book.Data {
+		data = append(data, d)
+	}
+
+	return data
+}
@@ -13,6 +13,21 @@ type PositionResponse struct {
 	Data []*ngerest.Position `json:"data"`
 }
 
+// NewPositionPartial make a new position partial response
+func NewPositionPartial() *PositionResponse {
+	partial := PositionResponse{}
+
+	partial.Table = "position"
+	partial.Action = PartialAction
+	partial.Keys = []string{}
+	partial.Types = make(map[string]string)
+	partial.ForeignKeys = make(map[string]string)
+	partial.Attributes = make(map[string]string)
+	partial.Filter = make(map[string]string)
+
+	return &partial
+}
+
 func (pos *PositionResponse) String() string {
 	result, _ := json.Marshal(pos)
 
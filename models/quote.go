@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/frozenpine/ngerest"
+)
+
+// QuoteResponse quote & quote bin response structure
+type QuoteResponse struct {
+	tableResponse
+
+	Data []*ngerest.Quote `json:"data"`
+}
+
+// NewQuotePartial make a new quote partial response
+func NewQuotePartial() *QuoteResponse {
+	partial := QuoteResponse{}
+
+	partial.Table = "quote"
+	partial.Action = PartialAction
+	partial.Keys = []string{}
+	partial.Types = make(map[string]string)
+	partial.ForeignKeys = make(map[string]string)
+	partial.Attributes = make(map[string]string)
+	partial.Filter = make(map[string]string)
+
+	return &partial
+}
+
+// String get structure's string format
+func (q *QuoteResponse) String() string {
+	result, _ := json.Marshal(q)
+
+	return string(result)
+}
+
+// Format format String output
+func (q *QuoteResponse) Format(format string) string {
+	return q.String()
+}
+
+// GetAction get action for response
+func (q *QuoteResponse) GetAction() string {
+	return q.Action
+}
+
+// GetData get data for reponse
+func (q *QuoteResponse) GetData() []interface{} {
+	var data []interface{}
+
+	for _, d := range q.Data {
+		data = append(data, d)
+	}
+
+	return data
+}
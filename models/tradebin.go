@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/frozenpine/ngerest"
+)
+
+// TradeBinResponse trade bin response structure
+type TradeBinResponse struct {
+	tableResponse
+
+	Data []*ngerest.TradeBin `json:"data"`
+}
+
+// NewTradeBinPartial make a new trade bin partial response for bin size, e.g. 1m
+func NewTradeBinPartial(binSize string) *TradeBinResponse {
+	partial := TradeBinResponse{}
+
+	partial.Table = "tradeBin" + binSize
+	partial.Action = PartialAction
+	partial.Keys = []string{}
+	partial.Types = make(map[string]string)
+	partial.ForeignKeys = make(map[string]string)
+	partial.Attributes = make(map[string]string)
+	partial.Filter = make(map[string]string)
+
+	return &partial
+}
+
+// String get structure's string format
+func (bin *TradeBinResponse) String() string {
+	result, _ := json.Marshal(bin)
+
+	return string(result)
+}
+
+// Format format String output
+func (bin *TradeBinResponse) Format(format string) string {
+	return bin.String()
+}
+
+// GetAction get action for response
+func (bin *TradeBinResponse) GetAction() string {
+	return bin.Action
+}
+
+// GetData get data for reponse
+func (bin *TradeBinResponse) GetData() []interface{} {
+	var data []interface{}
+
+	for _, d := range bin.Data {
+		data = append(data, d)
+	}
+
+	return data
+}
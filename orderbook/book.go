@@ -0,0 +1,112 @@
+package orderbook
+
+import (
+	"sort"
+
+	"github.com/frozenpine/ngerest"
+)
+
+// priceLevel orders on same price in time priority
+type priceLevel struct {
+	price  float64
+	size   float32
+	orders []*ngerest.Order
+}
+
+func (l *priceLevel) remove(orderID string) *ngerest.Order {
+	for idx, ord := range l.orders {
+		if ord.OrderID == orderID {
+			l.orders = append(l.orders[:idx], l.orders[idx+1:]...)
+			l.size -= ord.LeavesQty
+
+			return ord
+		}
+	}
+
+	return nil
+}
+
+// bookSide price levels of one side, best price first
+type bookSide struct {
+	side   string
+	levels []*priceLevel
+}
+
+// better true if price a has higher priority than b
+func (s *bookSide) better(a, b float64) bool {
+	if s.side == SideBuy {
+		return a > b
+	}
+
+	return a < b
+}
+
+func (s *bookSide) search(price float64) int {
+	return sort.Search(len(s.levels), func(idx int) bool {
+		return !s.better(s.levels[idx].price, price)
+	})
+}
+
+func (s *bookSide) best() *priceLevel {
+	if len(s.levels) < 1 {
+		return nil
+	}
+
+	return s.levels[0]
+}
+
+func (s *bookSide) get(price float64) *priceLevel {
+	if idx := s.search(price); idx < len(s.levels) && s.levels[idx].price == price {
+		return s.levels[idx]
+	}
+
+	return nil
+}
+
+func (s *bookSide) add(ord *ngerest.Order) {
+	idx := s.search(ord.Price)
+
+	if idx >= len(s.levels) || s.levels[idx].price != ord.Price {
+		s.levels = append(s.levels, nil)
+		copy(s.levels[idx+1:], s.levels[idx:])
+		s.levels[idx] = &priceLevel{price: ord.Price}
+	}
+
+	level := s.levels[idx]
+	level.orders = append(level.orders, ord)
+	level.size += ord.LeavesQty
+}
+
+func (s *bookSide) remove(ord *ngerest.Order) bool {
+	idx := s.search(ord.Price)
+
+	if idx >= len(s.levels) || s.levels[idx].price != ord.Price {
+		return false
+	}
+
+	level := s.levels[idx]
+
+	if level.remove(ord.OrderID) == nil {
+		return false
+	}
+
+	if len(level.orders) < 1 {
+		s.levels = append(s.levels[:idx], s.levels[idx+1:]...)
+	}
+
+	return true
+}
+
+func (s *bookSide) popBest() {
+	if len(s.levels) > 0 {
+		s.levels = s.levels[1:]
+	}
+}
+
+func newBookSide(side string) *bookSide {
+	s := bookSide{
+		side: side,
+	}
+
+	return &s
+}
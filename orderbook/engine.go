@@ -0,0 +1,561 @@
+package orderbook
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// DefaultTickSize default price tick size
+	DefaultTickSize = 0.5
+
+	// levelIDBase base for l2 id generation, id = base - price / tick
+	levelIDBase = 8800000000
+)
+
+type levelKey struct {
+	side  string
+	price float64
+}
+
+// Engine price-time priority matching engine for one symbol,
+// book changes & fills will be appended to mbl & trade cache.
+type Engine struct {
+	symbol   string
+	tickSize float64
+
+	lock   sync.Mutex
+	bids   *bookSide
+	asks   *bookSide
+	orders map[string]*ngerest.Order
+
+	lastPrice         float64
+	lastTickDirection string
+
+	// level size before current operation, for generating l2 changes
+	originLevels map[levelKey]float32
+	touchedLevel []levelKey
+
+	mbl   utils.Cache
+	trade utils.Cache
+
+	fillHandler func(fills []*Fill)
+}
+
+// Symbol engine's symbol
+func (e *Engine) Symbol() string {
+	return e.symbol
+}
+
+// TickSize engine's price tick size
+func (e *Engine) TickSize() float64 {
+	return e.tickSize
+}
+
+// BestBid best bid price & size, zero if bid side is empty
+func (e *Engine) BestBid() (float64, float32) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if level := e.bids.best(); level != nil {
+		return level.price, level.size
+	}
+
+	return 0, 0
+}
+
+// BestAsk best ask price & size, zero if ask side is empty
+func (e *Engine) BestAsk() (float64, float32) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if level := e.asks.best(); level != nil {
+		return level.price, level.size
+	}
+
+	return 0, 0
+}
+
+// LastPrice last trade price
+func (e *Engine) LastPrice() float64 {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.lastPrice
+}
+
+// LastTickDirection tick direction of last trade
+func (e *Engine) LastTickDirection() string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.lastTickDirection
+}
+
+// SetFillHandler set handler called with fills of each matching, whoever placed the taker order.
+// Handler is called with engine locked in matching sequence, so it must not call engine's methods.
+func (e *Engine) SetFillHandler(handler func(fills []*Fill)) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.fillHandler = handler
+}
+
+func (e *Engine) getSide(side string) *bookSide {
+	if side == SideBuy {
+		return e.bids
+	}
+
+	return e.asks
+}
+
+func (e *Engine) getOpposite(side string) *bookSide {
+	if side == SideBuy {
+		return e.asks
+	}
+
+	return e.bids
+}
+
+func (e *Engine) levelID(price float64) int {
+	return levelIDBase - int(math.Round(price/e.tickSize))
+}
+
+func (e *Engine) validate(ord *ngerest.Order) error {
+	if ord.Symbol == "" {
+		ord.Symbol = e.symbol
+	} else if ord.Symbol != e.symbol {
+		return ErrInvalidSymbol
+	}
+
+	if ord.Side != SideBuy && ord.Side != SideSell {
+		return ErrInvalidSide
+	}
+
+	if ord.OrderQty <= 0 {
+		return ErrInvalidQty
+	}
+
+	switch ord.OrdType {
+	case "", OrdTypeLimit:
+		ord.OrdType = OrdTypeLimit
+
+		if ord.Price <= 0 {
+			return ErrInvalidPrice
+		}
+
+		ticks := ord.Price / e.tickSize
+		if math.Abs(ticks-math.Round(ticks)) > 1e-9 {
+			return ErrInvalidPrice
+		}
+		ord.Price = math.Round(ticks) * e.tickSize
+
+		if ord.TimeInForce == "" {
+			ord.TimeInForce = TimeInForceGTC
+		}
+	case OrdTypeMarket:
+		ord.Price = 0
+
+		if ord.TimeInForce == "" {
+			ord.TimeInForce = TimeInForceIOC
+		}
+
+		if isPostOnly(ord) {
+			return ErrInvalidExecInst
+		}
+	default:
+		return ErrInvalidOrdType
+	}
+
+	switch ord.TimeInForce {
+	case TimeInForceGTC, TimeInForceIOC:
+	default:
+		return ErrInvalidTimeInForce
+	}
+
+	if ord.ExecInst != "" && !isPostOnly(ord) {
+		return ErrInvalidExecInst
+	}
+
+	return nil
+}
+
+// crossed true if order can match with level price
+func (e *Engine) crossed(ord *ngerest.Order, price float64) bool {
+	switch {
+	case ord.OrdType == OrdTypeMarket:
+		return true
+	case ord.Side == SideBuy:
+		return price <= ord.Price
+	default:
+		return price >= ord.Price
+	}
+}
+
+// touch record level's origin size before modified in current operation
+func (e *Engine) touch(side string, price float64) {
+	key := levelKey{side: side, price: price}
+
+	if _, exist := e.originLevels[key]; exist {
+		return
+	}
+
+	var size float32
+	if level := e.getSide(side).get(price); level != nil {
+		size = level.size
+	}
+
+	e.originLevels[key] = size
+	e.touchedLevel = append(e.touchedLevel, key)
+}
+
+func (e *Engine) tickDirection(price float64) string {
+	var direction string
+
+	switch {
+	case e.lastPrice == 0 || price > e.lastPrice:
+		direction = "PlusTick"
+	case price < e.lastPrice:
+		direction = "MinusTick"
+	case strings.HasPrefix(e.lastTickDirection, "Zero"):
+		direction = e.lastTickDirection
+	default:
+		direction = "Zero" + e.lastTickDirection
+	}
+
+	e.lastPrice = price
+	e.lastTickDirection = direction
+
+	return direction
+}
+
+func (e *Engine) match(taker *ngerest.Order) []*Fill {
+	var fills []*Fill
+
+	opposite := e.getOpposite(taker.Side)
+
+	for taker.LeavesQty > 0 {
+		level := opposite.best()
+
+		if level == nil || !e.crossed(taker, level.price) {
+			break
+		}
+
+		e.touch(opposite.side, level.price)
+
+		for taker.LeavesQty > 0 && len(level.orders) > 0 {
+			maker := level.orders[0]
+
+			size := maker.LeavesQty
+			if taker.LeavesQty < size {
+				size = taker.LeavesQty
+			}
+
+			now := time.Now()
+			ts := timestamp(now)
+
+			applyFill(maker, level.price, size, ts)
+			applyFill(taker, level.price, size, ts)
+			level.size -= size
+
+			fills = append(fills, &Fill{
+				MatchID:   uuid.NewV4().String(),
+				Price:     level.price,
+				Size:      size,
+				Side:      taker.Side,
+				Maker:     *maker,
+				Taker:     *taker,
+				Timestamp: now,
+			})
+
+			if maker.LeavesQty <= 0 {
+				level.orders = level.orders[1:]
+				delete(e.orders, maker.OrderID)
+			}
+		}
+
+		if len(level.orders) < 1 {
+			opposite.popBest()
+		}
+	}
+
+	return fills
+}
+
+// publish append l2 changes & trades in current operation to caches
+func (e *Engine) publish(fills []*Fill) {
+	defer func() {
+		e.originLevels = make(map[levelKey]float32)
+		e.touchedLevel = nil
+	}()
+
+	if len(fills) > 0 {
+		tdRsp := models.TradeResponse{}
+		tdRsp.Table = "trade"
+		tdRsp.Action = models.InsertAction
+
+		for _, fill := range fills {
+			ts := ngerest.NGETime(fill.Timestamp)
+
+			tdRsp.Data = append(tdRsp.Data, &ngerest.Trade{
+				Timestamp:     &ts,
+				Symbol:        e.symbol,
+				Side:          fill.Side,
+				Size:          fill.Size,
+				Price:         fill.Price,
+				TickDirection: e.tickDirection(fill.Price),
+				TrdMatchID:    fill.MatchID,
+			})
+		}
+
+		if e.trade != nil {
+			e.trade.Append(utils.NewCacheInput(&tdRsp))
+		}
+
+		if e.fillHandler != nil {
+			e.fillHandler(fills)
+		}
+	}
+
+	if e.mbl == nil {
+		return
+	}
+
+	var deleteRsp, updateRsp, insertRsp models.MBLResponse
+	deleteRsp.Table, deleteRsp.Action = "orderBookL2", models.DeleteAction
+	updateRsp.Table, updateRsp.Action = "orderBookL2", models.UpdateAction
+	insertRsp.Table, insertRsp.Action = "orderBookL2", models.InsertAction
+
+	for _, key := range e.touchedLevel {
+		originSize := e.originLevels[key]
+
+		l2 := ngerest.OrderBookL2{
+			Symbol: e.symbol,
+			ID:     e.levelID(key.price),
+			Side:   key.side,
+			Price:  key.price,
+		}
+
+		level := e.getSide(key.side).get(key.price)
+
+		switch {
+		case level == nil && originSize > 0:
+			deleteRsp.Data = append(deleteRsp.Data, &l2)
+		case level != nil && originSize <= 0:
+			l2.Size = level.size
+			insertRsp.Data = append(insertRsp.Data, &l2)
+		case level != nil && level.size != originSize:
+			l2.Size = level.size
+			updateRsp.Data = append(updateRsp.Data, &l2)
+		}
+	}
+
+	// delete must be applied before insert,
+	// as mbl cache's levels are indexed by price regardless of side
+	for _, rsp := range []*models.MBLResponse{&deleteRsp, &updateRsp, &insertRsp} {
+		if len(rsp.Data) > 0 {
+			e.mbl.Append(utils.NewCacheInput(rsp))
+		}
+	}
+}
+
+// Place place new order into engine, order will be matched immediately
+// and remaining quantity will rest on book if order is GTC limit order.
+func (e *Engine) Place(order *ngerest.Order) (*Result, error) {
+	ord := *order
+
+	if err := e.validate(&ord); err != nil {
+		return nil, err
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if ord.OrderID == "" {
+		ord.OrderID = uuid.NewV4().String()
+	} else if _, exist := e.orders[ord.OrderID]; exist {
+		return nil, ErrDuplicateOrder
+	}
+
+	ts := timestamp(time.Now())
+
+	ord.OrdStatus = StatusNew
+	ord.LeavesQty = ord.OrderQty
+	ord.CumQty = 0
+	ord.AvgPx = 0
+	ord.Text = ""
+	ord.TransactTime = ts
+	ord.Timestamp = ts
+
+	origin := ord
+
+	if isPostOnly(&ord) {
+		if level := e.getOpposite(ord.Side).best(); level != nil && e.crossed(&ord, level.price) {
+			cancelOrder(&ord, "Canceled: Order had execInst of "+ExecInstPostOnly, ts)
+
+			return &Result{Order: &ord, Origin: &origin}, nil
+		}
+	}
+
+	fills := e.match(&ord)
+
+	if ord.LeavesQty > 0 {
+		if ord.OrdType == OrdTypeMarket || ord.TimeInForce == TimeInForceIOC {
+			cancelOrder(&ord, "Canceled: Order had timeInForce of "+ord.TimeInForce, ts)
+		} else {
+			resting := ord
+			resting.WorkingIndicator = true
+
+			e.touch(resting.Side, resting.Price)
+			e.getSide(resting.Side).add(&resting)
+			e.orders[resting.OrderID] = &resting
+
+			ord = resting
+		}
+	}
+
+	e.publish(fills)
+
+	return &Result{Order: &ord, Origin: &origin, Fills: fills}, nil
+}
+
+// Amend amend resting order's quantity or price, zero value means unchanged,
+// order will lose time priority if price changed or quantity increased.
+func (e *Engine) Amend(orderID string, orderQty float32, price float64) (*Result, error) {
+	if orderQty < 0 {
+		return nil, ErrInvalidQty
+	}
+
+	if price < 0 {
+		return nil, ErrInvalidPrice
+	} else if price > 0 {
+		ticks := price / e.tickSize
+		if math.Abs(ticks-math.Round(ticks)) > 1e-9 {
+			return nil, ErrInvalidPrice
+		}
+		price = math.Round(ticks) * e.tickSize
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	ord, exist := e.orders[orderID]
+	if !exist {
+		return nil, ErrOrderNotFound
+	}
+
+	if orderQty == 0 {
+		orderQty = ord.OrderQty
+	}
+	if price == 0 {
+		price = ord.Price
+	}
+
+	if orderQty <= ord.CumQty {
+		return nil, ErrInvalidQty
+	}
+
+	leavesQty := orderQty - ord.CumQty
+	ts := timestamp(time.Now())
+
+	e.touch(ord.Side, ord.Price)
+
+	if price == ord.Price && leavesQty <= ord.LeavesQty {
+		e.getSide(ord.Side).get(ord.Price).size -= ord.LeavesQty - leavesQty
+
+		ord.OrderQty = orderQty
+		ord.LeavesQty = leavesQty
+		ord.Timestamp = ts
+
+		e.publish(nil)
+
+		amended := *ord
+
+		return &Result{Order: &amended}, nil
+	}
+
+	e.getSide(ord.Side).remove(ord)
+	delete(e.orders, orderID)
+
+	ord.OrderQty = orderQty
+	ord.LeavesQty = leavesQty
+	ord.Price = price
+	ord.Timestamp = ts
+
+	origin := *ord
+
+	var fills []*Fill
+
+	if level := e.getOpposite(ord.Side).best(); isPostOnly(ord) && level != nil && e.crossed(ord, level.price) {
+		cancelOrder(ord, "Canceled: Order had execInst of "+ExecInstPostOnly, ts)
+	} else {
+		fills = e.match(ord)
+	}
+
+	if ord.LeavesQty > 0 {
+		e.touch(ord.Side, ord.Price)
+		e.getSide(ord.Side).add(ord)
+		e.orders[orderID] = ord
+	}
+
+	e.publish(fills)
+
+	amended := *ord
+
+	return &Result{Order: &amended, Origin: &origin, Fills: fills}, nil
+}
+
+// Cancel cancel resting order on book
+func (e *Engine) Cancel(orderID string) (*ngerest.Order, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	ord, exist := e.orders[orderID]
+	if !exist {
+		return nil, ErrOrderNotFound
+	}
+
+	e.touch(ord.Side, ord.Price)
+	e.getSide(ord.Side).remove(ord)
+	delete(e.orders, orderID)
+
+	cancelOrder(ord, "Canceled: Canceled via API.", timestamp(time.Now()))
+
+	e.publish(nil)
+
+	canceled := *ord
+
+	return &canceled, nil
+}
+
+// NewEngine create matching engine for symbol,
+// an empty partial will be appended to mbl cache to initialize book.
+func NewEngine(symbol string, tickSize float64, mbl, trade utils.Cache) *Engine {
+	if tickSize <= 0 {
+		tickSize = DefaultTickSize
+	}
+
+	engine := Engine{
+		symbol:       symbol,
+		tickSize:     tickSize,
+		bids:         newBookSide(SideBuy),
+		asks:         newBookSide(SideSell),
+		orders:       make(map[string]*ngerest.Order),
+		originLevels: make(map[levelKey]float32),
+		mbl:          mbl,
+		trade:        trade,
+	}
+
+	if mbl != nil {
+		mbl.Append(utils.NewCacheInput(models.NewMBLPartial()))
+	}
+
+	return &engine
+}
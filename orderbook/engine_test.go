@@ -0,0 +1,274 @@
+package orderbook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils"
+)
+
+func placeOrder(t *testing.T, engine *Engine, ord *ngerest.Order) *Result {
+	rst, err := engine.Place(ord)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rst
+}
+
+func TestPriceTimePriority(t *testing.T) {
+	engine := NewEngine("XBTUSD", 0, nil, nil)
+
+	first := placeOrder(t, engine, &ngerest.Order{Side: SideSell, OrderQty: 10, Price: 9000})
+	second := placeOrder(t, engine, &ngerest.Order{Side: SideSell, OrderQty: 10, Price: 9000})
+	placeOrder(t, engine, &ngerest.Order{Side: SideSell, OrderQty: 10, Price: 8999.5})
+
+	rst := placeOrder(t, engine, &ngerest.Order{Side: SideBuy, OrderQty: 25, Price: 9000})
+
+	if len(rst.Fills) != 3 {
+		t.Fatalf("fill count miss-match: %d", len(rst.Fills))
+	}
+
+	if rst.Fills[0].Price != 8999.5 {
+		t.Fatal("better price not matched first")
+	}
+
+	if rst.Fills[1].Maker.OrderID != first.Order.OrderID || rst.Fills[1].Size != 10 {
+		t.Fatal("earlier order not matched first")
+	}
+
+	if rst.Fills[2].Maker.OrderID != second.Order.OrderID || rst.Fills[2].Size != 5 {
+		t.Fatal("later order fill miss-match")
+	}
+
+	if rst.Order.OrdStatus != StatusFilled || rst.Order.AvgPx != (8999.5*10+9000*15)/25 {
+		t.Fatalf("taker order status miss-match: %+v", rst.Order)
+	}
+
+	if rst.Origin.OrdStatus != StatusNew || rst.Origin.LeavesQty != rst.Order.OrderQty || rst.Origin.CumQty != 0 {
+		t.Fatalf("taker order origin miss-match: %+v", rst.Origin)
+	}
+
+	if price, size := engine.BestAsk(); price != 9000 || size != 5 {
+		t.Fatalf("best ask miss-match: %.1f@%.0f", price, size)
+	}
+
+	if engine.LastPrice() != 9000 {
+		t.Fatal("last price miss-match")
+	}
+}
+
+func TestOrderTypes(t *testing.T) {
+	engine := NewEngine("XBTUSD", 0, nil, nil)
+
+	placeOrder(t, engine, &ngerest.Order{Side: SideSell, OrderQty: 10, Price: 9000})
+
+	postOnly := placeOrder(t, engine, &ngerest.Order{
+		Side: SideBuy, OrderQty: 10, Price: 9000, ExecInst: ExecInstPostOnly})
+	if postOnly.Order.OrdStatus != StatusCanceled || len(postOnly.Fills) > 0 {
+		t.Fatal("post only order take liquidity")
+	}
+
+	postOnly = placeOrder(t, engine, &ngerest.Order{
+		Side: SideBuy, OrderQty: 10, Price: 8999.5, ExecInst: ExecInstPostOnly})
+	if postOnly.Order.OrdStatus != StatusNew {
+		t.Fatal("post only order not rest on book")
+	}
+
+	ioc := placeOrder(t, engine, &ngerest.Order{
+		Side: SideBuy, OrderQty: 15, Price: 9000, TimeInForce: TimeInForceIOC})
+	if ioc.Order.OrdStatus != StatusCanceled || ioc.Order.CumQty != 10 || ioc.Order.LeavesQty != 0 {
+		t.Fatalf("ioc order remaining not canceled: %+v", ioc.Order)
+	}
+
+	if price, _ := engine.BestBid(); price != 8999.5 {
+		t.Fatal("ioc order rest on book")
+	}
+
+	market := placeOrder(t, engine, &ngerest.Order{Side: SideSell, OrderQty: 20, OrdType: OrdTypeMarket})
+	if market.Order.CumQty != 10 || market.Order.OrdStatus != StatusCanceled {
+		t.Fatalf("market order status miss-match: %+v", market.Order)
+	}
+
+	if price, _ := engine.BestBid(); price != 0 {
+		t.Fatal("bid side not empty")
+	}
+
+	for _, ord := range []*ngerest.Order{
+		{Side: "Invalid", OrderQty: 1, Price: 9000},
+		{Side: SideBuy, OrderQty: 0, Price: 9000},
+		{Side: SideBuy, OrderQty: 1, Price: 9000.3},
+		{Side: SideBuy, OrderQty: 1},
+		{Side: SideBuy, OrderQty: 1, Price: 9000, OrdType: "Stop"},
+		{Side: SideBuy, OrderQty: 1, OrdType: OrdTypeMarket, ExecInst: ExecInstPostOnly},
+		{Symbol: "ETHUSD", Side: SideBuy, OrderQty: 1, Price: 9000},
+	} {
+		if _, err := engine.Place(ord); err == nil {
+			t.Fatalf("invalid order placed: %+v", ord)
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	engine := NewEngine("XBTUSD", 0, nil, nil)
+
+	rst := placeOrder(t, engine, &ngerest.Order{Side: SideBuy, OrderQty: 10, Price: 9000})
+
+	if _, err := engine.Place(&ngerest.Order{
+		OrderID: rst.Order.OrderID, Side: SideBuy, OrderQty: 10, Price: 9000}); err != ErrDuplicateOrder {
+		t.Fatal("duplicate order placed")
+	}
+
+	canceled, err := engine.Cancel(rst.Order.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if canceled.OrdStatus != StatusCanceled || canceled.LeavesQty != 0 {
+		t.Fatal("order status miss-match after cancel")
+	}
+
+	if price, _ := engine.BestBid(); price != 0 {
+		t.Fatal("canceled order still on book")
+	}
+
+	if _, err := engine.Cancel(rst.Order.OrderID); err != ErrOrderNotFound {
+		t.Fatal("cancel order twice")
+	}
+}
+
+func TestAmend(t *testing.T) {
+	engine := NewEngine("XBTUSD", 0, nil, nil)
+
+	first := placeOrder(t, engine, &ngerest.Order{Side: SideBuy, OrderQty: 10, Price: 9000})
+	second := placeOrder(t, engine, &ngerest.Order{Side: SideBuy, OrderQty: 10, Price: 9000})
+
+	// reduce quantity keeps priority
+	amended, err := engine.Amend(first.Order.OrderID, 5, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amended.Order.LeavesQty != 5 {
+		t.Fatal("order quantity not amended")
+	}
+	if _, size := engine.BestBid(); size != 15 {
+		t.Fatalf("level size miss-match after amend: %.0f", size)
+	}
+
+	rst := placeOrder(t, engine, &ngerest.Order{Side: SideSell, OrderQty: 5, Price: 9000})
+	if rst.Fills[0].Maker.OrderID != first.Order.OrderID {
+		t.Fatal("order lost priority after reducing quantity")
+	}
+
+	// amend price across the book makes order a taker
+	placeOrder(t, engine, &ngerest.Order{Side: SideSell, OrderQty: 4, Price: 9001})
+
+	amended, err = engine.Amend(second.Order.OrderID, 0, 9001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(amended.Fills) != 1 || amended.Order.LeavesQty != 6 || amended.Order.Price != 9001 {
+		t.Fatalf("amended order not matched: %+v", amended.Order)
+	}
+	if price, size := engine.BestBid(); price != 9001 || size != 6 {
+		t.Fatalf("best bid miss-match after amend: %.1f@%.0f", price, size)
+	}
+
+	if _, err = engine.Amend(second.Order.OrderID, 4, 0); err != ErrInvalidQty {
+		t.Fatal("order quantity amended below filled quantity")
+	}
+
+	if _, err = engine.Amend(first.Order.OrderID, 10, 0); err != ErrOrderNotFound {
+		t.Fatal("filled order amended")
+	}
+}
+
+func TestFeedCaches(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	mbl := utils.NewMBLCache(ctx, "XBTUSD")
+	td := utils.NewTradeCache(ctx, "XBTUSD")
+
+	engine := NewEngine("XBTUSD", 0, mbl, td)
+
+	for _, ord := range []*ngerest.Order{
+		{Side: SideSell, OrderQty: 10, Price: 9001},
+		{Side: SideSell, OrderQty: 10, Price: 9000.5},
+		{Side: SideBuy, OrderQty: 10, Price: 9000},
+		{Side: SideBuy, OrderQty: 10, Price: 8999.5},
+		// take whole 9000.5 level & rest on it
+		{Side: SideBuy, OrderQty: 15, Price: 9000.5},
+		// partially take 9001 level
+		{Side: SideBuy, OrderQty: 4, Price: 9001},
+		// take whole bid side
+		{Side: SideSell, OrderQty: 25, OrdType: OrdTypeMarket},
+	} {
+		placeOrder(t, engine, ord)
+	}
+
+	rst := placeOrder(t, engine, &ngerest.Order{Side: SideBuy, OrderQty: 3, Price: 8000})
+	placeOrder(t, engine, &ngerest.Order{Side: SideBuy, OrderQty: 3, Price: 8000})
+	if _, err := engine.Cancel(rst.Order.OrderID); err != nil {
+		t.Fatal(err)
+	}
+
+	snap := mbl.TakeSnapshot(0, nil, "").(*models.MBLResponse)
+
+	expect := []ngerest.OrderBookL2{
+		{Side: SideSell, Price: 9001, Size: 6},
+		{Side: SideBuy, Price: 8000, Size: 3},
+	}
+
+	if len(snap.Data) != len(expect) {
+		t.Fatalf("mbl depth miss-match: %s", snap.String())
+	}
+
+	for idx, l2 := range snap.Data {
+		if l2.Side != expect[idx].Side || l2.Price != expect[idx].Price || l2.Size != expect[idx].Size {
+			t.Fatalf("mbl level miss-match: %s", snap.String())
+		}
+
+		if l2.ID != engine.levelID(l2.Price) {
+			t.Fatal("mbl level id miss-match")
+		}
+	}
+
+	mblCache := mbl.(*utils.MBLCache)
+	if mblCache.BestBidPrice() != 8000 || mblCache.BestAskPrice() != 9001 {
+		t.Fatal("mbl cache best quote miss-match")
+	}
+
+	trades := td.TakeSnapshot(0, nil, "").(*models.TradeResponse)
+	if len(trades.Data) != 5 {
+		t.Fatalf("trade count miss-match: %s", trades.String())
+	}
+
+	if last := trades.Data[len(trades.Data)-1]; last.Price != 8999.5 || last.Side != SideSell ||
+		last.TickDirection != "MinusTick" {
+		t.Fatalf("last trade miss-match: %+v", last)
+	}
+}
+
+func TestFillHandler(t *testing.T) {
+	engine := NewEngine("XBTUSD", 0, nil, nil)
+
+	var handled []*Fill
+	engine.SetFillHandler(func(fills []*Fill) {
+		handled = append(handled, fills...)
+	})
+
+	maker := placeOrder(t, engine, &ngerest.Order{Side: SideSell, OrderQty: 10, Price: 9000})
+
+	if len(handled) != 0 {
+		t.Fatal("fill handler called without fills")
+	}
+
+	placeOrder(t, engine, &ngerest.Order{Side: SideBuy, OrderQty: 4, OrdType: OrdTypeMarket})
+
+	if len(handled) != 1 || handled[0].Maker.OrderID != maker.Order.OrderID || handled[0].Maker.CumQty != 4 {
+		t.Fatalf("handled fills miss-match: %+v", handled)
+	}
+}
@@ -0,0 +1,109 @@
+package orderbook
+
+import (
+	"errors"
+	"time"
+
+	"github.com/frozenpine/ngerest"
+)
+
+const (
+	// SideBuy buy side
+	SideBuy = "Buy"
+	// SideSell sell side
+	SideSell = "Sell"
+
+	// OrdTypeLimit limit order, rest on book if not filled
+	OrdTypeLimit = "Limit"
+	// OrdTypeMarket market order, match at any price & never rest on book
+	OrdTypeMarket = "Market"
+
+	// TimeInForceGTC good till cancel
+	TimeInForceGTC = "GoodTillCancel"
+	// TimeInForceIOC immediate or cancel, remaining quantity will be canceled
+	TimeInForceIOC = "ImmediateOrCancel"
+
+	// ExecInstPostOnly post only, order will be canceled if it would take liquidity
+	ExecInstPostOnly = "ParticipateDoNotInitiate"
+
+	// StatusNew order resting on book without fills
+	StatusNew = "New"
+	// StatusPartiallyFilled order partially filled
+	StatusPartiallyFilled = "PartiallyFilled"
+	// StatusFilled order fully filled
+	StatusFilled = "Filled"
+	// StatusCanceled order canceled
+	StatusCanceled = "Canceled"
+)
+
+var (
+	// ErrInvalidSymbol order symbol miss-match with engine
+	ErrInvalidSymbol = errors.New("invalid order symbol")
+	// ErrInvalidSide order side is neither Buy nor Sell
+	ErrInvalidSide = errors.New("invalid order side")
+	// ErrInvalidQty order quantity not positive
+	ErrInvalidQty = errors.New("invalid order quantity")
+	// ErrInvalidPrice limit price not positive or not multiple of tick size
+	ErrInvalidPrice = errors.New("invalid order price")
+	// ErrInvalidOrdType order type not supported
+	ErrInvalidOrdType = errors.New("invalid order type")
+	// ErrInvalidTimeInForce time in force not supported
+	ErrInvalidTimeInForce = errors.New("invalid time in force")
+	// ErrInvalidExecInst exec inst not supported
+	ErrInvalidExecInst = errors.New("invalid exec inst")
+	// ErrDuplicateOrder order id already exist in engine
+	ErrDuplicateOrder = errors.New("duplicate order id")
+	// ErrOrderNotFound order not found on book
+	ErrOrderNotFound = errors.New("order not found")
+)
+
+// Fill match result between resting maker order & incoming taker order
+type Fill struct {
+	MatchID   string
+	Price     float64
+	Size      float32
+	Side      string
+	Maker     ngerest.Order
+	Taker     ngerest.Order
+	Timestamp time.Time
+}
+
+// Result order operation result,
+// Order is a copy of order's state after operation,
+// Origin is a copy of order's state before matching, nil if order not matched in operation.
+type Result struct {
+	Order  *ngerest.Order
+	Origin *ngerest.Order
+	Fills  []*Fill
+}
+
+func isPostOnly(ord *ngerest.Order) bool {
+	return ord.ExecInst == ExecInstPostOnly
+}
+
+func applyFill(ord *ngerest.Order, price float64, size float32, ts int64) {
+	ord.AvgPx = (ord.AvgPx*float64(ord.CumQty) + price*float64(size)) / float64(ord.CumQty+size)
+	ord.CumQty += size
+	ord.LeavesQty -= size
+	ord.Timestamp = ts
+
+	if ord.LeavesQty > 0 {
+		ord.OrdStatus = StatusPartiallyFilled
+	} else {
+		ord.OrdStatus = StatusFilled
+		ord.WorkingIndicator = false
+	}
+}
+
+func cancelOrder(ord *ngerest.Order, text string, ts int64) {
+	ord.LeavesQty = 0
+	ord.OrdStatus = StatusCanceled
+	ord.WorkingIndicator = false
+	ord.Text = text
+	ord.Timestamp = ts
+}
+
+// timestamp order timestamp in milliseconds
+func timestamp(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
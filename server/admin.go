@@ -0,0 +1,272 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frozenpine/wstester/utils"
+	"github.com/frozenpine/wstester/utils/log"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	adminSessionsURI = "/admin/sessions"
+	adminCachesURI   = "/admin/caches"
+	adminReplayURI   = "/admin/replay"
+	adminChaosSuffix = "/chaos"
+)
+
+// ErrAdminForbidden admin api requested without valid admin key
+var ErrAdminForbidden = errors.New("admin api forbidden")
+
+// adminAuth check admin key in admin-key header before calling admin handler,
+// admin api is only allowed from loopback address if no admin key configured.
+func (s *server) adminAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		allowed := false
+
+		if key := s.cfg.Load().AdminKey; key != "" {
+			allowed = subtle.ConstantTimeCompare([]byte(r.Header.Get("admin-key")), []byte(key)) == 1
+		} else if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			ip := net.ParseIP(host)
+			allowed = ip != nil && ip.IsLoopback()
+		}
+
+		if !allowed {
+			log.Warnf("Admin request %s %s from %s forbidden.", r.Method, r.URL.Path, r.RemoteAddr)
+
+			writeHTTPError(w, http.StatusForbidden, ErrAdminForbidden, nil)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// isValidCloseCode check if code can be sent in close frame,
+// reserved codes 1004, 1005, 1006 & 1015 and unassigned codes are invalid.
+func isValidCloseCode(code int) bool {
+	switch {
+	case code >= websocket.CloseNormalClosure && code <= websocket.CloseUnsupportedData:
+		return true
+	case code >= websocket.CloseInvalidFramePayloadData && code <= websocket.CloseTryAgainLater:
+		return true
+	default:
+		return code >= 3000 && code <= 4999
+	}
+}
+
+func writeJSONResult(w http.ResponseWriter, result interface{}) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	w.Write(data)
+}
+
+func (s *server) getSession(id string) Session {
+	s.clientLock.RLock()
+	defer s.clientLock.RUnlock()
+
+	return s.clients[id]
+}
+
+// sessionsHandler list sessions on GET /admin/sessions,
+// get session on GET /admin/sessions/{id},
+// close session on DELETE /admin/sessions/{id}?code={code}&reason={reason},
+// session's chaos config is handled on /admin/sessions/{id}/chaos
+func (s *server) sessionsHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, adminSessionsURI), "/")
+
+	if strings.HasSuffix(id, adminChaosSuffix) {
+		s.chaosHandler(w, r, strings.TrimSuffix(id, adminChaosSuffix))
+		return
+	}
+
+	if id == "" {
+		if r.Method != http.MethodGet {
+			writeHTTPError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"), nil)
+			return
+		}
+
+		s.clientLock.RLock()
+		statusList := make([]*SessionStatus, 0, len(s.clients))
+		for _, session := range s.clients {
+			statusList = append(statusList, session.GetStatus())
+		}
+		s.clientLock.RUnlock()
+
+		sort.Slice(statusList, func(i, j int) bool {
+			return statusList[i].ConnectTime.Before(statusList[j].ConnectTime)
+		})
+
+		writeJSONResult(w, statusList)
+
+		return
+	}
+
+	session := s.getSession(id)
+	if session == nil {
+		writeHTTPError(w, http.StatusNotFound, errors.New("session not found: "+id), nil)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSONResult(w, session.GetStatus())
+	case http.MethodDelete:
+		code := websocket.CloseNormalClosure
+
+		if codeStr := r.URL.Query().Get("code"); codeStr != "" {
+			var err error
+
+			if code, err = strconv.Atoi(codeStr); err != nil || !isValidCloseCode(code) {
+				writeHTTPError(w, http.StatusBadRequest, errors.New("invalid close code: "+codeStr), nil)
+				return
+			}
+		}
+
+		reason := r.URL.Query().Get("reason")
+		if reason == "" {
+			reason = "Session closed by admin."
+		}
+
+		log.Warnf("Client session[%s] closed by admin request from %s.", id, r.RemoteAddr)
+
+		session.Close(code, reason)
+
+		writeJSONResult(w, map[string]interface{}{"success": true, "id": id})
+	default:
+		writeHTTPError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"), nil)
+	}
+}
+
+// chaosHandler get session's chaos config on GET /admin/sessions/{id}/chaos,
+// set chaos config with json body on PUT or POST, disable fault injection on DELETE.
+// Fault window of new chaos config starts from the time it's applied.
+func (s *server) chaosHandler(w http.ResponseWriter, r *http.Request, id string) {
+	session := s.getSession(id)
+	if session == nil {
+		writeHTTPError(w, http.StatusNotFound, errors.New("session not found: "+id), nil)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		cfg := NewChaosConfig()
+
+		if err := json.NewDecoder(r.Body).Decode(cfg); err != nil {
+			writeHTTPError(w, http.StatusBadRequest, err, nil)
+			return
+		}
+
+		if err := session.SetChaos(cfg); err != nil {
+			writeHTTPError(w, http.StatusBadRequest, err, nil)
+			return
+		}
+
+		log.Warnf("Client session[%s] chaos config changed by admin request from %s.", id, r.RemoteAddr)
+	case http.MethodDelete:
+		session.SetChaos(nil)
+
+		log.Infof("Client session[%s] chaos disabled by admin request from %s.", id, r.RemoteAddr)
+	default:
+		writeHTTPError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"), nil)
+		return
+	}
+
+	writeJSONResult(w, map[string]interface{}{"id": id, "chaos": session.GetChaos()})
+}
+
+// cachesHandler list caches' status on GET /admin/caches?table={table}&symbol={symbol},
+// empty table or symbol means all.
+func (s *server) cachesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeHTTPError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"), nil)
+		return
+	}
+
+	tables := s.dataCaches.GetTables()
+	if table := r.URL.Query().Get("table"); table != "" {
+		tables = []string{table}
+	}
+	symbol := r.URL.Query().Get("symbol")
+
+	// same cache may be registered in multiple tables
+	visited := make(map[utils.Cache]bool)
+	statusList := []*utils.CacheStatus{}
+
+	for _, table := range tables {
+		for _, cache := range s.dataCaches.GetCaches(table, symbol) {
+			if visited[cache] {
+				continue
+			}
+			visited[cache] = true
+
+			statusList = append(statusList, cache.Status())
+		}
+	}
+
+	writeJSONResult(w, statusList)
+}
+
+// replayHandler get replay status on GET /admin/replay,
+// control replay on POST /admin/replay?action={pause|resume|seek|speed}&offset={duration}&speed={speed},
+// replay status after control will be returned.
+func (s *server) replayHandler(w http.ResponseWriter, r *http.Request) {
+	if s.replay == nil {
+		writeHTTPError(w, http.StatusServiceUnavailable, errors.New("replay mock mode not enabled"), nil)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			writeHTTPError(w, http.StatusBadRequest, err, nil)
+			return
+		}
+
+		switch action := r.Form.Get("action"); action {
+		case "pause":
+			s.replay.Pause()
+		case "resume":
+			s.replay.Resume()
+		case "seek":
+			offset, err := time.ParseDuration(r.Form.Get("offset"))
+			if err != nil || offset < 0 {
+				writeHTTPError(w, http.StatusBadRequest, errors.New("invalid offset: "+r.Form.Get("offset")), nil)
+				return
+			}
+
+			s.replay.Seek(offset)
+		case "speed":
+			speed, err := strconv.ParseFloat(r.Form.Get("speed"), 64)
+			if err != nil || speed < 0 {
+				writeHTTPError(w, http.StatusBadRequest, errors.New("invalid speed: "+r.Form.Get("speed")), nil)
+				return
+			}
+
+			s.replay.SetSpeed(speed)
+		default:
+			writeHTTPError(w, http.StatusBadRequest, errors.New("invalid action: "+action), nil)
+			return
+		}
+	default:
+		writeHTTPError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"), nil)
+		return
+	}
+
+	writeJSONResult(w, s.replay.Status())
+}
@@ -0,0 +1,268 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frozenpine/wstester/mock"
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils"
+	"github.com/gorilla/websocket"
+)
+
+func TestAdminSessions(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	conn := dialTestServer(t, httpSvr)
+	defer conn.Close()
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribe", Args: []string{"trade:XBTUSD"}})
+	readTestMessage(t, conn, `"subscribe":"trade:XBTUSD"`)
+	readTestMessage(t, conn, `"action":"partial"`)
+
+	w := httptest.NewRecorder()
+	svr.sessionsHandler(w, httptest.NewRequest(http.MethodGet, adminSessionsURI, nil))
+
+	var statusList []*SessionStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &statusList); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+
+	if len(statusList) != 1 {
+		t.Fatal("session count miss-match:", w.Body.String())
+	}
+
+	status := statusList[0]
+	if len(status.Subscribed) != 1 || status.Subscribed[0] != "trade:XBTUSD" || status.BytesSent <= 0 {
+		t.Fatal("session status miss-match:", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	svr.sessionsHandler(w, httptest.NewRequest(http.MethodDelete, adminSessionsURI+"/not-exist", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatal("close not exist session should be not found:", w.Code)
+	}
+
+	// reserved codes can not be sent in close frame
+	for _, code := range []string{"1005", "1006", "1015", "2000"} {
+		w = httptest.NewRecorder()
+		svr.sessionsHandler(w, httptest.NewRequest(
+			http.MethodDelete, adminSessionsURI+"/"+status.ID+"?code="+code, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("close code %s should be rejected: %d", code, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	svr.sessionsHandler(w, httptest.NewRequest(
+		http.MethodDelete, adminSessionsURI+"/"+status.ID+"?code=4001&reason=kicked", nil))
+	if w.Code != http.StatusOK {
+		t.Fatal("close session failed:", w.Body.String())
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second * 3))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if !websocket.IsCloseError(err, 4001) {
+				t.Fatal("close code miss-match:", err)
+			}
+
+			break
+		}
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	handler := svr.adminAuth(svr.sessionsHandler)
+
+	request := func(remote, key string) int {
+		r := httptest.NewRequest(http.MethodGet, adminSessionsURI, nil)
+		r.RemoteAddr = remote
+		if key != "" {
+			r.Header.Set("admin-key", key)
+		}
+
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		return w.Code
+	}
+
+	if code := request("127.0.0.1:1234", ""); code != http.StatusOK {
+		t.Fatal("loopback admin request rejected without admin key configured:", code)
+	}
+
+	if code := request("10.0.0.1:1234", ""); code != http.StatusForbidden {
+		t.Fatal("remote admin request allowed without admin key configured:", code)
+	}
+
+	cfg := *svr.cfg.Load()
+	cfg.AdminKey = "adminKey"
+	svr.cfg.Store(&cfg)
+
+	if code := request("127.0.0.1:1234", ""); code != http.StatusForbidden {
+		t.Fatal("admin request allowed without admin key:", code)
+	}
+
+	if code := request("10.0.0.1:1234", "wrongKey"); code != http.StatusForbidden {
+		t.Fatal("admin request allowed with wrong admin key:", code)
+	}
+
+	if code := request("10.0.0.1:1234", "adminKey"); code != http.StatusOK {
+		t.Fatal("admin request with admin key rejected:", code)
+	}
+}
+
+func TestAdminSessionChaos(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	conn := dialTestServer(t, httpSvr)
+	defer conn.Close()
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribe", Args: []string{"trade:XBTUSD"}})
+	readTestMessage(t, conn, `"subscribe":"trade:XBTUSD"`)
+	readTestMessage(t, conn, `"action":"partial"`)
+
+	var id string
+	svr.clientLock.RLock()
+	for id = range svr.clients {
+	}
+	svr.clientLock.RUnlock()
+
+	chaosURI := adminSessionsURI + "/" + id + adminChaosSuffix
+
+	w := httptest.NewRecorder()
+	svr.sessionsHandler(w, httptest.NewRequest(http.MethodPut, chaosURI, strings.NewReader(`{"drop": 2}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatal("invalid chaos config should be bad request:", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	svr.sessionsHandler(w, httptest.NewRequest(http.MethodPut, chaosURI, strings.NewReader(`{"drop": 1}`)))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"drop":1`) {
+		t.Fatal("set chaos config failed:", w.Body.String())
+	}
+
+	dropped := chaosFaults[FaultDrop].Value()
+
+	cache := svr.dataCaches.GetCache("trade", "XBTUSD")
+	cache.Append(utils.NewCacheInput(newTestTrade(9000)))
+
+	for deadline := time.Now().Add(time.Second * 3); chaosFaults[FaultDrop].Value() == dropped; {
+		if time.Now().After(deadline) {
+			t.Fatal("trade not dropped by chaos")
+		}
+
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	w = httptest.NewRecorder()
+	svr.sessionsHandler(w, httptest.NewRequest(http.MethodDelete, chaosURI, nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"chaos":null`) {
+		t.Fatal("disable chaos failed:", w.Body.String())
+	}
+
+	cache.Append(utils.NewCacheInput(newTestTrade(9001)))
+	readTestMessage(t, conn, `"price":9001`)
+}
+
+func TestAdminCaches(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	mbl := utils.NewMBLCache(ctx, "XBTUSD")
+	svr.dataCaches.Register("orderBookL2", "XBTUSD", mbl)
+	svr.dataCaches.Register("orderBookL2_25", "XBTUSD", mbl)
+
+	svr.dataCaches.GetCache("trade", "XBTUSD").Append(utils.NewCacheInput(newTestTrade(9000)))
+
+	w := httptest.NewRecorder()
+	svr.cachesHandler(w, httptest.NewRequest(http.MethodGet, adminCachesURI, nil))
+
+	var statusList []*utils.CacheStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &statusList); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+
+	if len(statusList) != 2 {
+		t.Fatal("cache count miss-match:", w.Body.String())
+	}
+
+	for _, status := range statusList {
+		switch status.Table {
+		case "trade":
+			if status.Detail["historyLength"].(float64) != 1 || status.Detail["lastPrice"].(float64) != 9000 {
+				t.Fatal("trade cache status miss-match:", w.Body.String())
+			}
+		case "orderBookL2":
+			if _, exist := status.Detail["bestBidPrice"]; !exist {
+				t.Fatal("mbl cache status miss-match:", w.Body.String())
+			}
+		default:
+			t.Fatal("unexpected cache:", status.Table)
+		}
+	}
+}
+
+func TestAdminReplay(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	w := httptest.NewRecorder()
+	svr.replayHandler(w, httptest.NewRequest(http.MethodGet, adminReplayURI, nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatal("replay status returned without replay mock mode:", w.Code)
+	}
+
+	svr.replay = mock.NewReplay(svr.cfg.Load().Replay, nil)
+
+	for _, query := range []string{"action=pause", "action=speed&speed=2"} {
+		w = httptest.NewRecorder()
+		svr.replayHandler(w, httptest.NewRequest(http.MethodPost, adminReplayURI+"?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatal("replay control failed:", query, w.Body.String())
+		}
+	}
+
+	var status mock.ReplayStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+
+	if !status.Paused || status.Speed != 2 {
+		t.Fatal("replay status miss-match:", w.Body.String())
+	}
+
+	for _, query := range []string{"action=seek&offset=abc", "action=speed&speed=-1", "action=stop"} {
+		w = httptest.NewRecorder()
+		svr.replayHandler(w, httptest.NewRequest(http.MethodPost, adminReplayURI+"?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Fatal("invalid replay control accepted:", query, w.Code)
+		}
+	}
+}
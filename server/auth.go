@@ -0,0 +1,140 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/frozenpine/wstester/utils"
+)
+
+// APIKey api key & secret pair with it's owner identity
+type APIKey struct {
+	Key       string `json:"key"`
+	Secret    string `json:"secret"`
+	ClientID  string `json:"clientId"`
+	AccountID string `json:"accountId"`
+}
+
+// KeyStore local api key store for authentication
+type KeyStore interface {
+	// GetKey get api key pair by key string, nil returned if key not exist
+	GetKey(key string) *APIKey
+	// AddKey add or replace api key pair in store
+	AddKey(key *APIKey)
+}
+
+type keyStore struct {
+	keys map[string]*APIKey
+	lock sync.RWMutex
+}
+
+func (s *keyStore) GetKey(key string) *APIKey {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.keys[key]
+}
+
+func (s *keyStore) AddKey(key *APIKey) {
+	if key == nil || key.Key == "" {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.keys[key.Key] = key
+}
+
+// NewKeyStore create an empty key store
+func NewKeyStore() KeyStore {
+	store := keyStore{
+		keys: make(map[string]*APIKey),
+	}
+
+	return &store
+}
+
+// LoadKeyStore load key store from json file, file content is a list of APIKey
+func LoadKeyStore(path string) (KeyStore, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*APIKey
+
+	if err = json.Unmarshal(content, &keys); err != nil {
+		return nil, fmt.Errorf("invalid key store file[%s]: %v", path, err)
+	}
+
+	store := NewKeyStore()
+
+	for _, key := range keys {
+		store.AddKey(key)
+	}
+
+	return store, nil
+}
+
+func getValidKey(store KeyStore, key string, expires int64) (*APIKey, error) {
+	if store == nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	apiKey := store.GetKey(key)
+	if apiKey == nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if now := time.Now().Unix(); expires < now {
+		return nil, NewAPIExpires(expires)
+	}
+
+	return apiKey, nil
+}
+
+// CheckSignature check api signature generated by utils.GenerateSignature,
+// signature is valid if it signed on one of given uris with GET method.
+func CheckSignature(store KeyStore, key, signature string, expires int64, uris ...string) (*APIKey, error) {
+	apiKey, err := getValidKey(store, key, expires)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, uri := range uris {
+		expected := utils.GenerateSignature(
+			apiKey.Secret, "GET", &url.URL{Path: uri}, int(expires), nil)
+
+		if hmac.Equal([]byte(expected), []byte(signature)) {
+			return apiKey, nil
+		}
+	}
+
+	return nil, ErrInvalidSignature
+}
+
+// CheckRequestSignature check rest request's api signature generated by utils.GenerateSignature,
+// signature is signed on request method, uri with query and body.
+func CheckRequestSignature(
+	store KeyStore, key, signature string, expires int64, method string, uri *url.URL, body []byte) (*APIKey, error) {
+	apiKey, err := getValidKey(store, key, expires)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := utils.GenerateSignature(
+		apiKey.Secret, method, uri, int(expires), bytes.NewBuffer(body))
+
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, ErrInvalidSignature
+	}
+
+	return apiKey, nil
+}
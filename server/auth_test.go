@@ -0,0 +1,119 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils"
+)
+
+func TestCheckSignature(t *testing.T) {
+	store := NewKeyStore()
+	store.AddKey(&APIKey{
+		Key:       "testKey",
+		Secret:    "testSecret",
+		ClientID:  "1",
+		AccountID: "2",
+	})
+
+	expires := time.Now().Unix() + 5
+	signature := utils.GenerateSignature(
+		"testSecret", "get", &url.URL{Path: defaultSignatureURI}, int(expires), nil)
+
+	key, err := CheckSignature(store, "testKey", signature, expires, defaultSignatureURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.ClientID != "1" || key.AccountID != "2" {
+		t.Fatal("key identity miss-match:", key)
+	}
+
+	if _, err = CheckSignature(store, "invalidKey", signature, expires, defaultSignatureURI); err != ErrInvalidAPIKey {
+		t.Fatal("invalid key check failed:", err)
+	}
+
+	if _, err = CheckSignature(store, "testKey", signature, expires, defaultBaseURI); err != ErrInvalidSignature {
+		t.Fatal("invalid uri check failed:", err)
+	}
+
+	expired := time.Now().Unix() - 5
+	signature = utils.GenerateSignature(
+		"testSecret", "GET", &url.URL{Path: defaultSignatureURI}, int(expired), nil)
+
+	if _, err = CheckSignature(store, "testKey", signature, expired, defaultSignatureURI); err == nil {
+		t.Fatal("expired signature check failed")
+	} else if _, ok := err.(*ErrAPIExpires); !ok {
+		t.Fatal("expired signature check failed:", err)
+	}
+}
+
+func TestLoadKeyStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wstester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keys, _ := json.Marshal([]*APIKey{
+		{Key: "key1", Secret: "secret1", ClientID: "c1", AccountID: "a1"},
+		{Key: "key2", Secret: "secret2", ClientID: "c2", AccountID: "a2"},
+	})
+
+	path := filepath.Join(dir, "keys.json")
+	if err = ioutil.WriteFile(path, keys, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := LoadKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key := store.GetKey("key2"); key == nil || key.Secret != "secret2" {
+		t.Fatal("load key store failed:", key)
+	}
+}
+
+func TestAuthArgs(t *testing.T) {
+	req := models.OperationRequest{}
+
+	if err := json.Unmarshal(
+		[]byte(`{"op":"authKeyExpires","args":["testKey",1573012345,"signature"]}`), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(req.Args) != 3 || req.Args[1] != "1573012345" {
+		t.Fatal("parse mixed args failed:", req.Args)
+	}
+}
+
+func TestCheckRequestSignature(t *testing.T) {
+	store := NewKeyStore()
+	store.AddKey(&APIKey{Key: "testKey", Secret: "testSecret"})
+
+	expires := time.Now().Unix() + 5
+	uri := &url.URL{Path: "/api/v1/order", RawQuery: "symbol=XBTUSD"}
+	body := []byte("side=Buy&orderQty=1")
+
+	signature := utils.GenerateSignature("testSecret", "POST", uri, int(expires), bytes.NewBuffer(body))
+
+	if _, err := CheckRequestSignature(store, "testKey", signature, expires, "POST", uri, body); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CheckRequestSignature(
+		store, "testKey", signature, expires, "POST", uri, []byte("side=Sell&orderQty=1")); err != ErrInvalidSignature {
+		t.Fatal("modified body check failed:", err)
+	}
+
+	if _, err := CheckRequestSignature(store, "testKey", signature, expires, "PUT", uri, body); err != ErrInvalidSignature {
+		t.Fatal("modified method check failed:", err)
+	}
+}
@@ -0,0 +1,356 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils"
+	"github.com/frozenpine/wstester/utils/log"
+	"github.com/frozenpine/wstester/utils/metrics"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// FaultDrop drop table data message
+	FaultDrop = "drop"
+	// FaultDuplicate send table data message twice
+	FaultDuplicate = "duplicate"
+	// FaultDelay delay table data message for random duration up to delay max
+	FaultDelay = "delay"
+	// FaultReorder hold table data message and send it after the next one
+	FaultReorder = "reorder"
+	// FaultMalformed send table data message as truncated json
+	FaultMalformed = "malformed"
+	// FaultSkipPartial drop partial of subscribed topic
+	FaultSkipPartial = "skip_partial"
+	// FaultStopHeartbeat stop heartbeat replies for the rest of session
+	FaultStopHeartbeat = "stop_heartbeat"
+	// FaultClose close session with random code in close codes
+	FaultClose = "close"
+
+	defaultChaosDelayMax = 1000
+)
+
+var (
+	defaultChaosCloseCodes = []int{
+		websocket.CloseAbnormalClosure, websocket.CloseInternalServerErr, websocket.CloseServiceRestart}
+
+	chaosFaults = func() map[string]*metrics.Counter {
+		counters := make(map[string]*metrics.Counter)
+
+		for _, fault := range []string{
+			FaultDrop, FaultDuplicate, FaultDelay, FaultReorder,
+			FaultMalformed, FaultSkipPartial, FaultStopHeartbeat, FaultClose,
+		} {
+			counters[fault] = metrics.DefaultRegistry.Counter(
+				"wstester_chaos_faults_total", "Faults injected by chaos mode.", metrics.Labels{"fault": fault})
+		}
+
+		return counters
+	}()
+)
+
+// ChaosConfig fault injection config, probabilities in [0, 1] are checked on each table data message,
+// or on each heartbeat for stop_heartbeat. Faults are injected only in schedule window
+// relative to session connect time (or time config applied to session).
+type ChaosConfig struct {
+	Drop          float64 `toml:"drop" json:"drop,omitempty"`
+	Duplicate     float64 `toml:"duplicate" json:"duplicate,omitempty"`
+	Delay         float64 `toml:"delay" json:"delay,omitempty"`
+	Reorder       float64 `toml:"reorder" json:"reorder,omitempty"`
+	Malformed     float64 `toml:"malformed" json:"malformed,omitempty"`
+	SkipPartial   float64 `toml:"skip_partial" json:"skipPartial,omitempty"`
+	StopHeartbeat float64 `toml:"stop_heartbeat" json:"stopHeartbeat,omitempty"`
+	Close         float64 `toml:"close" json:"close,omitempty"`
+
+	// DelayMax max delay in milliseconds for delay fault
+	DelayMax int `toml:"delay_max" json:"delayMax"`
+	// CloseCodes close codes for close fault, 1005, 1006 & 1015 close connection without close frame
+	CloseCodes []int `toml:"close_codes" json:"closeCodes"`
+
+	// Start seconds after session connected before faults injected
+	Start int `toml:"start" json:"start,omitempty"`
+	// Duration seconds of fault window, 0 means forever
+	Duration int `toml:"duration" json:"duration,omitempty"`
+	// Period seconds to repeat fault window, 0 means no repeat
+	Period int `toml:"period" json:"period,omitempty"`
+
+	// Seed random seed for reproducible faults, 0 means random
+	Seed int64 `toml:"seed" json:"seed,omitempty"`
+}
+
+// Validate check chaos config
+func (c *ChaosConfig) Validate() error {
+	for fault, probability := range c.probabilities() {
+		if probability < 0 || probability > 1 {
+			return fmt.Errorf("invalid chaos probability for %s: %v", fault, probability)
+		}
+	}
+
+	if c.DelayMax < 0 {
+		return errors.New("chaos delay max can not be negative")
+	}
+
+	for _, code := range c.CloseCodes {
+		if code < websocket.CloseNormalClosure || code > 4999 {
+			return fmt.Errorf("invalid chaos close code: %d", code)
+		}
+	}
+
+	if c.Close > 0 && len(c.CloseCodes) < 1 {
+		return errors.New("no chaos close code configured")
+	}
+
+	if c.Start < 0 || c.Duration < 0 || c.Period < 0 {
+		return errors.New("chaos schedule can not be negative")
+	}
+
+	if c.Period > 0 && c.Period < c.Duration {
+		return errors.New("chaos period must be longer than duration")
+	}
+
+	return nil
+}
+
+// IsEnabled check if any fault may be injected
+func (c *ChaosConfig) IsEnabled() bool {
+	for _, probability := range c.probabilities() {
+		if probability > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *ChaosConfig) probabilities() map[string]float64 {
+	return map[string]float64{
+		FaultDrop:          c.Drop,
+		FaultDuplicate:     c.Duplicate,
+		FaultDelay:         c.Delay,
+		FaultReorder:       c.Reorder,
+		FaultMalformed:     c.Malformed,
+		FaultSkipPartial:   c.SkipPartial,
+		FaultStopHeartbeat: c.StopHeartbeat,
+		FaultClose:         c.Close,
+	}
+}
+
+// NewChaosConfig create chaos config with all faults disabled
+func NewChaosConfig() *ChaosConfig {
+	cfg := ChaosConfig{
+		DelayMax:   defaultChaosDelayMax,
+		CloseCodes: append([]int{}, defaultChaosCloseCodes...),
+	}
+
+	return &cfg
+}
+
+// chaosInjector fault injector for one session
+type chaosInjector struct {
+	cfg   *ChaosConfig
+	since time.Time
+
+	lock sync.Mutex
+	rand *rand.Rand
+	// held messages reordered after next table data message
+	held []*message
+	// hbStopped heartbeat replies stopped by stop_heartbeat fault
+	hbStopped bool
+}
+
+// isActive check if time is in fault window
+func (i *chaosInjector) isActive(now time.Time) bool {
+	elapsed := now.Sub(i.since) - time.Duration(i.cfg.Start)*time.Second
+
+	if elapsed < 0 {
+		return false
+	}
+
+	if i.cfg.Duration == 0 {
+		return true
+	}
+
+	duration := time.Duration(i.cfg.Duration) * time.Second
+
+	if i.cfg.Period > 0 {
+		elapsed %= time.Duration(i.cfg.Period) * time.Second
+	}
+
+	return elapsed < duration
+}
+
+// hit roll for fault by probability in fault window
+func (i *chaosInjector) hit(fault string, probability float64) bool {
+	if probability <= 0 || !i.isActive(time.Now()) {
+		return false
+	}
+
+	i.lock.Lock()
+	hit := i.rand.Float64() < probability
+	i.lock.Unlock()
+
+	if hit {
+		chaosFaults[fault].Inc()
+	}
+
+	return hit
+}
+
+func (i *chaosInjector) delay() time.Duration {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	return time.Duration(i.rand.Intn(i.cfg.DelayMax+1)) * time.Millisecond
+}
+
+func (i *chaosInjector) closeCode() int {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	return i.cfg.CloseCodes[i.rand.Intn(len(i.cfg.CloseCodes))]
+}
+
+// stopHeartbeat check if heartbeat replies stopped, once stopped never resumed
+func (i *chaosInjector) stopHeartbeat() bool {
+	i.lock.Lock()
+	stopped := i.hbStopped
+	i.lock.Unlock()
+
+	if stopped {
+		return true
+	}
+
+	if i.hit(FaultStopHeartbeat, i.cfg.StopHeartbeat) {
+		i.lock.Lock()
+		i.hbStopped = true
+		i.lock.Unlock()
+
+		return true
+	}
+
+	return false
+}
+
+// inject apply faults to table data message, return messages to send in order,
+// sc is used for delaying & closing session.
+func (i *chaosInjector) inject(msg *message, sc *clientSession) []*message {
+	rsp, isTable := msg.json.(models.TableResponse)
+
+	// sync messages are operation responses & welcome
+	if !isTable || msg.errChan != nil {
+		return []*message{msg}
+	}
+
+	if rsp.GetAction() == models.PartialAction {
+		if i.hit(FaultSkipPartial, i.cfg.SkipPartial) {
+			log.Warnf("Chaos skip partial for client session[%s].", sc.GetID())
+
+			return nil
+		}
+
+		return []*message{msg}
+	}
+
+	if i.hit(FaultClose, i.cfg.Close) {
+		code := i.closeCode()
+
+		log.Warnf("Chaos close client session[%s] with code[%d].", sc.GetID(), code)
+
+		switch code {
+		case websocket.CloseNoStatusReceived, websocket.CloseAbnormalClosure, websocket.CloseTLSHandshake:
+			// reserved codes can not be sent in close frame
+			sc.Close(-1, "Chaos abnormal close.")
+		default:
+			sc.Close(code, "Chaos abnormal close.")
+		}
+
+		return nil
+	}
+
+	if i.hit(FaultDrop, i.cfg.Drop) {
+		return nil
+	}
+
+	if i.hit(FaultDelay, i.cfg.Delay) {
+		select {
+		case <-sc.ctx.Done():
+			return nil
+		case <-time.After(i.delay()):
+		}
+	}
+
+	if i.hit(FaultMalformed, i.cfg.Malformed) {
+		msg = malformMessage(msg)
+	}
+
+	msgs := []*message{msg}
+
+	if i.hit(FaultDuplicate, i.cfg.Duplicate) {
+		msgs = append(msgs, msg)
+	}
+
+	i.lock.Lock()
+	held := i.held
+	i.held = nil
+	i.lock.Unlock()
+
+	if len(held) > 0 {
+		return append(msgs, held...)
+	}
+
+	if i.hit(FaultReorder, i.cfg.Reorder) {
+		i.lock.Lock()
+		i.held = msgs
+		i.lock.Unlock()
+
+		return nil
+	}
+
+	return msgs
+}
+
+// malformMessage make message with truncated json of origin message
+func malformMessage(msg *message) *message {
+	var (
+		data []byte
+		err  error
+	)
+
+	if shared, ok := msg.json.(*utils.SharedResponse); ok {
+		data, err = shared.Bytes()
+	} else {
+		data, err = json.Marshal(msg.json)
+	}
+
+	if err != nil || len(data) < 2 {
+		return msg
+	}
+
+	return &message{txt: string(data[:len(data)/2]), topic: msg.topic}
+}
+
+// newChaosInjector create injector with chaos config, nil if no fault enabled
+func newChaosInjector(cfg *ChaosConfig) *chaosInjector {
+	if cfg == nil || !cfg.IsEnabled() {
+		return nil
+	}
+
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	injector := chaosInjector{
+		cfg:   cfg,
+		since: time.Now(),
+		rand:  rand.New(rand.NewSource(seed)),
+	}
+
+	return &injector
+}
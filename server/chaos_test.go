@@ -0,0 +1,230 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/frozenpine/wstester/models"
+	"github.com/gorilla/websocket"
+)
+
+func newChaosSession(t *testing.T, chaos *ChaosConfig) (*clientSession, *websocket.Conn) {
+	session, clientConn := newTestSession(t, NewConfig())
+	session.hbChan = make(chan *models.HeartBeat)
+	session.hbResetChan = make(chan struct{}, 1)
+
+	if err := session.SetChaos(chaos); err != nil {
+		t.Fatal(err)
+	}
+
+	go session.sendMessageLoop()
+	go session.heartbeatLoop()
+
+	t.Cleanup(func() { session.Close(-1, "test finished") })
+
+	return session, clientConn
+}
+
+func expectNoMessage(t *testing.T, conn *websocket.Conn) {
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond * 300))
+
+	if _, msg, err := conn.ReadMessage(); err == nil {
+		t.Fatal("unexpected message:", string(msg))
+	}
+}
+
+func TestChaosConfig(t *testing.T) {
+	cfg := NewChaosConfig()
+	if err := cfg.Validate(); err != nil || cfg.IsEnabled() {
+		t.Fatal("default chaos config should be valid & disabled:", err)
+	}
+
+	for _, modify := range []func(*ChaosConfig){
+		func(c *ChaosConfig) { c.Drop = 1.5 },
+		func(c *ChaosConfig) { c.Reorder = -0.1 },
+		func(c *ChaosConfig) { c.DelayMax = -1 },
+		func(c *ChaosConfig) { c.CloseCodes = []int{999} },
+		func(c *ChaosConfig) { c.Close, c.CloseCodes = 0.1, nil },
+		func(c *ChaosConfig) { c.Start = -1 },
+		func(c *ChaosConfig) { c.Duration, c.Period = 10, 5 },
+	} {
+		cfg := NewChaosConfig()
+		modify(cfg)
+
+		if err := cfg.Validate(); err == nil {
+			t.Fatalf("invalid chaos config passed: %+v", cfg)
+		}
+	}
+
+	if newChaosInjector(NewChaosConfig()) != nil {
+		t.Fatal("injector created for disabled chaos config")
+	}
+}
+
+func TestChaosSchedule(t *testing.T) {
+	cfg := NewChaosConfig()
+	cfg.Drop = 1
+	cfg.Start, cfg.Duration, cfg.Period = 10, 5, 20
+
+	injector := newChaosInjector(cfg)
+	since := injector.since
+
+	for offset, active := range map[int]bool{
+		0: false, 9: false, 10: true, 14: true, 15: false, 29: false, 30: true, 34: true, 35: false,
+	} {
+		if injector.isActive(since.Add(time.Duration(offset)*time.Second)) != active {
+			t.Fatalf("fault window at %ds should be %t", offset, active)
+		}
+	}
+
+	cfg.Period = 0
+	if injector.isActive(since.Add(time.Second * 30)) {
+		t.Fatal("fault window should not be repeated")
+	}
+}
+
+func TestChaosMessageFaults(t *testing.T) {
+	t.Run("Drop", func(t *testing.T) {
+		cfg := NewChaosConfig()
+		cfg.Drop = 1
+
+		session, conn := newChaosSession(t, cfg)
+
+		session.WriteTopicMessage("trade", models.NewTradePartial())
+		readTestMessage(t, conn, `"action":"partial"`)
+
+		session.WriteTopicMessage("trade", newTestTrade(9000))
+		expectNoMessage(t, conn)
+	})
+
+	t.Run("SkipPartial", func(t *testing.T) {
+		cfg := NewChaosConfig()
+		cfg.SkipPartial = 1
+
+		session, conn := newChaosSession(t, cfg)
+
+		session.WriteTopicMessage("trade", models.NewTradePartial())
+		session.WriteTopicMessage("trade", newTestTrade(9000))
+		readTestMessage(t, conn, `"action":"insert"`)
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		cfg := NewChaosConfig()
+		cfg.Duplicate = 1
+
+		session, conn := newChaosSession(t, cfg)
+
+		session.WriteTopicMessage("trade", newTestTrade(9000))
+		readTestMessage(t, conn, `"price":9000`)
+		readTestMessage(t, conn, `"price":9000`)
+	})
+
+	t.Run("Reorder", func(t *testing.T) {
+		cfg := NewChaosConfig()
+		cfg.Reorder = 1
+
+		session, conn := newChaosSession(t, cfg)
+
+		session.WriteTopicMessage("trade", newTestTrade(9000))
+		session.WriteTopicMessage("trade", newTestTrade(9001))
+		readTestMessage(t, conn, `"price":9001`)
+		readTestMessage(t, conn, `"price":9000`)
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		cfg := NewChaosConfig()
+		cfg.Malformed = 1
+
+		session, conn := newChaosSession(t, cfg)
+
+		session.WriteTopicMessage("trade", newTestTrade(9000))
+		msg := readTestMessage(t, conn, `"table":"trade"`)
+
+		var rsp models.TradeResponse
+		if err := json.Unmarshal([]byte(msg), &rsp); err == nil {
+			t.Fatal("malformed message parsed:", msg)
+		}
+	})
+
+	t.Run("Delay", func(t *testing.T) {
+		cfg := NewChaosConfig()
+		cfg.Delay, cfg.DelayMax, cfg.Seed = 1, 100, 1
+
+		session, conn := newChaosSession(t, cfg)
+
+		session.WriteTopicMessage("trade", newTestTrade(9000))
+		readTestMessage(t, conn, `"price":9000`)
+	})
+
+	t.Run("Schedule", func(t *testing.T) {
+		cfg := NewChaosConfig()
+		cfg.Drop, cfg.Start = 1, 3600
+
+		session, conn := newChaosSession(t, cfg)
+
+		session.WriteTopicMessage("trade", newTestTrade(9000))
+		readTestMessage(t, conn, `"price":9000`)
+	})
+
+	t.Run("SyncMessage", func(t *testing.T) {
+		cfg := NewChaosConfig()
+		cfg.Drop = 1
+
+		session, conn := newChaosSession(t, cfg)
+
+		if err := session.WriteTextMessage("pong", true); err != nil {
+			t.Fatal(err)
+		}
+		readTestMessage(t, conn, "pong")
+	})
+}
+
+func TestChaosClose(t *testing.T) {
+	for _, code := range []int{websocket.CloseServiceRestart, websocket.CloseAbnormalClosure} {
+		cfg := NewChaosConfig()
+		cfg.Close, cfg.CloseCodes = 1, []int{code}
+
+		session, conn := newChaosSession(t, cfg)
+
+		session.WriteTopicMessage("trade", newTestTrade(9000))
+
+		conn.SetReadDeadline(time.Now().Add(time.Second * 3))
+
+		_, _, err := conn.ReadMessage()
+		if !websocket.IsCloseError(err, code) {
+			t.Fatalf("expect close code %d, got: %v", code, err)
+		}
+
+		if !session.IsClosed() {
+			t.Fatal("session not closed")
+		}
+	}
+}
+
+func TestChaosStopHeartbeat(t *testing.T) {
+	session, conn := newChaosSession(t, nil)
+
+	session.hbChan <- models.NewPing()
+	readTestMessage(t, conn, "pong")
+
+	cfg := NewChaosConfig()
+	cfg.StopHeartbeat = 1
+
+	if err := session.SetChaos(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := session.GetStatus(); status.Chaos == nil || status.Chaos.StopHeartbeat != 1 {
+		t.Fatal("chaos config missing in session status")
+	}
+
+	session.hbChan <- models.NewPing()
+	expectNoMessage(t, conn)
+
+	session.SetChaos(nil)
+
+	if session.GetChaos() != nil || session.getChaos() != nil {
+		t.Fatal("chaos not disabled")
+	}
+}
@@ -2,11 +2,17 @@ package server
 
 import (
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/BurntSushi/toml"
+	"github.com/frozenpine/wstester/kafka"
+	"github.com/frozenpine/wstester/mock"
 	uuid "github.com/satori/go.uuid"
 )
 
@@ -23,28 +29,229 @@ const (
 	defaultID           = "0"
 	defaultHBInterval   = 15
 	defaultHBFail       = 3
+	defaultSymbol       = "XBTUSD"
 	isReverseHB         = false
+	defaultMockMode     = MockUpstream
 
-	// SvrConfigKey context key for SvrConfig
+	// MockUpstream public flow data cascaded from upstream
+	MockUpstream = "upstream"
+	// MockTrade public trade flow generated randomly
+	MockTrade = "trade"
+	// MockMatch public orderBookL2 & trade flow driven by matching engine
+	MockMatch = "match"
+	// MockGenerate public flow generated by synthetic market generator on matching engine
+	MockGenerate = "generate"
+	// MockReplay public flow replayed from recording file
+	MockReplay = "replay"
+	// MockNone no data source for public flow
+	MockNone = "none"
+
+	// SvrConfigKey context key for live config shared by server & sessions
 	SvrConfigKey = SvrContextKey("config")
 )
 
+const (
+	defaultRateBurst     = 10
+	defaultRateViolation = 10
+)
+
 // Config websocket listen config
 type Config struct {
-	Listen       net.IP
-	Port         int
-	BaseURI      string
-	SignatureURI string
+	Listen       net.IP `toml:"listen"`
+	Port         int    `toml:"port"`
+	BaseURI      string `toml:"base_uri"`
+	SignatureURI string `toml:"signature_uri"`
+
+	// TLS tls config for wss listener, plain ws listener if no certificate configured
+	TLS *TLSConfig `toml:"tls"`
+
+	// AdminKey key in admin-key header for admin api, empty means admin api only allowed from loopback
+	AdminKey string `toml:"admin_key"`
+
+	WelcomMsg string `toml:"welcome_msg"`
+	DocsURI   string `toml:"docs_uri"`
+	FrontID   string `toml:"front_id"`
+
+	// ConnectLimit connection limit for server, 0 means unlimited
+	ConnectLimit int `toml:"connect_limit"`
+	// ConnectLimitPerIP connection limit for each client ip, 0 means unlimited
+	ConnectLimitPerIP int `toml:"connect_limit_per_ip"`
+	// ConnectLimitPerKey connection limit for each api key, 0 means unlimited
+	ConnectLimitPerKey int `toml:"connect_limit_per_key"`
+	// TrustedProxies proxy ips or cidrs whose X-Forwarded-For header is trusted for client ip,
+	// empty means client ip is always remote addr
+	TrustedProxies []string `toml:"trusted_proxies"`
+
+	// RateLimit operation rate limit per second for each session, 0 means unlimited
+	RateLimit float64 `toml:"rate_limit"`
+	// RateBurst operation burst size for each session
+	RateBurst int `toml:"rate_burst"`
+	// KeyRateLimit operation rate limit per second for each api key, 0 means unlimited
+	KeyRateLimit float64 `toml:"key_rate_limit"`
+	// KeyRateBurst operation burst size for each api key
+	KeyRateBurst int `toml:"key_rate_burst"`
+	// RateViolationLimit session will be closed after rate limited operations exceed this count, 0 means never
+	RateViolationLimit int `toml:"rate_violation_limit"`
+
+	// SendQueue outbound queue & slow consumer policy for each session
+	SendQueue *QueueConfig `toml:"send_queue"`
+
+	// ConflateInterval publish interval in milliseconds for orderBookL2 channels,
+	// changes in interval are merged on price level, 0 means publish immediately
+	ConflateInterval int `toml:"conflate_interval"`
+
+	// PreparedMessage send table data with websocket prepared message,
+	// so compressed frames are shared by sessions with the same compression settings
+	PreparedMessage bool `toml:"prepared_message"`
+
+	// Chaos fault injection config for all sessions, can be overridden for each session by admin api
+	Chaos *ChaosConfig `toml:"chaos"`
+
+	// Symbols symbols for public flow caches
+	Symbols []string `toml:"symbols"`
+
+	// MockMode data source for public flow: upstream, trade, match, generate, replay or none
+	MockMode string `toml:"mock_mode"`
+	// Upstream upstream source config for upstream mock mode
+	Upstream *mock.UpstreamConfig `toml:"upstream"`
+	// Record directory for recording upstream frames in upstream mock mode, empty means no recording
+	Record string `toml:"record"`
+	// Replay recording replay config for replay mock mode
+	Replay *mock.ReplayConfig `toml:"replay"`
+	// Generator synthetic market generator config, tick size is also used in match mock mode
+	Generator *mock.GeneratorConfig `toml:"generator"`
+
+	// KeyStore json file path for api key & secret pairs
+	KeyStore string `toml:"key_store"`
+
+	// Notify kafka config for NOTIFY topic, private flow disabled if no broker configured
+	Notify *kafka.Config `toml:"notify"`
+
+	HeartbeatInterval  int  `toml:"heartbeat_interval"`
+	ReversHeartbeat    bool `toml:"reverse_heartbeat"`
+	HeartbeatFailCount int  `toml:"heartbeat_fail_count"`
+}
+
+// LoadFile load config from toml file, fields not in file will be kept
+func (c *Config) LoadFile(path string) error {
+	meta, err := toml.DecodeFile(path, c)
+	if err != nil {
+		return err
+	}
+
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return fmt.Errorf("unknown config keys in %s: %v", path, undecoded)
+	}
+
+	return nil
+}
+
+// Validate check config values
+func (c *Config) Validate() error {
+	if c.Listen == nil {
+		return errors.New("invalid listen address")
+	}
+
+	if c.Port <= 0 || c.Port > 65535 {
+		return fmt.Errorf("invalid port: %d", c.Port)
+	}
+
+	if !strings.HasPrefix(c.BaseURI, "/") || !strings.HasPrefix(c.SignatureURI, "/") {
+		return errors.New("uri must start with \"/\"")
+	}
+
+	if err := c.TLS.Validate(); err != nil {
+		return err
+	}
+
+	if c.ConnectLimit < 0 || c.ConnectLimitPerIP < 0 || c.ConnectLimitPerKey < 0 {
+		return errors.New("connect limit can not be negative")
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if parseProxy(proxy) == nil {
+			return fmt.Errorf("invalid trusted proxy: %s", proxy)
+		}
+	}
+
+	if c.RateLimit < 0 || c.KeyRateLimit < 0 || c.RateViolationLimit < 0 {
+		return errors.New("rate limit can not be negative")
+	}
+
+	if err := c.SendQueue.Validate(); err != nil {
+		return err
+	}
+
+	if c.ConflateInterval < 0 {
+		return errors.New("conflate interval can not be negative")
+	}
+
+	if err := c.Chaos.Validate(); err != nil {
+		return err
+	}
+
+	if len(c.Symbols) < 1 {
+		return errors.New("no symbol configured")
+	}
+
+	switch c.MockMode {
+	case MockUpstream, MockTrade, MockMatch, MockGenerate, MockReplay, MockNone:
+	default:
+		return fmt.Errorf("invalid mock mode: %s", c.MockMode)
+	}
+
+	if err := c.Upstream.Validate(); err != nil {
+		return err
+	}
+
+	for _, symbol := range c.Upstream.Symbols {
+		if !c.hasSymbol(symbol) {
+			return fmt.Errorf("upstream symbol %s not in symbols", symbol)
+		}
+	}
+
+	if err := c.Generator.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Replay.Validate(); err != nil {
+		return err
+	}
+
+	if c.MockMode == MockReplay && c.Replay.File == "" {
+		return errors.New("no recording file configured for replay mock mode")
+	}
 
-	WelcomMsg string
-	DocsURI   string
-	FrontID   string
+	if c.HeartbeatInterval <= 0 || c.HeartbeatFailCount <= 0 {
+		return errors.New("heartbeat interval & fail count must be positive")
+	}
 
-	ConnectLimit int
+	return nil
+}
 
-	HeartbeatInterval  int
-	ReversHeartbeat    bool
-	HeartbeatFailCount int
+// GetConflateInterval get publish interval for orderBookL2 channels
+func (c *Config) GetConflateInterval() time.Duration {
+	return time.Duration(c.ConflateInterval) * time.Millisecond
+}
+
+// GetHeartbeatInterval get interval for reverse heartbeat
+func (c *Config) GetHeartbeatInterval() time.Duration {
+	return time.Duration(c.HeartbeatInterval) * time.Second
+}
+
+// GetHeartbeatTimeout get timeout for session without any data received
+func (c *Config) GetHeartbeatTimeout() time.Duration {
+	return time.Duration(c.HeartbeatInterval*c.HeartbeatFailCount) * time.Second
+}
+
+func (c *Config) hasSymbol(symbol string) bool {
+	for _, name := range c.Symbols {
+		if name == symbol {
+			return true
+		}
+	}
+
+	return false
 }
 
 // ChangeListen change server listen address
@@ -84,6 +291,29 @@ func (c *Config) GetNS() uuid.UUID {
 	return uuid.Must(uuid.FromBytes(nsHash[:]))
 }
 
+// liveConfig config snapshot shared by running server & sessions,
+// snapshot stored must never be modified, reload swaps in a modified copy.
+type liveConfig struct {
+	value atomic.Value
+}
+
+// Load get current config snapshot
+func (c *liveConfig) Load() *Config {
+	return c.value.Load().(*Config)
+}
+
+// Store swap in new config snapshot
+func (c *liveConfig) Store(cfg *Config) {
+	c.value.Store(cfg)
+}
+
+func newLiveConfig(cfg *Config) *liveConfig {
+	live := liveConfig{}
+	live.Store(cfg)
+
+	return &live
+}
+
 // NewConfig create a new server config
 func NewConfig() *Config {
 	cfg := Config{
@@ -92,12 +322,30 @@ func NewConfig() *Config {
 		BaseURI:      defaultBaseURI,
 		SignatureURI: defaultSignatureURI,
 
+		TLS: &TLSConfig{},
+
 		WelcomMsg: defaultWelcomMsg,
 		DocsURI:   defaultDocURI,
 		FrontID:   defaultID,
 
 		ConnectLimit: 40,
 
+		RateBurst:          defaultRateBurst,
+		KeyRateBurst:       defaultRateBurst,
+		RateViolationLimit: defaultRateViolation,
+
+		SendQueue: NewQueueConfig(),
+		Chaos:     NewChaosConfig(),
+
+		Symbols:  []string{defaultSymbol},
+		MockMode: defaultMockMode,
+
+		Upstream:  mock.NewUpstreamConfig(),
+		Generator: mock.NewGeneratorConfig(),
+		Replay:    mock.NewReplayConfig(),
+
+		Notify: kafka.NewConfig(),
+
 		HeartbeatInterval:  defaultHBInterval,
 		ReversHeartbeat:    isReverseHB,
 		HeartbeatFailCount: defaultHBFail,
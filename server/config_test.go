@@ -1,6 +1,9 @@
 package server
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -16,3 +19,81 @@ func TestGetNS(t *testing.T) {
 
 	t.Log(cfg.GetNS())
 }
+
+func writeTestConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "wstester")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "config.toml")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	path := writeTestConfig(t, `
+listen = "127.0.0.1"
+port = 19988
+symbols = ["XBTUSD", "ETHUSD"]
+mock_mode = "none"
+heartbeat_interval = 5
+
+[notify]
+brokers = ["127.0.0.1:9092"]
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	cfg := NewConfig()
+	if err := cfg.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.GetListenAddr() != "127.0.0.1:19988" {
+		t.Fatal("listen addr miss-match:", cfg.GetListenAddr())
+	}
+
+	if len(cfg.Symbols) != 2 || cfg.MockMode != MockNone || cfg.HeartbeatInterval != 5 {
+		t.Fatal("config value miss-match:", cfg)
+	}
+
+	if !cfg.Notify.IsEnabled() || cfg.Notify.Topic != "NOTIFY" {
+		t.Fatal("notify config miss-match:", cfg.Notify)
+	}
+
+	if cfg.HeartbeatFailCount != defaultHBFail || cfg.BaseURI != defaultBaseURI {
+		t.Fatal("default value not kept.")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadFileUnknownKey(t *testing.T) {
+	path := writeTestConfig(t, `heartbeat = 5`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	if err := NewConfig().LoadFile(path); err == nil {
+		t.Fatal("unknown key should be reported.")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := NewConfig()
+	cfg.MockMode = "invalid"
+
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("invalid mock mode should be reported.")
+	}
+
+	cfg = NewConfig()
+	cfg.Symbols = nil
+
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("empty symbols should be reported.")
+	}
+}
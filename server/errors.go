@@ -1,12 +1,31 @@
 package server
 
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrInvalidAPIKey api key not found in key store
+	ErrInvalidAPIKey = errors.New("Invalid API Key.")
+	// ErrInvalidSignature api signature miss-match
+	ErrInvalidSignature = errors.New("Signature not valid.")
+	// ErrMissingSignature api key specified without signature or expires
+	ErrMissingSignature = errors.New("Missing API signature or expires.")
+	// ErrSessionClosed write message to closed session
+	ErrSessionClosed = errors.New("session is closed")
+)
+
 // ErrAPIExpires api signature expires error
 type ErrAPIExpires struct {
 	expires int64
 }
 
 func (e *ErrAPIExpires) Error() string {
-	return ""
+	return fmt.Sprintf(
+		"This request has expired - `expires` is in the past. Current time: %d, expires: %d",
+		time.Now().Unix(), e.expires)
 }
 
 // NewAPIExpires create api signature expires error
@@ -17,3 +36,31 @@ func NewAPIExpires(expires int64) *ErrAPIExpires {
 
 	return &err
 }
+
+// ErrConnectLimit connection count exceeds limit
+type ErrConnectLimit struct {
+	scope string
+	limit int
+}
+
+func (e *ErrConnectLimit) Error() string {
+	return fmt.Sprintf("Too many connections for %s, limit: %d.", e.scope, e.limit)
+}
+
+// Meta get limit info for error response
+func (e *ErrConnectLimit) Meta() map[string]interface{} {
+	return map[string]interface{}{
+		"scope": e.scope,
+		"limit": e.limit,
+	}
+}
+
+// NewConnectLimit create connection limit error for scope
+func NewConnectLimit(scope string, limit int) *ErrConnectLimit {
+	err := ErrConnectLimit{
+		scope: scope,
+		limit: limit,
+	}
+
+	return &err
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils"
+	"github.com/frozenpine/wstester/utils/metrics"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	conn := dialTestServer(t, httpSvr)
+	defer conn.Close()
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribe", Args: []string{"trade:XBTUSD"}})
+	readTestMessage(t, conn, `"subscribe":"trade:XBTUSD"`)
+	readTestMessage(t, conn, `"action":"partial"`)
+
+	svr.dataCaches.GetCache("trade", "XBTUSD").Append(utils.NewCacheInput(newTestTrade(9000)))
+	readTestMessage(t, conn, `"price":9000`)
+
+	w := httptest.NewRecorder()
+	svr.metricsHandler(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	output := w.Body.String()
+
+	for _, expect := range []string{
+		`wstester_table_in_messages_total{server="0.0.0.0:9988",symbol="XBTUSD",table="trade"} `,
+		`wstester_table_out_messages_total{server="0.0.0.0:9988",symbol="XBTUSD",table="trade"} `,
+		`wstester_cache_pipeline_capacity{server="0.0.0.0:9988",symbol="XBTUSD",table="trade"} 1000`,
+		`wstester_session_queue_depth{server="0.0.0.0:9988",session="`,
+		"# TYPE wstester_session_dropped_messages_total counter\n",
+		`wstester_session_dropped_messages_total{server="0.0.0.0:9988",session="`,
+		`wstester_session_conflated_messages_total{server="0.0.0.0:9988",session="`,
+		`wstester_dispatch_blocked_total `,
+		`wstester_slow_consumer_disconnects_total `,
+		`wstester_heartbeat_failures_total `,
+		`wstester_chaos_faults_total{fault="drop"} `,
+	} {
+		if !strings.Contains(output, expect) {
+			t.Fatalf("expect metrics contains %s, got:\n%s", expect, output)
+		}
+	}
+
+	// cache with same table & symbol in other server never touch series of this server
+	other := utils.NewTradeCache(utils.WithMetricLabels(ctx, metrics.Labels{"server": "0.0.0.0:9989"}), "XBTUSD")
+	other.Stop()
+
+	w = httptest.NewRecorder()
+	svr.metricsHandler(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if output := w.Body.String(); !strings.Contains(
+		output, `wstester_cache_pipeline_capacity{server="0.0.0.0:9988",symbol="XBTUSD",table="trade"} 1000`) {
+		t.Fatal("cache metrics unregistered by other server:", output)
+	}
+}
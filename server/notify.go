@@ -3,9 +3,14 @@ package server
 import (
 	"encoding/json"
 	"errors"
+	"net/http"
+	"reflect"
 	"regexp"
+	"strings"
 
+	"github.com/frozenpine/wstester/kafka"
 	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils/log"
 )
 
 var (
@@ -14,10 +19,24 @@ var (
 	execNotifyPattern     = regexp.MustCompile(`"type": ?"execution"`)
 	orderNotifyPattern    = regexp.MustCompile(`"type": ?"order"`)
 	tradeNotifyPattern    = regexp.MustCompile(`"type": ?"trade"`)
+
+	privateTables = map[string]func() models.TableResponse{
+		"order":     func() models.TableResponse { return models.NewOrderPartial() },
+		"execution": func() models.TableResponse { return models.NewExecutionPartial() },
+		"position":  func() models.TableResponse { return models.NewPositionPartial() },
+		"margin":    func() models.TableResponse { return models.NewMarginPartial() },
+	}
+
+	// accountTables private tables without symbol column, can not be subscribed with symbol
+	accountTables = map[string]bool{
+		"margin": true,
+	}
 )
 
 // Notify message in kafka NOTIFY topic
 type Notify interface {
+	// GetType get notify type, which is also the table name of content
+	GetType() string
 	// IsPrivate wether this notify message is a private flow
 	IsPrivate() bool
 	// GetClientID get client id in notify
@@ -69,6 +88,10 @@ type notifyMessage struct {
 	AccountID string `json:"accountId,omitempty"`
 }
 
+func (n *notifyMessage) GetType() string {
+	return n.Type
+}
+
 func (n *notifyMessage) IsPrivate() bool {
 	return n.ClientID != "" && n.AccountID != ""
 }
@@ -152,3 +175,205 @@ type MBLNotify struct {
 func (mbl *MBLNotify) GetContent() interface{} {
 	return mbl.Content
 }
+
+func (s *server) handlePrivateSubscribe(req models.Request, topicStr string, client Session) models.Response {
+	tableName, symbol, _, _ := s.parseTopic(topicStr)
+
+	var (
+		errMsg string
+		status int
+	)
+
+	switch {
+	case !client.IsAuthorized():
+		errMsg, status = "You are not authorized to subscribe private topic: "+topicStr, http.StatusUnauthorized
+	case symbol != "" && accountTables[tableName]:
+		errMsg, status = "Private topic can not be subscribed with symbol: "+topicStr, http.StatusBadRequest
+	}
+
+	if errMsg != "" {
+		rsp := models.ErrResponse{
+			Error:  errMsg,
+			Status: status,
+			Request: models.OperationRequest{
+				Operation: req.GetOperation(),
+				Args:      req.GetArgs(),
+			},
+		}
+
+		client.WriteJSONMessage(&rsp, false)
+
+		return &rsp
+	}
+
+	client.Subscribe(topicStr, nil)
+
+	rsp := models.SubscribeResponse{
+		Success:   true,
+		Subscribe: topicStr,
+		Request:   *req.(*models.OperationRequest),
+	}
+
+	client.WriteJSONMessage(&rsp, false)
+
+	partial := privateTables[tableName]()
+
+	var filter map[string]string
+
+	switch partial := partial.(type) {
+	case *models.OrderResponse:
+		filter = partial.Filter
+
+		// orders placed through order api are the only private data kept in server,
+		// other private tables' partial is empty
+		for _, ord := range s.orders.list(client.GetAccountID()) {
+			if isOrderOpen(ord) && (symbol == "" || ord.Symbol == symbol) {
+				partial.Data = append(partial.Data, ord)
+			}
+		}
+	case *models.ExecutionResponse:
+		filter = partial.Filter
+	case *models.PositionResponse:
+		filter = partial.Filter
+	case *models.MarginResponse:
+		filter = partial.Filter
+	}
+
+	filter["account"] = client.GetAccountID()
+	if symbol != "" {
+		filter["symbol"] = symbol
+	}
+
+	client.WriteJSONMessage(partial, false)
+
+	return &rsp
+}
+
+// subscribedSymbols get symbols of table subscribed by client,
+// all is true if table subscribed without symbol.
+func subscribedSymbols(client Session, table string) (all bool, symbols map[string]bool) {
+	for _, topic := range client.GetSubscribed() {
+		if topic == table {
+			return true, nil
+		}
+
+		if strings.HasPrefix(topic, table+":") {
+			if symbols == nil {
+				symbols = make(map[string]bool)
+			}
+
+			symbols[strings.TrimPrefix(topic, table+":")] = true
+		}
+	}
+
+	return
+}
+
+// filterNotifyContent make a copy of notify content with rows in subscribed symbols,
+// nil if no row left.
+func filterNotifyContent(content interface{}, symbols map[string]bool) interface{} {
+	origin := reflect.Indirect(reflect.ValueOf(content))
+	if origin.Kind() != reflect.Struct {
+		return nil
+	}
+
+	data := origin.FieldByName("Data")
+	if !data.IsValid() || data.Kind() != reflect.Slice {
+		return nil
+	}
+
+	rows := reflect.MakeSlice(data.Type(), 0, data.Len())
+
+	for idx := 0; idx < data.Len(); idx++ {
+		row := reflect.Indirect(data.Index(idx))
+		if !row.IsValid() {
+			continue
+		}
+
+		if symbol := row.FieldByName("Symbol"); symbol.IsValid() && symbols[symbol.String()] {
+			rows = reflect.Append(rows, data.Index(idx))
+		}
+	}
+
+	if rows.Len() < 1 {
+		return nil
+	}
+
+	filtered := reflect.New(origin.Type())
+	filtered.Elem().Set(origin)
+	filtered.Elem().FieldByName("Data").Set(rows)
+
+	return filtered.Interface()
+}
+
+// dispatchNotify dispatch private notify to authorized sessions subscribed notify's table,
+// sessions subscribed table with symbols only get rows in those symbols,
+// notify of public tables is never dispatched, return dispatched session count.
+func (s *server) dispatchNotify(notify Notify) int {
+	if _, isPrivate := privateTables[notify.GetType()]; !isPrivate || !notify.IsPrivate() {
+		return 0
+	}
+
+	content := notify.GetContent()
+	if content == nil || reflect.ValueOf(content).IsNil() {
+		log.Warnf("Notify[%s] content is empty.", notify.GetType())
+		return 0
+	}
+
+	var (
+		destinations []Session
+		contents     []interface{}
+	)
+
+	s.clientLock.RLock()
+	for _, client := range s.clients {
+		if !client.IsAuthorized() ||
+			client.GetClientID() != notify.GetClientID() ||
+			client.GetAccountID() != notify.GetAccountID() {
+			continue
+		}
+
+		all, symbols := subscribedSymbols(client, notify.GetType())
+
+		switch {
+		case all:
+			destinations = append(destinations, client)
+			contents = append(contents, content)
+		case len(symbols) > 0:
+			if filtered := filterNotifyContent(content, symbols); filtered != nil {
+				destinations = append(destinations, client)
+				contents = append(contents, filtered)
+			}
+		}
+	}
+	s.clientLock.RUnlock()
+
+	for idx, client := range destinations {
+		client.WriteJSONMessage(contents[idx], false)
+	}
+
+	return len(destinations)
+}
+
+func (s *server) consumeNotify(consumer kafka.Consumer) {
+	for msg := range consumer.Messages() {
+		notify, err := ParseNotify(msg)
+
+		if err != nil {
+			log.Error("Fail to parse notify: ", err)
+			continue
+		}
+
+		// public notify data is delivered by table caches
+		if !notify.IsPrivate() {
+			continue
+		}
+
+		if count := s.dispatchNotify(notify); count < 1 && log.IsTraceLevel {
+			log.Debugf("No session for %s notify of client[%s] account[%s]",
+				notify.GetType(), notify.GetClientID(), notify.GetAccountID())
+		}
+	}
+
+	log.Info("Notify consumer closed.")
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils"
+)
+
+func TestPrivateNotify(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	key := &APIKey{Key: "testKey", Secret: "testSecret", ClientID: "1", AccountID: "2"}
+	svr.keyStore = NewKeyStore()
+	svr.keyStore.AddKey(key)
+
+	svr.orders.save(key, &ngerest.Order{OrderID: "resting", Symbol: "XBTUSD", OrdStatus: "New", LeavesQty: 1})
+	svr.orders.save(key, &ngerest.Order{OrderID: "finished", Symbol: "XBTUSD", OrdStatus: "Filled"})
+
+	conn := dialTestServer(t, httpSvr)
+	defer conn.Close()
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribe", Args: []string{"order"}})
+	readTestMessage(t, conn, `"status":401`)
+
+	expires := time.Now().Unix() + 5
+	signature := utils.GenerateSignature(
+		"testSecret", "GET", &url.URL{Path: defaultBaseURI}, int(expires), nil)
+
+	conn.WriteJSON(models.OperationRequest{
+		Operation: "authKeyExpires",
+		Args:      []string{"testKey", strconv.FormatInt(expires, 10), signature},
+	})
+	readTestMessage(t, conn, `"success":true`)
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribe", Args: []string{"order"}})
+	readTestMessage(t, conn, `"subscribe":"order"`)
+	if partial := readTestMessage(t, conn, `"account":"2"`); !strings.Contains(partial, `"orderID":"resting"`) ||
+		strings.Contains(partial, `"orderID":"finished"`) {
+		t.Fatal("order partial miss-match:", partial)
+	}
+
+	other, err := ParseNotify([]byte(
+		`{"type":"order","clientId":"1","accountId":"3","content":{"table":"order","action":"insert","data":[{"orderID":"other"}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := svr.dispatchNotify(other); count != 0 {
+		t.Fatal("notify dispatched to other account's session")
+	}
+
+	exec, _ := ParseNotify([]byte(
+		`{"type":"execution","clientId":"1","accountId":"2","content":{"table":"execution","action":"insert","data":[]}}`))
+	if count := svr.dispatchNotify(exec); count != 0 {
+		t.Fatal("notify dispatched to unsubscribed table")
+	}
+
+	ord, _ := ParseNotify([]byte(
+		`{"type":"order","clientId":"1","accountId":"2","content":{"table":"order","action":"insert","data":[{"orderID":"mine"}]}}`))
+	if count := svr.dispatchNotify(ord); count != 1 {
+		t.Fatal("notify dispatch failed")
+	}
+	readTestMessage(t, conn, `"orderID":"mine"`)
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribe", Args: []string{"margin:XBTUSD"}})
+	readTestMessage(t, conn, `"status":400`)
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribe", Args: []string{"execution:XBTUSD"}})
+	readTestMessage(t, conn, `"subscribe":"execution:XBTUSD"`)
+	readTestMessage(t, conn, `"symbol":"XBTUSD"`)
+
+	other, _ = ParseNotify([]byte(
+		`{"type":"execution","clientId":"1","accountId":"2","content":{"table":"execution","action":"insert","data":[{"execID":"eth","symbol":"ETHUSD"}]}}`))
+	if count := svr.dispatchNotify(other); count != 0 {
+		t.Fatal("notify dispatched for unsubscribed symbol")
+	}
+
+	exec, _ = ParseNotify([]byte(
+		`{"type":"execution","clientId":"1","accountId":"2","content":{"table":"execution","action":"insert","data":[{"execID":"eth","symbol":"ETHUSD"},{"execID":"xbt","symbol":"XBTUSD"}]}}`))
+	if count := svr.dispatchNotify(exec); count != 1 {
+		t.Fatal("notify dispatch failed")
+	}
+	if msg := readTestMessage(t, conn, `"execID":"xbt"`); strings.Contains(msg, "ETHUSD") {
+		t.Fatal("notify rows not filtered by symbol:", msg)
+	}
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribe", Args: []string{"trade"}})
+	readTestMessage(t, conn, `"subscribe":"trade"`)
+
+	trade, _ := ParseNotify([]byte(
+		`{"type":"trade","clientId":"1","accountId":"2","content":{"table":"trade","action":"insert","data":[{"symbol":"XBTUSD"}]}}`))
+	if count := svr.dispatchNotify(trade); count != 0 {
+		t.Fatal("public table notify dispatched as private flow")
+	}
+}
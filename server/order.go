@@ -0,0 +1,636 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/orderbook"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	orderURI    = "/api/v1/order"
+	orderAllURI = "/api/v1/order/all"
+
+	maxAccountOrders  = 1000
+	defaultOrderCount = 100
+	maxOrderCount     = 500
+)
+
+var (
+	// ErrMissingAPIKey rest request without api key
+	ErrMissingAPIKey = errors.New("Missing API key.")
+	// ErrMatchDisabled order api requested without matching engine
+	ErrMatchDisabled = errors.New("Order API is only available in match mock mode.")
+)
+
+// orderStore orders placed through order api, grouped by owner's account
+type orderStore struct {
+	lock   sync.RWMutex
+	owners map[string]*APIKey
+	orders map[string][]*ngerest.Order
+}
+
+func isOrderOpen(ord *ngerest.Order) bool {
+	return ord.LeavesQty > 0 &&
+		(ord.OrdStatus == orderbook.StatusNew || ord.OrdStatus == orderbook.StatusPartiallyFilled)
+}
+
+// save add or replace order state, finished orders will be removed
+// if account's order count exceeds maxAccountOrders.
+func (s *orderStore) save(owner *APIKey, ord *ngerest.Order) {
+	stored := *ord
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	orders := s.orders[owner.AccountID]
+
+	if _, exist := s.owners[ord.OrderID]; exist {
+		for idx := len(orders) - 1; idx >= 0; idx-- {
+			if orders[idx].OrderID == ord.OrderID {
+				// fills are handled in engine's matching sequence,
+				// so result with less cum qty is an older state
+				if ord.CumQty >= orders[idx].CumQty {
+					orders[idx] = &stored
+				}
+				return
+			}
+		}
+	}
+
+	s.owners[ord.OrderID] = owner
+	orders = append(orders, &stored)
+
+	if len(orders) > maxAccountOrders {
+		for idx, origin := range orders {
+			if !isOrderOpen(origin) {
+				delete(s.owners, origin.OrderID)
+				orders = append(orders[:idx], orders[idx+1:]...)
+				break
+			}
+		}
+	}
+
+	s.orders[owner.AccountID] = orders
+}
+
+// setOwner register order's owner before order saved, nil owner to unregister
+func (s *orderStore) setOwner(orderID string, owner *APIKey) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if owner == nil {
+		delete(s.owners, orderID)
+	} else {
+		s.owners[orderID] = owner
+	}
+}
+
+func (s *orderStore) getOwner(orderID string) *APIKey {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.owners[orderID]
+}
+
+// find find account's order by order id or client order id
+func (s *orderStore) find(accountID, orderID, clOrdID string) *ngerest.Order {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	orders := s.orders[accountID]
+
+	for idx := len(orders) - 1; idx >= 0; idx-- {
+		if (orderID != "" && orders[idx].OrderID == orderID) ||
+			(orderID == "" && clOrdID != "" && orders[idx].ClOrdID == clOrdID) {
+			ord := *orders[idx]
+
+			return &ord
+		}
+	}
+
+	return nil
+}
+
+func (s *orderStore) list(accountID string) []*ngerest.Order {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	orders := make([]*ngerest.Order, 0, len(s.orders[accountID]))
+
+	for _, origin := range s.orders[accountID] {
+		ord := *origin
+		orders = append(orders, &ord)
+	}
+
+	return orders
+}
+
+func newOrderStore() *orderStore {
+	store := orderStore{
+		owners: make(map[string]*APIKey),
+		orders: make(map[string][]*ngerest.Order),
+	}
+
+	return &store
+}
+
+// orderParams rest request parameters from query string, form or json body
+type orderParams map[string]string
+
+func parseOrderParams(r *http.Request, body []byte) (orderParams, error) {
+	params := orderParams{}
+
+	for name, values := range r.URL.Query() {
+		params[name] = strings.Join(values, ",")
+	}
+
+	if len(body) == 0 {
+		return params, nil
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var values map[string]interface{}
+
+		if err := json.Unmarshal(body, &values); err != nil {
+			return nil, fmt.Errorf("invalid json body: %v", err)
+		}
+
+		for name, value := range values {
+			switch value := value.(type) {
+			case string:
+				params[name] = value
+			case float64:
+				params[name] = strconv.FormatFloat(value, 'f', -1, 64)
+			default:
+				data, _ := json.Marshal(value)
+				params[name] = string(data)
+			}
+		}
+
+		return params, nil
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid form body: %v", err)
+	}
+
+	for name, value := range values {
+		params[name] = strings.Join(value, ",")
+	}
+
+	return params, nil
+}
+
+func (p orderParams) getFloat(name string) (float64, error) {
+	value := p[name]
+	if value == "" {
+		return 0, nil
+	}
+
+	result, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, value)
+	}
+
+	return result, nil
+}
+
+// getList get list parameter in json array or comma separated format
+func (p orderParams) getList(name string) []string {
+	value := strings.TrimSpace(p[name])
+	if value == "" {
+		return nil
+	}
+
+	var list []string
+
+	if strings.HasPrefix(value, "[") && json.Unmarshal([]byte(value), &list) == nil {
+		return list
+	}
+
+	return strings.Split(value, ",")
+}
+
+func (s *server) getRESTAuth(r *http.Request, body []byte) (*APIKey, error) {
+	apiKey := r.Header.Get("api-key")
+	if apiKey == "" {
+		return nil, ErrMissingAPIKey
+	}
+
+	apiSignature := r.Header.Get("api-signature")
+	if apiSignature == "" {
+		return nil, ErrMissingSignature
+	}
+
+	apiExpires, err := strconv.ParseInt(r.Header.Get("api-expires"), 10, 64)
+	if err != nil {
+		return nil, ErrMissingSignature
+	}
+
+	return CheckRequestSignature(s.keyStore, apiKey, apiSignature, apiExpires, r.Method, r.URL, body)
+}
+
+func (s *server) getEngine(symbol string) (*orderbook.Engine, error) {
+	if len(s.engines) < 1 {
+		return nil, ErrMatchDisabled
+	}
+
+	engine, exist := s.engines[symbol]
+	if !exist {
+		return nil, fmt.Errorf("invalid symbol: %s", symbol)
+	}
+
+	return engine, nil
+}
+
+func newExecution(ord *ngerest.Order, execType string, fill *orderbook.Fill) *ngerest.Execution {
+	ts := ngerest.NGETime(time.Now())
+
+	exec := ngerest.Execution{
+		ExecID:           uuid.NewV4().String(),
+		OrderID:          ord.OrderID,
+		ClOrdID:          ord.ClOrdID,
+		Account:          ord.Account,
+		Symbol:           ord.Symbol,
+		Side:             ord.Side,
+		OrderQty:         ord.OrderQty,
+		Price:            ord.Price,
+		ExecType:         execType,
+		OrdType:          ord.OrdType,
+		TimeInForce:      ord.TimeInForce,
+		ExecInst:         ord.ExecInst,
+		OrdStatus:        ord.OrdStatus,
+		WorkingIndicator: ord.WorkingIndicator,
+		LeavesQty:        ord.LeavesQty,
+		CumQty:           ord.CumQty,
+		AvgPx:            ord.AvgPx,
+		Text:             ord.Text,
+		TransactTime:     &ts,
+		Timestamp:        &ts,
+	}
+
+	if fill != nil {
+		exec.LastQty = fill.Size
+		exec.LastPx = fill.Price
+		exec.TrdMatchID = fill.MatchID
+
+		if fill.Maker.OrderID == ord.OrderID {
+			exec.LastLiquidityInd = "AddedLiquidity"
+		} else {
+			exec.LastLiquidityInd = "RemovedLiquidity"
+		}
+	}
+
+	return &exec
+}
+
+func (s *server) notifyOrder(owner *APIKey, action string, ord *ngerest.Order) {
+	rsp := models.OrderResponse{}
+	rsp.Table = "order"
+	rsp.Action = action
+	rsp.Data = []*ngerest.Order{ord}
+
+	s.dispatchNotify(&OrderNotify{
+		notifyMessage: notifyMessage{Type: "order", ClientID: owner.ClientID, AccountID: owner.AccountID},
+		Content:       &rsp,
+	})
+}
+
+func (s *server) notifyExecution(owner *APIKey, exec *ngerest.Execution) {
+	rsp := models.ExecutionResponse{}
+	rsp.Table = "execution"
+	rsp.Action = models.InsertAction
+	rsp.Data = []*ngerest.Execution{exec}
+
+	s.dispatchNotify(&ExecutionNotify{
+		notifyMessage: notifyMessage{Type: "execution", ClientID: owner.ClientID, AccountID: owner.AccountID},
+		Content:       &rsp,
+	})
+}
+
+// handleResult save taker order's state in result & push order, execution updates to owner,
+// makers' fills are handled by handleFills.
+func (s *server) handleResult(owner *APIKey, rst *orderbook.Result, action, execType string) {
+	s.orders.save(owner, rst.Order)
+
+	// execution of operation itself reports order's state before fills
+	origin := rst.Order
+	if rst.Origin != nil {
+		origin = rst.Origin
+	}
+
+	s.notifyExecution(owner, newExecution(origin, execType, nil))
+
+	for _, fill := range rst.Fills {
+		s.notifyExecution(owner, newExecution(&fill.Taker, "Trade", fill))
+	}
+
+	if execType != "Canceled" && rst.Order.OrdStatus == orderbook.StatusCanceled {
+		s.notifyExecution(owner, newExecution(rst.Order, "Canceled", nil))
+	}
+
+	s.notifyOrder(owner, action, rst.Order)
+}
+
+// handleFills engine's fill handler, save makers' state & push order, execution updates to owners
+// whoever placed the taker order, makers without owner are orders placed by engine's other users.
+func (s *server) handleFills(fills []*orderbook.Fill) {
+	for _, fill := range fills {
+		if maker := s.orders.getOwner(fill.Maker.OrderID); maker != nil {
+			s.orders.save(maker, &fill.Maker)
+			s.notifyExecution(maker, newExecution(&fill.Maker, "Trade", fill))
+			s.notifyOrder(maker, models.UpdateAction, &fill.Maker)
+		}
+	}
+}
+
+func (s *server) placeOrder(owner *APIKey, params orderParams) (interface{}, error) {
+	ord := ngerest.Order{
+		ClOrdID:     params["clOrdID"],
+		Symbol:      params["symbol"],
+		Side:        params["side"],
+		OrdType:     params["ordType"],
+		TimeInForce: params["timeInForce"],
+		ExecInst:    params["execInst"],
+		Text:        params["text"],
+	}
+
+	if account, err := strconv.ParseFloat(owner.AccountID, 32); err == nil {
+		ord.Account = float32(account)
+	}
+
+	qty, err := params.getFloat("orderQty")
+	if err != nil {
+		return nil, err
+	}
+
+	// side determined by quantity's sign if not specified
+	if ord.Side == "" {
+		if qty < 0 {
+			ord.Side = orderbook.SideSell
+			qty = -qty
+		} else {
+			ord.Side = orderbook.SideBuy
+		}
+	}
+	ord.OrderQty = float32(qty)
+
+	if ord.Price, err = params.getFloat("price"); err != nil {
+		return nil, err
+	}
+
+	if ord.OrdType == "" && ord.Price == 0 {
+		ord.OrdType = orderbook.OrdTypeMarket
+	}
+
+	engine, err := s.getEngine(ord.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	// owner registered before placing, so fills of resting order are handled immediately
+	ord.OrderID = uuid.NewV4().String()
+	s.orders.setOwner(ord.OrderID, owner)
+
+	rst, err := engine.Place(&ord)
+	if err != nil {
+		s.orders.setOwner(ord.OrderID, nil)
+		return nil, err
+	}
+
+	s.handleResult(owner, rst, models.InsertAction, "New")
+
+	return rst.Order, nil
+}
+
+func (s *server) amendOrder(owner *APIKey, params orderParams) (interface{}, error) {
+	origin := s.orders.find(owner.AccountID, params["orderID"], params["origClOrdID"])
+	if origin == nil {
+		return nil, orderbook.ErrOrderNotFound
+	}
+
+	qty, err := params.getFloat("orderQty")
+	if err != nil {
+		return nil, err
+	}
+
+	if leaves, err := params.getFloat("leavesQty"); err != nil {
+		return nil, err
+	} else if leaves > 0 {
+		qty = float64(origin.CumQty) + leaves
+	}
+
+	price, err := params.getFloat("price")
+	if err != nil {
+		return nil, err
+	}
+
+	if qty == 0 && price == 0 {
+		return nil, errors.New("orderQty, leavesQty or price must be sent")
+	}
+
+	engine, err := s.getEngine(origin.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	rst, err := engine.Amend(origin.OrderID, float32(qty), price)
+	if err != nil {
+		return nil, err
+	}
+
+	s.handleResult(owner, rst, models.UpdateAction, "Replaced")
+
+	return rst.Order, nil
+}
+
+func (s *server) cancelOrder(owner *APIKey, origin *ngerest.Order, text string) *ngerest.Order {
+	if !isOrderOpen(origin) {
+		origin.Text = "Unable to cancel order due to existing state: " + origin.OrdStatus
+		return origin
+	}
+
+	engine, err := s.getEngine(origin.Symbol)
+	if err != nil {
+		origin.Text = err.Error()
+		return origin
+	}
+
+	canceled, err := engine.Cancel(origin.OrderID)
+	if err != nil {
+		origin.Text = err.Error()
+		return origin
+	}
+
+	if text != "" {
+		canceled.Text = text
+	}
+
+	s.handleResult(owner, &orderbook.Result{Order: canceled}, models.UpdateAction, "Canceled")
+
+	return canceled
+}
+
+func (s *server) cancelOrders(owner *APIKey, params orderParams) (interface{}, error) {
+	var origins []*ngerest.Order
+
+	for _, orderID := range params.getList("orderID") {
+		if origin := s.orders.find(owner.AccountID, orderID, ""); origin != nil {
+			origins = append(origins, origin)
+		}
+	}
+
+	for _, clOrdID := range params.getList("clOrdID") {
+		if origin := s.orders.find(owner.AccountID, "", clOrdID); origin != nil {
+			origins = append(origins, origin)
+		}
+	}
+
+	if len(origins) < 1 {
+		return nil, orderbook.ErrOrderNotFound
+	}
+
+	results := make([]*ngerest.Order, 0, len(origins))
+	for _, origin := range origins {
+		results = append(results, s.cancelOrder(owner, origin, params["text"]))
+	}
+
+	return results, nil
+}
+
+func (s *server) cancelAllOrders(owner *APIKey, params orderParams) (interface{}, error) {
+	results := []*ngerest.Order{}
+
+	for _, origin := range s.orders.list(owner.AccountID) {
+		if !isOrderOpen(origin) {
+			continue
+		}
+
+		if symbol := params["symbol"]; symbol != "" && origin.Symbol != symbol {
+			continue
+		}
+
+		results = append(results, s.cancelOrder(owner, origin, params["text"]))
+	}
+
+	return results, nil
+}
+
+func (s *server) queryOrders(owner *APIKey, params orderParams) (interface{}, error) {
+	var filter struct {
+		Open bool `json:"open"`
+	}
+
+	if value := params["filter"]; value != "" {
+		if err := json.Unmarshal([]byte(value), &filter); err != nil {
+			return nil, fmt.Errorf("invalid filter: %s", value)
+		}
+	}
+
+	count := defaultOrderCount
+	if value := params["count"]; value != "" {
+		var err error
+
+		if count, err = strconv.Atoi(value); err != nil || count < 1 || count > maxOrderCount {
+			return nil, fmt.Errorf("invalid count: %s", value)
+		}
+	}
+
+	reverse := params["reverse"] == "true"
+
+	orders := s.orders.list(owner.AccountID)
+	results := make([]*ngerest.Order, 0, count)
+
+	for idx := range orders {
+		if reverse {
+			idx = len(orders) - 1 - idx
+		}
+
+		ord := orders[idx]
+
+		if symbol := params["symbol"]; symbol != "" && ord.Symbol != symbol {
+			continue
+		}
+
+		if filter.Open && !isOrderOpen(ord) {
+			continue
+		}
+
+		if results = append(results, ord); len(results) >= count {
+			break
+		}
+	}
+
+	return results, nil
+}
+
+// orderHandler mock order api, requests should be signed by utils.GenerateSignature:
+// place order on POST /api/v1/order, amend order on PUT /api/v1/order,
+// cancel orders on DELETE /api/v1/order, query orders on GET /api/v1/order,
+// cancel all orders on DELETE /api/v1/order/all
+func (s *server) orderHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	owner, err := s.getRESTAuth(r, body)
+	if err != nil {
+		writeHTTPError(w, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	params, err := parseOrderParams(r, body)
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	var handler func(*APIKey, orderParams) (interface{}, error)
+
+	switch {
+	case r.URL.Path == orderAllURI && r.Method == http.MethodDelete:
+		handler = s.cancelAllOrders
+	case r.URL.Path == orderURI && r.Method == http.MethodPost:
+		handler = s.placeOrder
+	case r.URL.Path == orderURI && r.Method == http.MethodPut:
+		handler = s.amendOrder
+	case r.URL.Path == orderURI && r.Method == http.MethodDelete:
+		handler = s.cancelOrders
+	case r.URL.Path == orderURI && r.Method == http.MethodGet:
+		handler = s.queryOrders
+	default:
+		writeHTTPError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"), nil)
+		return
+	}
+
+	// orders are handled in sequence, so that private flow is in the same order as matching
+	s.orderMux.Lock()
+	result, err := handler(owner, params)
+	s.orderMux.Unlock()
+
+	switch err {
+	case nil:
+		writeJSONResult(w, result)
+	case orderbook.ErrOrderNotFound:
+		writeHTTPError(w, http.StatusNotFound, err, nil)
+	case ErrMatchDisabled:
+		writeHTTPError(w, http.StatusServiceUnavailable, err, nil)
+	default:
+		writeHTTPError(w, http.StatusBadRequest, err, nil)
+	}
+}
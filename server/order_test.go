@@ -0,0 +1,229 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/orderbook"
+	"github.com/frozenpine/wstester/utils"
+)
+
+func sendOrderRequest(
+	t *testing.T, httpSvr *httptest.Server, key *APIKey, method, uri string, params url.Values) (int, []byte) {
+	target, _ := url.Parse(httpSvr.URL + uri)
+
+	var body string
+	if method == http.MethodGet {
+		target.RawQuery = params.Encode()
+	} else {
+		body = params.Encode()
+	}
+
+	req, _ := http.NewRequest(method, target.String(), bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if key != nil {
+		expires := time.Now().Unix() + 5
+		req.Header.Set("api-key", key.Key)
+		req.Header.Set("api-expires", strconv.FormatInt(expires, 10))
+		req.Header.Set("api-signature", utils.GenerateSignature(
+			key.Secret, method, target, int(expires), bytes.NewBufferString(body)))
+	}
+
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	result, _ := ioutil.ReadAll(rsp.Body)
+
+	return rsp.StatusCode, result
+}
+
+func TestOrderAPI(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	maker := &APIKey{Key: "makerKey", Secret: "makerSecret", ClientID: "1", AccountID: "2"}
+	taker := &APIKey{Key: "takerKey", Secret: "takerSecret", ClientID: "1", AccountID: "3"}
+
+	svr.keyStore = NewKeyStore()
+	svr.keyStore.AddKey(maker)
+	svr.keyStore.AddKey(taker)
+
+	mbl := utils.NewMBLCache(ctx, "XBTUSD")
+	svr.engines["XBTUSD"] = orderbook.NewEngine("XBTUSD", 0, mbl, nil)
+	svr.engines["XBTUSD"].SetFillHandler(svr.handleFills)
+
+	apiSvr := httptest.NewServer(http.HandlerFunc(svr.orderHandler))
+	defer apiSvr.Close()
+
+	conn := dialTestServer(t, httpSvr)
+	defer conn.Close()
+
+	expires := time.Now().Unix() + 5
+	conn.WriteJSON(models.OperationRequest{
+		Operation: "authKeyExpires",
+		Args: []string{maker.Key, strconv.FormatInt(expires, 10), utils.GenerateSignature(
+			maker.Secret, "GET", &url.URL{Path: defaultBaseURI}, int(expires), nil)},
+	})
+	readTestMessage(t, conn, `"success":true`)
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribe", Args: []string{"order", "execution"}})
+	readTestMessage(t, conn, `"subscribe":"order"`)
+	readTestMessage(t, conn, `"table":"order","action":"partial"`)
+	readTestMessage(t, conn, `"subscribe":"execution"`)
+	readTestMessage(t, conn, `"table":"execution","action":"partial"`)
+
+	if status, _ := sendOrderRequest(t, apiSvr, nil, http.MethodPost, orderURI, nil); status != http.StatusUnauthorized {
+		t.Fatal("unsigned request accepted:", status)
+	}
+
+	status, result := sendOrderRequest(t, apiSvr, maker, http.MethodPost, orderURI, url.Values{
+		"symbol": {"XBTUSD"}, "side": {"Sell"}, "orderQty": {"10"}, "price": {"9000"}, "clOrdID": {"ask"},
+	})
+	if status != http.StatusOK {
+		t.Fatal("place order failed:", string(result))
+	}
+
+	ask := ngerest.Order{}
+	json.Unmarshal(result, &ask)
+	if ask.OrdStatus != orderbook.StatusNew || ask.Account != 2 {
+		t.Fatal("placed order miss-match:", string(result))
+	}
+
+	readTestMessage(t, conn, `"execType":"New"`)
+	readTestMessage(t, conn, `"table":"order","action":"insert"`)
+
+	snap := mbl.TakeSnapshot(0, nil, "").(*models.MBLResponse)
+	if len(snap.Data) != 1 || snap.Data[0].Price != 9000 || snap.Data[0].Size != 10 {
+		t.Fatal("order not on book:", snap.String())
+	}
+
+	status, result = sendOrderRequest(t, apiSvr, taker, http.MethodPost, orderURI, url.Values{
+		"symbol": {"XBTUSD"}, "orderQty": {"4"}, "price": {"9000"},
+	})
+	if status != http.StatusOK {
+		t.Fatal("place order failed:", string(result))
+	}
+
+	readTestMessage(t, conn, `"execType":"Trade"`)
+	readTestMessage(t, conn, `"ordStatus":"PartiallyFilled"`)
+
+	status, result = sendOrderRequest(t, apiSvr, maker, http.MethodPut, orderURI, url.Values{
+		"origClOrdID": {"ask"}, "price": {"9001"},
+	})
+	if status != http.StatusOK {
+		t.Fatal("amend order failed:", string(result))
+	}
+
+	readTestMessage(t, conn, `"execType":"Replaced"`)
+	readTestMessage(t, conn, `"price":9001`)
+
+	// taker placed by engine's other user, like generator
+	if _, err := svr.engines["XBTUSD"].Place(&ngerest.Order{
+		Symbol: "XBTUSD", Side: orderbook.SideBuy, OrdType: orderbook.OrdTypeMarket, OrderQty: 2,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	readTestMessage(t, conn, `"execType":"Trade"`)
+	readTestMessage(t, conn, `"cumQty":6`)
+
+	status, result = sendOrderRequest(t, apiSvr, taker, http.MethodDelete, orderURI, url.Values{
+		"orderID": {ask.OrderID},
+	})
+	if status != http.StatusNotFound {
+		t.Fatal("other account's order canceled:", string(result))
+	}
+
+	status, result = sendOrderRequest(t, apiSvr, maker, http.MethodDelete, orderAllURI, nil)
+	if status != http.StatusOK {
+		t.Fatal("cancel all orders failed:", string(result))
+	}
+
+	var canceled []*ngerest.Order
+	json.Unmarshal(result, &canceled)
+	if len(canceled) != 1 || canceled[0].OrdStatus != orderbook.StatusCanceled {
+		t.Fatal("cancel all orders result miss-match:", string(result))
+	}
+
+	readTestMessage(t, conn, `"execType":"Canceled"`)
+	readTestMessage(t, conn, `"ordStatus":"Canceled"`)
+
+	snap = mbl.TakeSnapshot(0, nil, "").(*models.MBLResponse)
+	if len(snap.Data) != 0 {
+		t.Fatal("canceled order still on book:", snap.String())
+	}
+
+	status, result = sendOrderRequest(t, apiSvr, maker, http.MethodGet, orderURI, url.Values{
+		"symbol": {"XBTUSD"}, "filter": {`{"open":true}`},
+	})
+	if status != http.StatusOK || string(result) != "[]" {
+		t.Fatal("open orders miss-match:", string(result))
+	}
+
+	status, result = sendOrderRequest(t, apiSvr, maker, http.MethodGet, orderURI, nil)
+	var orders []*ngerest.Order
+	json.Unmarshal(result, &orders)
+	if status != http.StatusOK || len(orders) != 1 || orders[0].CumQty != 6 {
+		t.Fatal("order history miss-match:", string(result))
+	}
+
+	if _, err := svr.engines["XBTUSD"].Place(&ngerest.Order{
+		Symbol: "XBTUSD", Side: orderbook.SideSell, OrderQty: 1, Price: 9000,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	status, result = sendOrderRequest(t, apiSvr, maker, http.MethodPost, orderURI, url.Values{
+		"symbol": {"XBTUSD"}, "orderQty": {"1"}, "price": {"9000"},
+	})
+	if status != http.StatusOK {
+		t.Fatal("place order failed:", string(result))
+	}
+
+	// new execution reports state before filled
+	if msg := readTestMessage(t, conn, `"execType":"New"`); !strings.Contains(msg, `"ordStatus":"New"`) ||
+		!strings.Contains(msg, `"leavesQty":1`) {
+		t.Fatal("new execution miss-match:", msg)
+	}
+	readTestMessage(t, conn, `"ordStatus":"Filled"`)
+}
+
+func TestOrderAPIDisabled(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	key := &APIKey{Key: "testKey", Secret: "testSecret", ClientID: "1", AccountID: "2"}
+	svr.keyStore = NewKeyStore()
+	svr.keyStore.AddKey(key)
+
+	apiSvr := httptest.NewServer(http.HandlerFunc(svr.orderHandler))
+	defer apiSvr.Close()
+
+	status, _ := sendOrderRequest(t, apiSvr, key, http.MethodPost, orderURI, url.Values{
+		"symbol": {"XBTUSD"}, "side": {"Buy"}, "orderQty": {"1"}, "price": {"9000"},
+	})
+	if status != http.StatusServiceUnavailable {
+		t.Fatal("order placed without matching engine:", status)
+	}
+}
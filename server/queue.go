@@ -0,0 +1,262 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils"
+)
+
+const (
+	// PolicyDropOldest drop oldest queued data message when send queue full
+	PolicyDropOldest = "drop_oldest"
+	// PolicyConflate replace queued data message of the same topic with new one when send queue full,
+	// suited for snapshot tables like orderBook10, quote, queued orderBookL2 deltas are merged on price level.
+	PolicyConflate = "conflate"
+	// PolicyDisconnect close session when send queue full
+	PolicyDisconnect = "disconnect"
+
+	defaultQueueSize   = 1000
+	defaultQueuePolicy = PolicyDropOldest
+)
+
+// ErrSlowConsumer session closed by disconnect policy for send queue full
+var ErrSlowConsumer = errors.New("Slow consumer: send queue full.")
+
+// QueueConfig session send queue config,
+// only data messages of subscribed public topics count in queue size & can be dropped or conflated,
+// other messages like operation responses, partials & private notifies are always queued.
+type QueueConfig struct {
+	// Size max data messages queued in each session
+	Size int `toml:"size"`
+	// Policy slow consumer policy when queue full: drop_oldest, conflate or disconnect
+	Policy string `toml:"policy"`
+	// TopicPolicies policy for each table, overrides default policy
+	TopicPolicies map[string]string `toml:"topic_policies"`
+}
+
+func isValidPolicy(policy string) bool {
+	switch policy {
+	case PolicyDropOldest, PolicyConflate, PolicyDisconnect:
+		return true
+	default:
+		return false
+	}
+}
+
+// Validate check send queue config
+func (c *QueueConfig) Validate() error {
+	if c.Size <= 0 {
+		return errors.New("send queue size must be positive")
+	}
+
+	if !isValidPolicy(c.Policy) {
+		return fmt.Errorf("invalid send queue policy: %s", c.Policy)
+	}
+
+	for table, policy := range c.TopicPolicies {
+		if !isValidPolicy(policy) {
+			return fmt.Errorf("invalid send queue policy for %s: %s", table, policy)
+		}
+	}
+
+	return nil
+}
+
+// GetPolicy get slow consumer policy for topic, topic can be in table:symbol format
+func (c *QueueConfig) GetPolicy(topic string) string {
+	table := strings.SplitN(topic, ":", 2)[0]
+
+	if policy, exist := c.TopicPolicies[table]; exist {
+		return policy
+	}
+
+	return c.Policy
+}
+
+// NewQueueConfig create send queue config with default values
+func NewQueueConfig() *QueueConfig {
+	cfg := QueueConfig{
+		Size:          defaultQueueSize,
+		Policy:        defaultQueuePolicy,
+		TopicPolicies: make(map[string]string),
+	}
+
+	return &cfg
+}
+
+// sendQueue bounded outbound queue for session, push never blocks
+type sendQueue struct {
+	lock    sync.Mutex
+	items   *list.List
+	notify  chan struct{}
+	size    int
+	dataLen int
+}
+
+// push queue message, data message (with topic) exceeding queue size is handled by policy,
+// return count of messages dropped & conflated.
+func (q *sendQueue) push(msg *message, policy string) (dropped, conflated int, err error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	defer func() {
+		select {
+		case q.notify <- struct{}{}:
+		default:
+		}
+	}()
+
+	if msg.topic == "" || q.dataLen < q.size {
+		q.pushBack(msg)
+
+		return
+	}
+
+	switch policy {
+	case PolicyConflate:
+		if mbl, ok := unwrapMessage(msg).(*models.MBLResponse); ok {
+			dropped, conflated = q.conflateMBL(msg.topic, mbl)
+
+			return dropped, conflated, nil
+		}
+
+		for e := q.items.Back(); e != nil; e = e.Prev() {
+			if e.Value.(*message).topic == msg.topic {
+				e.Value = msg
+
+				return 0, 1, nil
+			}
+		}
+	case PolicyDisconnect:
+		return 0, 0, ErrSlowConsumer
+	}
+
+	q.dropOldest()
+	q.pushBack(msg)
+
+	return 1, 0, nil
+}
+
+// conflateMBL merge queued mbl deltas of topic with new one on price level,
+// merged deltas are queued at position of the first merged one, so order with other topics is kept.
+// Oldest data messages are dropped if merged deltas still exceed queue size,
+// return count of messages dropped & conflated.
+func (q *sendQueue) conflateMBL(topic string, mbl *models.MBLResponse) (dropped, conflated int) {
+	var (
+		rspList  []*models.MBLResponse
+		elements []*list.Element
+	)
+
+	for e := q.items.Front(); e != nil; e = e.Next() {
+		if queued := e.Value.(*message); queued.topic == topic {
+			if rsp, ok := unwrapMessage(queued).(*models.MBLResponse); ok {
+				rspList = append(rspList, rsp)
+				elements = append(elements, e)
+			}
+		}
+	}
+
+	rspList = append(rspList, mbl)
+
+	merged := utils.ConflateMBL(rspList...)
+
+	for _, rsp := range merged {
+		msg := &message{json: rsp, topic: topic}
+
+		if len(elements) > 0 {
+			q.items.InsertBefore(msg, elements[0])
+			q.dataLen++
+		} else {
+			q.pushBack(msg)
+		}
+	}
+
+	for _, e := range elements {
+		q.items.Remove(e)
+		q.dataLen--
+	}
+
+	for q.dataLen > q.size {
+		q.dropOldest()
+		dropped++
+	}
+
+	return dropped, utils.MaxInt(len(rspList)-len(merged), 0)
+}
+
+// unwrapMessage get origin response in message if it's shared
+func unwrapMessage(msg *message) interface{} {
+	if rsp, ok := msg.json.(models.TableResponse); ok {
+		return utils.UnwrapResponse(rsp)
+	}
+
+	return msg.json
+}
+
+func (q *sendQueue) pushBack(msg *message) {
+	q.items.PushBack(msg)
+
+	if msg.topic != "" {
+		q.dataLen++
+	}
+}
+
+func (q *sendQueue) dropOldest() {
+	for e := q.items.Front(); e != nil; e = e.Next() {
+		if e.Value.(*message).topic != "" {
+			q.items.Remove(e)
+			q.dataLen--
+
+			return
+		}
+	}
+}
+
+// pop wait for first queued message until ctx done
+func (q *sendQueue) pop(ctx context.Context) *message {
+	for {
+		q.lock.Lock()
+		if e := q.items.Front(); e != nil {
+			msg := q.items.Remove(e).(*message)
+
+			if msg.topic != "" {
+				q.dataLen--
+			}
+
+			q.lock.Unlock()
+
+			return msg
+		}
+		q.lock.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-q.notify:
+		}
+	}
+}
+
+// Len count of queued messages
+func (q *sendQueue) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.items.Len()
+}
+
+func newSendQueue(size int) *sendQueue {
+	queue := sendQueue{
+		items:  list.New(),
+		notify: make(chan struct{}, 1),
+		size:   size,
+	}
+
+	return &queue
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/models"
+	"github.com/gorilla/websocket"
+)
+
+func TestQueueConfig(t *testing.T) {
+	cfg := NewQueueConfig()
+	cfg.TopicPolicies["orderBook10"] = PolicyConflate
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if policy := cfg.GetPolicy("orderBook10:XBTUSD"); policy != PolicyConflate {
+		t.Fatal("topic policy miss-match:", policy)
+	}
+
+	if policy := cfg.GetPolicy("trade"); policy != PolicyDropOldest {
+		t.Fatal("default policy miss-match:", policy)
+	}
+
+	cfg.TopicPolicies["trade"] = "block"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("invalid topic policy not detected")
+	}
+}
+
+func TestSendQueue(t *testing.T) {
+	queue := newSendQueue(2)
+
+	push := func(topic, txt, policy string) (int, int) {
+		dropped, conflated, err := queue.push(&message{topic: topic, txt: txt}, policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return dropped, conflated
+	}
+
+	push("", "subscribed", "")
+	push("trade", "td1", PolicyDropOldest)
+	push("quote", "q1", PolicyConflate)
+
+	if dropped, _ := push("trade", "td2", PolicyDropOldest); dropped != 1 {
+		t.Fatal("oldest data not dropped")
+	}
+
+	if _, conflated := push("trade", "td3", PolicyConflate); conflated != 1 {
+		t.Fatal("data not conflated")
+	}
+
+	// control messages never dropped & not counted in size
+	push("", "pong", "")
+
+	if _, _, err := queue.push(&message{topic: "trade", txt: "td4"}, PolicyDisconnect); err != ErrSlowConsumer {
+		t.Fatal("slow consumer not detected:", err)
+	}
+
+	var sent []string
+	for queue.Len() > 0 {
+		sent = append(sent, queue.pop(context.Background()).txt)
+	}
+
+	if strings.Join(sent, ",") != "subscribed,q1,td3,pong" {
+		t.Fatal("queued messages miss-match:", sent)
+	}
+}
+
+// newTestSession make a session without send loop, so nothing in send queue will be consumed
+func newTestSession(t *testing.T, cfg *Config) (*clientSession, *websocket.Conn) {
+	connChan := make(chan *websocket.Conn, 1)
+
+	httpSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		connChan <- conn
+	}))
+	t.Cleanup(httpSvr.Close)
+
+	clientConn, _, err := websocket.DefaultDialer.Dial(strings.Replace(httpSvr.URL, "http", "ws", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { clientConn.Close() })
+
+	conn := <-connChan
+
+	session := clientSession{
+		cfg:        newLiveConfig(cfg),
+		conn:       conn,
+		addr:       conn.RemoteAddr(),
+		sendQueue:  newSendQueue(cfg.SendQueue.Size),
+		subscribed: make(map[string][]func()),
+		queues:     make(map[string][]func() int),
+	}
+	session.ctx, session.cancelFn = context.WithCancel(context.Background())
+
+	return &session, clientConn
+}
+
+func TestSlowConsumer(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SendQueue.Size = 2
+	cfg.SendQueue.TopicPolicies["quote"] = PolicyConflate
+	cfg.SendQueue.TopicPolicies["orderBookL2"] = PolicyDisconnect
+
+	session, clientConn := newTestSession(t, cfg)
+
+	for _, price := range []float64{9000, 9001, 9002} {
+		session.WriteTopicMessage("trade:XBTUSD", newTestTrade(price))
+	}
+
+	quote := models.QuoteResponse{}
+	quote.Table = "quote"
+	quote.Action = models.InsertAction
+	// no queued quote to be conflated, oldest trade dropped
+	session.WriteTopicMessage("quote:XBTUSD", &quote)
+	session.WriteTopicMessage("quote:XBTUSD", &quote)
+
+	// partial never dropped
+	session.WriteTopicMessage("quote:XBTUSD", models.NewQuotePartial())
+
+	if status := session.GetStatus(); status.Dropped != 2 || status.Conflated != 1 || status.QueueDepth != 3 {
+		t.Fatalf("slow consumer status miss-match: %+v", status)
+	}
+
+	if atomic.LoadInt32(&session.queueFull) != 1 {
+		t.Fatal("send queue full state not recorded")
+	}
+
+	session.sendQueue.pop(context.Background())
+	session.WriteTopicMessage("trade:XBTUSD", newTestTrade(9003))
+
+	if atomic.LoadInt32(&session.queueFull) != 0 {
+		t.Fatal("send queue recovered state not recorded")
+	}
+
+	mbl := models.MBLResponse{}
+	mbl.Table = "orderBookL2"
+	mbl.Action = models.UpdateAction
+	if err := session.WriteTopicMessage("orderBookL2:XBTUSD", &mbl); err != ErrSlowConsumer {
+		t.Fatal("slow consumer not disconnected:", err)
+	}
+
+	if !session.IsClosed() {
+		t.Fatal("session not closed")
+	}
+
+	_, _, err := clientConn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Fatal("close code miss-match:", err)
+	}
+}
+
+func TestSendQueueConflateMBL(t *testing.T) {
+	queue := newSendQueue(2)
+
+	newMBL := func(action string, price float64, size float32) *message {
+		rsp := newTestMBL(action, &ngerest.OrderBookL2{Symbol: "XBTUSD", Side: "Buy", Price: price, Size: size})
+
+		return &message{json: rsp, topic: "orderBookL2:XBTUSD"}
+	}
+
+	queue.push(newMBL(models.InsertAction, 9000, 1), PolicyConflate)
+	queue.push(&message{topic: "trade:XBTUSD", txt: "td1"}, PolicyConflate)
+
+	if _, conflated, _ := queue.push(newMBL(models.UpdateAction, 9000, 2), PolicyConflate); conflated != 1 {
+		t.Fatal("mbl conflated count miss-match:", conflated)
+	}
+
+	// changes on different levels can not be merged, oldest dropped to keep queue size
+	if dropped, _, _ := queue.push(newMBL(models.DeleteAction, 8999, 1), PolicyConflate); dropped != 1 {
+		t.Fatal("mbl dropped count miss-match:", dropped)
+	}
+
+	if queue.dataLen > queue.size {
+		t.Fatal("queue size exceeded after conflate:", queue.dataLen)
+	}
+
+	var sent []string
+	for queue.Len() > 0 {
+		msg := queue.pop(context.Background())
+
+		if rsp, ok := msg.json.(*models.MBLResponse); ok {
+			sent = append(sent, rsp.String())
+		} else {
+			sent = append(sent, msg.txt)
+		}
+	}
+
+	// merged deltas keep position before newer messages of other topics
+	if len(sent) != 2 || !strings.Contains(sent[0], `"action":"insert"`) ||
+		!strings.Contains(sent[0], `"size":2`) || sent[1] != "td1" {
+		t.Fatal("conflated mbl miss-match:", sent)
+	}
+}
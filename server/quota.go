@@ -0,0 +1,192 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	quotaGlobal = "server"
+	quotaIP     = "ip"
+	quotaKey    = "key"
+)
+
+// connQuota connection counter for global, per ip & per api key limits
+type connQuota struct {
+	lock  sync.Mutex
+	total int
+	ips   map[string]int
+	keys  map[string]int
+}
+
+// minRemaining get minimum remaining count in limited scopes, -1 means unlimited
+func minRemaining(current, limit, last int) int {
+	if limit <= 0 {
+		return last
+	}
+
+	if left := limit - current; last < 0 || left < last {
+		return left
+	}
+
+	return last
+}
+
+// acquire occupy connection quota for ip & key, empty key means no api key.
+// remaining connection count will be returned, -1 means unlimited.
+func (q *connQuota) acquire(cfg *Config, ip, key string) (int, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if cfg.ConnectLimit > 0 && q.total >= cfg.ConnectLimit {
+		return 0, NewConnectLimit(quotaGlobal, cfg.ConnectLimit)
+	}
+
+	if cfg.ConnectLimitPerIP > 0 && q.ips[ip] >= cfg.ConnectLimitPerIP {
+		return 0, NewConnectLimit(quotaIP, cfg.ConnectLimitPerIP)
+	}
+
+	if key != "" && cfg.ConnectLimitPerKey > 0 && q.keys[key] >= cfg.ConnectLimitPerKey {
+		return 0, NewConnectLimit(quotaKey, cfg.ConnectLimitPerKey)
+	}
+
+	q.total++
+	q.ips[ip]++
+
+	left := minRemaining(q.total, cfg.ConnectLimit, -1)
+	left = minRemaining(q.ips[ip], cfg.ConnectLimitPerIP, left)
+
+	if key != "" {
+		q.keys[key]++
+
+		left = minRemaining(q.keys[key], cfg.ConnectLimitPerKey, left)
+	}
+
+	return left, nil
+}
+
+// acquireKey occupy api key quota for connection authorized after connected
+func (q *connQuota) acquireKey(cfg *Config, key string) error {
+	return q.moveKey(cfg, "", key)
+}
+
+// moveKey move api key quota of re-authorized connection from origin key to new key,
+// empty origin key means connection not authorized before.
+func (q *connQuota) moveKey(cfg *Config, origin, key string) error {
+	if origin == key {
+		return nil
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if cfg.ConnectLimitPerKey > 0 && q.keys[key] >= cfg.ConnectLimitPerKey {
+		return NewConnectLimit(quotaKey, cfg.ConnectLimitPerKey)
+	}
+
+	q.keys[key]++
+
+	if origin != "" {
+		q.releaseKeyLocked(origin)
+	}
+
+	return nil
+}
+
+func (q *connQuota) release(ip, key string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.total--
+
+	if q.ips[ip]--; q.ips[ip] <= 0 {
+		delete(q.ips, ip)
+	}
+
+	if key != "" {
+		q.releaseKeyLocked(key)
+	}
+}
+
+func (q *connQuota) releaseKey(key string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.releaseKeyLocked(key)
+}
+
+func (q *connQuota) releaseKeyLocked(key string) {
+	if q.keys[key]--; q.keys[key] <= 0 {
+		delete(q.keys, key)
+	}
+}
+
+func newConnQuota() *connQuota {
+	quota := connQuota{
+		ips:  make(map[string]int),
+		keys: make(map[string]int),
+	}
+
+	return &quota
+}
+
+// parseProxy parse trusted proxy in ip or cidr format, nil if invalid
+func parseProxy(proxy string) *net.IPNet {
+	if _, cidr, err := net.ParseCIDR(proxy); err == nil {
+		return cidr
+	}
+
+	ip := net.ParseIP(proxy)
+	if ip == nil {
+		return nil
+	}
+
+	bits := 8 * net.IPv6len
+	if ip.To4() != nil {
+		ip, bits = ip.To4(), 8*net.IPv4len
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+}
+
+func isTrustedProxy(proxies []string, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	for _, proxy := range proxies {
+		if cidr := parseProxy(proxy); cidr != nil && cidr.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getRemoteIP get client ip from remote addr, X-Forwarded-For header is only honoured
+// if remote addr is a trusted proxy, client ip is the last untrusted address in header.
+func getRemoteIP(r *http.Request, proxies []string) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	if !isTrustedProxy(proxies, ip) {
+		return ip
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header["X-Forwarded-For"], ","), ",")
+
+	for idx := len(forwarded) - 1; idx >= 0; idx-- {
+		if addr := strings.TrimSpace(forwarded[idx]); addr != "" {
+			if ip = addr; !isTrustedProxy(proxies, addr) {
+				break
+			}
+		}
+	}
+
+	return ip
+}
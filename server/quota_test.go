@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestConnQuota(t *testing.T) {
+	cfg := NewConfig()
+	cfg.ConnectLimit = 3
+	cfg.ConnectLimitPerIP = 2
+	cfg.ConnectLimitPerKey = 1
+
+	quota := newConnQuota()
+
+	if left, err := quota.acquire(cfg, "1.1.1.1", "key"); err != nil || left != 0 {
+		t.Fatal("acquire failed:", left, err)
+	}
+
+	if _, err := quota.acquire(cfg, "1.1.1.1", "key"); err == nil || err.(*ErrConnectLimit).scope != quotaKey {
+		t.Fatal("key limit not checked:", err)
+	}
+
+	if left, err := quota.acquire(cfg, "1.1.1.1", ""); err != nil || left != 0 {
+		t.Fatal("acquire failed:", left, err)
+	}
+
+	if _, err := quota.acquire(cfg, "1.1.1.1", ""); err == nil || err.(*ErrConnectLimit).scope != quotaIP {
+		t.Fatal("ip limit not checked:", err)
+	}
+
+	if left, err := quota.acquire(cfg, "2.2.2.2", ""); err != nil || left != 0 {
+		t.Fatal("acquire failed:", left, err)
+	}
+
+	if _, err := quota.acquire(cfg, "3.3.3.3", ""); err == nil || err.(*ErrConnectLimit).scope != quotaGlobal {
+		t.Fatal("server limit not checked:", err)
+	}
+
+	quota.release("1.1.1.1", "key")
+
+	if err := quota.acquireKey(cfg, "key"); err != nil {
+		t.Fatal("key quota not released:", err)
+	}
+
+	if err := quota.acquireKey(cfg, "key"); err == nil {
+		t.Fatal("key limit not checked for authorized session")
+	}
+
+	// re-authorized with other key
+	if err := quota.moveKey(cfg, "key", "other"); err != nil {
+		t.Fatal("move key quota failed:", err)
+	}
+
+	if err := quota.moveKey(cfg, "key", "other"); err == nil {
+		t.Fatal("key limit not checked for re-authorized session")
+	}
+
+	if err := quota.acquireKey(cfg, "key"); err != nil {
+		t.Fatal("origin key quota not released:", err)
+	}
+
+	cfg.ConnectLimit = 0
+	cfg.ConnectLimitPerIP = 0
+
+	if left, err := quota.acquire(cfg, "4.4.4.4", ""); err != nil || left != -1 {
+		t.Fatal("unlimited quota miss-match:", left, err)
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	proxies := []string{"10.0.0.0/8", "192.168.1.1"}
+
+	for _, c := range []struct {
+		remote, forwarded, expect string
+	}{
+		{"1.1.1.1:1234", "", "1.1.1.1"},
+		{"1.1.1.1:1234", "2.2.2.2", "1.1.1.1"},
+		{"192.168.1.1:1234", "2.2.2.2", "2.2.2.2"},
+		{"10.0.0.1:1234", "3.3.3.3, 2.2.2.2, 10.0.0.2", "2.2.2.2"},
+		{"10.0.0.1:1234", "", "10.0.0.1"},
+	} {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = c.remote
+		if c.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+
+		if ip := getRemoteIP(r, proxies); ip != c.expect {
+			t.Fatalf("remote ip for %s forwarded %q miss-match: %s", c.remote, c.forwarded, ip)
+		}
+	}
+
+	cfg := NewConfig()
+	cfg.TrustedProxies = []string{"10.0.0.0/33"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("invalid trusted proxy passed")
+	}
+}
+
+func TestConnectLimit(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	svr.cfg.Load().ConnectLimit = 1
+
+	conn, _, err := websocket.DefaultDialer.Dial(
+		strings.Replace(httpSvr.URL, "http", "ws", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	readTestMessage(t, conn, `"limit":{"remaining":0}`)
+
+	_, rsp, err := websocket.DefaultDialer.Dial(
+		strings.Replace(httpSvr.URL, "http", "ws", 1), nil)
+	if err == nil {
+		t.Fatal("connection limit not checked")
+	}
+
+	if rsp == nil || rsp.StatusCode != http.StatusTooManyRequests {
+		t.Fatal("connection limit response miss-match:", rsp)
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils"
+)
+
+// keySweepInterval interval to evict idle key buckets
+const keySweepInterval = time.Minute
+
+// keyLimiter operation rate limiter shared by sessions authorized with same api key
+type keyLimiter struct {
+	lock      sync.Mutex
+	buckets   map[string]*utils.TokenBucket
+	lastSweep time.Time
+}
+
+// sweep evict buckets refilled to full, full bucket is the same as a newly created one,
+// so eviction never resets rate limit state of active keys.
+func (l *keyLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < keySweepInterval {
+		return
+	}
+
+	for key, bucket := range l.buckets {
+		if bucket.IsFull() {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.lastSweep = now
+}
+
+func (l *keyLimiter) getBucket(cfg *Config, key string) *utils.TokenBucket {
+	if key == "" || cfg.KeyRateLimit <= 0 {
+		return nil
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.sweep(time.Now())
+
+	bucket, exist := l.buckets[key]
+	if !exist {
+		bucket = utils.NewTokenBucket(cfg.KeyRateLimit, cfg.KeyRateBurst)
+		l.buckets[key] = bucket
+	}
+
+	return bucket
+}
+
+func newKeyLimiter() *keyLimiter {
+	limiter := keyLimiter{
+		buckets:   make(map[string]*utils.TokenBucket),
+		lastSweep: time.Now(),
+	}
+
+	return &limiter
+}
+
+func newSessionBucket(cfg *Config) *utils.TokenBucket {
+	if cfg.RateLimit <= 0 {
+		return nil
+	}
+
+	return utils.NewTokenBucket(cfg.RateLimit, cfg.RateBurst)
+}
+
+// checkOpRate take operation token from session's & api key's bucket,
+// token is taken only if both buckets have token available,
+// rate limit error response will be returned if no token available.
+func (s *server) checkOpRate(bucket *utils.TokenBucket, req models.Request, client Session) *models.ErrResponse {
+	ok, wait := utils.TakeAll(bucket, s.keyLimiter.getBucket(s.cfg.Load(), client.GetAPIKey()))
+
+	if ok {
+		return nil
+	}
+
+	retryAfter := int(math.Ceil(wait.Seconds()))
+
+	rsp := models.ErrResponse{
+		Error:  fmt.Sprintf("Rate limit exceeded, retry in %d seconds.", retryAfter),
+		Status: http.StatusTooManyRequests,
+		Meta:   map[string]interface{}{"retryAfter": retryAfter},
+		Request: models.OperationRequest{
+			Operation: req.GetOperation(),
+			Args:      req.GetArgs(),
+		},
+	}
+
+	return &rsp
+}
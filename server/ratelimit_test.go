@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils"
+)
+
+func TestOperationRateLimit(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	svr.cfg.Load().RateLimit = 0.1
+	svr.cfg.Load().RateBurst = 2
+	svr.cfg.Load().RateViolationLimit = 2
+
+	conn := dialTestServer(t, httpSvr)
+	defer conn.Close()
+
+	unsub := models.OperationRequest{Operation: "unsubscribe", Args: []string{"trade"}}
+
+	for i := 0; i < 2; i++ {
+		conn.WriteJSON(unsub)
+		readTestMessage(t, conn, `"unsubscribe":"trade"`)
+	}
+
+	conn.WriteJSON(unsub)
+	readTestMessage(t, conn, `"meta":{"retryAfter":10}`)
+
+	conn.WriteJSON(unsub)
+	readTestMessage(t, conn, `"status":429`)
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, msg, err := conn.ReadMessage(); err == nil {
+		t.Fatal("session not closed after rate limit violations:", string(msg))
+	}
+}
+
+func TestKeyRateLimit(t *testing.T) {
+	cfg := NewConfig()
+	cfg.RateLimit = 1
+	cfg.KeyRateLimit = 1
+	cfg.KeyRateBurst = 1
+
+	limiter := newKeyLimiter()
+
+	if limiter.getBucket(cfg, "") != nil {
+		t.Fatal("bucket created for unauthorized session")
+	}
+
+	bucket := limiter.getBucket(cfg, "testKey")
+	if bucket != limiter.getBucket(cfg, "testKey") {
+		t.Fatal("bucket not shared by same key")
+	}
+
+	if ok, _ := bucket.Take(); !ok {
+		t.Fatal("take token failed")
+	}
+
+	if ok, _ := limiter.getBucket(cfg, "testKey").Take(); ok {
+		t.Fatal("key rate limit not checked")
+	}
+
+	sessionBucket := newSessionBucket(cfg)
+	if ok, _ := utils.TakeAll(sessionBucket, bucket); ok {
+		t.Fatal("key rate limit not checked")
+	}
+
+	if !sessionBucket.IsFull() {
+		t.Fatal("session token taken with key rate limited")
+	}
+
+	limiter.lastSweep = time.Now().Add(-keySweepInterval)
+	limiter.getBucket(cfg, "otherKey")
+
+	if _, exist := limiter.buckets["testKey"]; !exist {
+		t.Fatal("key bucket in use evicted")
+	}
+
+	time.Sleep(time.Second)
+
+	limiter.lastSweep = time.Now().Add(-keySweepInterval)
+	limiter.getBucket(cfg, "otherKey")
+
+	if _, exist := limiter.buckets["testKey"]; exist || len(limiter.buckets) != 1 {
+		t.Fatal("idle key bucket not evicted")
+	}
+}
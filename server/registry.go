@@ -0,0 +1,116 @@
+package server
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/frozenpine/wstester/utils"
+)
+
+// CacheRegistry registry for table caches identified by (table, symbol)
+type CacheRegistry interface {
+	// Register register cache for table & symbol, origin cache will be replaced.
+	Register(table, symbol string, cache utils.Cache)
+	// GetCache get cache specified by table & symbol, nil returned if not exist.
+	GetCache(table, symbol string) utils.Cache
+	// GetCaches get caches for table, empty symbol means all symbols' cache in table,
+	// caches are ordered by symbol name.
+	GetCaches(table, symbol string) []utils.Cache
+	// GetSymbols get registered symbols for table in order.
+	GetSymbols(table string) []string
+	// GetTables get registered table names in order.
+	GetTables() []string
+}
+
+type cacheRegistry struct {
+	caches map[string]map[string]utils.Cache
+	lock   sync.RWMutex
+}
+
+func (r *cacheRegistry) Register(table, symbol string, cache utils.Cache) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	symbolCaches, exist := r.caches[table]
+	if !exist {
+		symbolCaches = make(map[string]utils.Cache)
+		r.caches[table] = symbolCaches
+	}
+
+	symbolCaches[symbol] = cache
+}
+
+func (r *cacheRegistry) GetCache(table, symbol string) utils.Cache {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if symbolCaches, exist := r.caches[table]; exist {
+		return symbolCaches[symbol]
+	}
+
+	return nil
+}
+
+func (r *cacheRegistry) GetCaches(table, symbol string) []utils.Cache {
+	if symbol != "" {
+		if cache := r.GetCache(table, symbol); cache != nil {
+			return []utils.Cache{cache}
+		}
+
+		return nil
+	}
+
+	var caches []utils.Cache
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, sym := range r.symbols(table) {
+		caches = append(caches, r.caches[table][sym])
+	}
+
+	return caches
+}
+
+func (r *cacheRegistry) symbols(table string) []string {
+	var symbols []string
+
+	for symbol := range r.caches[table] {
+		symbols = append(symbols, symbol)
+	}
+
+	sort.Strings(symbols)
+
+	return symbols
+}
+
+func (r *cacheRegistry) GetSymbols(table string) []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.symbols(table)
+}
+
+func (r *cacheRegistry) GetTables() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	var tables []string
+
+	for table := range r.caches {
+		tables = append(tables, table)
+	}
+
+	sort.Strings(tables)
+
+	return tables
+}
+
+// NewCacheRegistry create a new cache registry
+func NewCacheRegistry() CacheRegistry {
+	registry := cacheRegistry{
+		caches: make(map[string]map[string]utils.Cache),
+	}
+
+	return &registry
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/frozenpine/wstester/utils"
+)
+
+func TestCacheRegistry(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	registry := NewCacheRegistry()
+
+	xbt := utils.NewTradeCache(ctx, "XBTUSD")
+	eth := utils.NewTradeCache(ctx, "ETHUSD")
+
+	registry.Register("trade", "XBTUSD", xbt)
+	registry.Register("trade", "ETHUSD", eth)
+
+	if caches := registry.GetCaches("trade", "ETHUSD"); len(caches) != 1 || caches[0] != eth {
+		t.Fatal("get symbol cache failed:", caches)
+	}
+
+	if caches := registry.GetCaches("trade", ""); len(caches) != 2 || caches[0] != eth || caches[1] != xbt {
+		t.Fatal("get fan in caches failed:", caches)
+	}
+
+	if caches := registry.GetCaches("trade", "LTCUSD"); len(caches) != 0 {
+		t.Fatal("get non-exist symbol cache failed:", caches)
+	}
+
+	if caches := registry.GetCaches("quote", ""); len(caches) != 0 {
+		t.Fatal("get non-exist table cache failed:", caches)
+	}
+
+	if symbols := registry.GetSymbols("trade"); len(symbols) != 2 || symbols[0] != "ETHUSD" {
+		t.Fatal("get symbols failed:", symbols)
+	}
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/frozenpine/wstester/utils"
+	"github.com/frozenpine/wstester/utils/log"
+)
+
+// ReloadResult result for reload request
+type ReloadResult struct {
+	Success bool     `json:"success"`
+	Restart []string `json:"restart,omitempty"`
+}
+
+// restartFields get fields' name which changed but can not be applied to running server
+func restartFields(origin, cfg *Config) []string {
+	var fields []string
+
+	check := func(name string, changed bool) {
+		if changed {
+			fields = append(fields, name)
+		}
+	}
+
+	check("listen", !origin.Listen.Equal(cfg.Listen))
+	check("port", origin.Port != cfg.Port)
+	check("base_uri", origin.BaseURI != cfg.BaseURI)
+	check("signature_uri", origin.SignatureURI != cfg.SignatureURI)
+	check("tls", !reflect.DeepEqual(origin.TLS, cfg.TLS))
+	check("send_queue", !reflect.DeepEqual(origin.SendQueue, cfg.SendQueue))
+	check("prepared_message", origin.PreparedMessage != cfg.PreparedMessage)
+	check("front_id", origin.FrontID != cfg.FrontID)
+	check("symbols", strings.Join(origin.Symbols, ",") != strings.Join(cfg.Symbols, ","))
+	check("mock_mode", origin.MockMode != cfg.MockMode)
+	check("upstream", !reflect.DeepEqual(origin.Upstream, cfg.Upstream))
+	check("record", origin.Record != cfg.Record)
+	check("replay", origin.Replay.File != cfg.Replay.File)
+	check("generator", origin.Generator.Seed != cfg.Generator.Seed ||
+		origin.Generator.MidPrice != cfg.Generator.MidPrice || origin.Generator.TickSize != cfg.Generator.TickSize)
+	check("key_store", origin.KeyStore != cfg.KeyStore)
+	check("notify", !reflect.DeepEqual(origin.Notify, cfg.Notify))
+	check("rate_limit", origin.RateLimit != cfg.RateLimit || origin.RateBurst != cfg.RateBurst)
+	check("key_rate_limit", origin.KeyRateLimit != cfg.KeyRateLimit || origin.KeyRateBurst != cfg.KeyRateBurst)
+	check("reverse_heartbeat", origin.ReversHeartbeat != cfg.ReversHeartbeat)
+
+	return fields
+}
+
+func (s *server) ReloadCfg(cfg *Config) ([]string, error) {
+	if cfg == nil {
+		return nil, errors.New("config is empty")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.reloadMux.Lock()
+	defer s.reloadMux.Unlock()
+
+	origin := s.cfg.Load()
+	restart := restartFields(origin, cfg)
+
+	hbChanged := origin.HeartbeatInterval != cfg.HeartbeatInterval ||
+		origin.HeartbeatFailCount != cfg.HeartbeatFailCount
+
+	// running sessions read config without lock, so live fields are applied to a copy
+	applied := *origin
+
+	applied.WelcomMsg = cfg.WelcomMsg
+	applied.DocsURI = cfg.DocsURI
+	applied.ConnectLimit = cfg.ConnectLimit
+	applied.ConnectLimitPerIP = cfg.ConnectLimitPerIP
+	applied.ConnectLimitPerKey = cfg.ConnectLimitPerKey
+	applied.TrustedProxies = cfg.TrustedProxies
+	applied.AdminKey = cfg.AdminKey
+	applied.HeartbeatInterval = cfg.HeartbeatInterval
+	applied.HeartbeatFailCount = cfg.HeartbeatFailCount
+	applied.RateViolationLimit = cfg.RateViolationLimit
+	// applied to new sessions, running sessions' chaos config can be changed by admin api
+	applied.Chaos = cfg.Chaos
+
+	generator := *origin.Generator
+	generator.Volatility = cfg.Generator.Volatility
+	generator.Depth = cfg.Generator.Depth
+	generator.OrderRate = cfg.Generator.OrderRate
+	generator.TradeRate = cfg.Generator.TradeRate
+	generator.MaxSize = cfg.Generator.MaxSize
+	applied.Generator = &generator
+
+	replay := *origin.Replay
+	replay.Speed = cfg.Replay.Speed
+	applied.Replay = &replay
+
+	applied.ConflateInterval = cfg.ConflateInterval
+
+	s.cfg.Store(&applied)
+
+	for _, generator := range s.generators {
+		generator.SetConfig(applied.Generator)
+	}
+
+	if origin.ConflateInterval != applied.ConflateInterval {
+		for _, cache := range s.dataCaches.GetCaches("orderBookL2", "") {
+			if mbl, ok := cache.(*utils.MBLCache); ok {
+				mbl.SetConflateInterval(applied.GetConflateInterval())
+			}
+		}
+	}
+
+	if origin.Replay.Speed != applied.Replay.Speed && s.replay != nil {
+		s.replay.SetSpeed(applied.Replay.Speed)
+	}
+
+	if hbChanged {
+		s.clientLock.RLock()
+		for _, session := range s.clients {
+			session.ResetHeartbeat()
+		}
+		s.clientLock.RUnlock()
+	}
+
+	if len(restart) > 0 {
+		log.Warnf("Config reloaded, changes need restart to take effect: %s", strings.Join(restart, ", "))
+	} else {
+		log.Info("Config reloaded.")
+	}
+
+	return restart, nil
+}
+
+func (s *server) SetConfigLoader(loader func() (*Config, error)) {
+	s.reloadMux.Lock()
+	s.cfgLoader = loader
+	s.reloadMux.Unlock()
+}
+
+func (s *server) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	writeErr := func(status int, err error) {
+		writeHTTPError(w, status, err, nil)
+	}
+
+	if r.Method != http.MethodPost {
+		writeErr(http.StatusMethodNotAllowed, errors.New("reload must be requested with POST method"))
+		return
+	}
+
+	s.reloadMux.Lock()
+	loader := s.cfgLoader
+	s.reloadMux.Unlock()
+
+	if loader == nil {
+		writeErr(http.StatusNotImplemented, errors.New("no config loader for reload"))
+		return
+	}
+
+	cfg, err := loader()
+	if err != nil {
+		writeErr(http.StatusBadRequest, err)
+		return
+	}
+
+	restart, err := s.ReloadCfg(cfg)
+	if err != nil {
+		writeErr(http.StatusBadRequest, err)
+		return
+	}
+
+	result, _ := json.Marshal(ReloadResult{Success: true, Restart: restart})
+
+	w.Header().Set("Content-type", "application/json")
+	w.Write(result)
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestReloadCfg(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	conn := dialTestServer(t, httpSvr)
+	defer conn.Close()
+
+	cfg := NewConfig()
+	cfg.Port = 19988
+	cfg.WelcomMsg = "Reloaded."
+	cfg.HeartbeatInterval = 5
+
+	restart, err := svr.ReloadCfg(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(restart) != 1 || restart[0] != "port" {
+		t.Fatal("restart fields miss-match:", restart)
+	}
+
+	if svr.cfg.Load().Port != defaultPort || svr.cfg.Load().HeartbeatInterval != 5 {
+		t.Fatal("live fields not applied or restart fields changed.")
+	}
+
+	newConn, _, err := websocket.DefaultDialer.Dial(
+		strings.Replace(httpSvr.URL, "http", "ws", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer newConn.Close()
+
+	readTestMessage(t, newConn, "Reloaded.")
+
+	cfg.MockMode = "invalid"
+	if _, err := svr.ReloadCfg(cfg); err == nil {
+		t.Fatal("invalid config reloaded.")
+	}
+}
+
+func TestReloadGenerator(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	cfg := NewConfig()
+	cfg.Generator.Volatility = 0.01
+	cfg.Generator.MidPrice = 100
+
+	restart, err := svr.ReloadCfg(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(restart) != 1 || restart[0] != "generator" {
+		t.Fatal("restart fields miss-match:", restart)
+	}
+
+	if svr.cfg.Load().Generator.Volatility != 0.01 || svr.cfg.Load().Generator.MidPrice == 100 {
+		t.Fatal("live generator fields not applied or restart fields changed.")
+	}
+
+	cfg.Generator.Depth = 0
+	if _, err := svr.ReloadCfg(cfg); err == nil {
+		t.Fatal("invalid generator config reloaded.")
+	}
+}
+
+func TestReloadHandler(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	reload := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		svr.reloadHandler(w, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+
+		return w
+	}
+
+	if w := reload(); w.Code != http.StatusNotImplemented {
+		t.Fatal("reload without loader should not be implemented:", w.Code)
+	}
+
+	svr.SetConfigLoader(func() (*Config, error) {
+		return nil, errors.New("load failed")
+	})
+	if w := reload(); w.Code != http.StatusBadRequest {
+		t.Fatal("load failure not reported:", w.Code)
+	}
+
+	svr.SetConfigLoader(func() (*Config, error) {
+		cfg := NewConfig()
+		cfg.Symbols = []string{"XBTUSD", "ETHUSD"}
+
+		return cfg, nil
+	})
+	if w := reload(); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"restart":["symbols"]`) {
+		t.Fatal("reload result miss-match:", w.Code, w.Body.String())
+	}
+}
@@ -10,13 +10,18 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/frozenpine/wstester/client"
+	"github.com/frozenpine/wstester/kafka"
 	"github.com/frozenpine/wstester/mock"
 	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/orderbook"
 	"github.com/frozenpine/wstester/utils"
 	"github.com/frozenpine/wstester/utils/log"
+	"github.com/frozenpine/wstester/utils/metrics"
 	"github.com/gorilla/websocket"
 )
 
@@ -25,6 +30,11 @@ var (
 	// logLevel int
 
 	opPattern = []byte(`"op"`)
+
+	shutdownTimeout = time.Second * 5
+
+	depthPattern = regexp.MustCompile(`^(L2_)?(\d+)$`)
+	depthTopic   = []string{"orderBook"}
 )
 
 type serverStatics struct {
@@ -43,23 +53,38 @@ type Status struct {
 type Server interface {
 	// RunForever startup and serve forever
 	RunForever(ctx context.Context) error
-	// ReloadCfg reload server config
-	ReloadCfg(*Config)
+	// ReloadCfg reload server config, changes will be applied to running server if possible,
+	// fields which need restart to take effect will be returned.
+	ReloadCfg(*Config) ([]string, error)
+	// SetConfigLoader set config loader for reload request from admin api
+	SetConfigLoader(func() (*Config, error))
 }
 
 type server struct {
-	cfg      *Config
+	cfg      *liveConfig
 	ctx      context.Context
 	upgrader *websocket.Upgrader
 
 	statics serverStatics
 
 	clients    map[string]Session
-	dataCaches map[string]utils.Cache
-}
-
-func (s *server) ReloadCfg(cfg *Config) {
-	*s.cfg = *cfg
+	clientLock sync.RWMutex
+	dataCaches CacheRegistry
+	engines    map[string]*orderbook.Engine
+	orders     *orderStore
+	generators []*mock.Generator
+	replay     *mock.Replay
+	recorder   *client.Recorder
+	keyStore   KeyStore
+	connQuota  *connQuota
+	keyLimiter *keyLimiter
+
+	httpServer *http.Server
+	dataCancel context.CancelFunc
+
+	cfgLoader func() (*Config, error)
+	reloadMux sync.Mutex
+	orderMux  sync.Mutex
 }
 
 func (s *server) RunForever(ctx context.Context) error {
@@ -68,34 +93,189 @@ func (s *server) RunForever(ctx context.Context) error {
 	}
 	s.ctx = ctx
 
-	http.HandleFunc("/status", s.statusHandler)
-	http.HandleFunc(s.cfg.BaseURI, s.wsUpgrader)
+	cfg := s.cfg.Load()
+
+	if cfg.Notify != nil && cfg.Notify.IsEnabled() {
+		consumer, err := kafka.NewConsumer(ctx, cfg.Notify)
+		if err != nil {
+			s.stopData()
+
+			return err
+		}
+
+		go s.consumeNotify(consumer)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.statusHandler)
+	mux.HandleFunc("/admin/reload", s.adminAuth(s.reloadHandler))
+	mux.HandleFunc("/metrics", s.metricsHandler)
+	mux.HandleFunc(adminSessionsURI, s.adminAuth(s.sessionsHandler))
+	mux.HandleFunc(adminSessionsURI+"/", s.adminAuth(s.sessionsHandler))
+	mux.HandleFunc(adminCachesURI, s.adminAuth(s.cachesHandler))
+	mux.HandleFunc(adminReplayURI, s.adminAuth(s.replayHandler))
+	mux.HandleFunc(orderURI, s.orderHandler)
+	mux.HandleFunc(orderAllURI, s.orderHandler)
+	mux.HandleFunc(cfg.BaseURI, s.wsUpgrader)
+
+	s.httpServer = &http.Server{
+		Addr:    cfg.GetListenAddr(),
+		Handler: mux,
+	}
 
 	s.statics.Startup = time.Now().UTC()
-	err := http.ListenAndServe(s.cfg.GetListenAddr(), nil)
+
+	clientsLabels := s.metricLabels(nil)
+	metrics.DefaultRegistry.GaugeFunc(
+		"wstester_clients", "Connected client sessions.", clientsLabels,
+		func() float64 { return float64(atomic.LoadInt64(&s.statics.Clients)) })
+	defer metrics.DefaultRegistry.Unregister("wstester_clients", clientsLabels)
+
+	errChan := make(chan error, 1)
+
+	if cfg.TLS.IsEnabled() {
+		tlsCfg, err := cfg.TLS.getTLSConfig(cfg.Listen.String())
+		if err != nil {
+			s.stopData()
+
+			return err
+		}
+
+		s.httpServer.TLSConfig = tlsCfg
+
+		go func() {
+			errChan <- s.httpServer.ListenAndServeTLS("", "")
+		}()
+
+		log.Infof("Server listening on wss://%s%s", cfg.GetListenAddr(), cfg.BaseURI)
+	} else {
+		go func() {
+			errChan <- s.httpServer.ListenAndServe()
+		}()
+
+		log.Infof("Server listening on ws://%s%s", cfg.GetListenAddr(), cfg.BaseURI)
+	}
+
+	select {
+	case err := <-errChan:
+		s.closeClients()
+		s.stopData()
+
+		return err
+	case <-ctx.Done():
+		return s.shutdown()
+	}
+}
+
+// shutdown stop listener, close all client sessions and stop data caches
+func (s *server) shutdown() error {
+	log.Info("Server shutting down.")
+
+	shutdownCtx, cancelFn := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelFn()
+
+	err := s.httpServer.Shutdown(shutdownCtx)
+
+	s.closeClients()
+	s.stopData()
+
+	log.Info("Server stopped.")
 
 	return err
 }
 
-func (s *server) incClients(conn *websocket.Conn, req *http.Request) Session {
+func (s *server) closeClients() {
+	s.clientLock.RLock()
+	sessions := make([]Session, 0, len(s.clients))
+	for _, session := range s.clients {
+		sessions = append(sessions, session)
+	}
+	s.clientLock.RUnlock()
+
+	for _, session := range sessions {
+		session.Close(websocket.CloseGoingAway, "Server shutting down.")
+	}
+}
+
+// stopData stop all data caches & mock data sources
+func (s *server) stopData() {
+	stopped := make(map[utils.Cache]bool)
+
+	for _, table := range s.dataCaches.GetTables() {
+		for _, cache := range s.dataCaches.GetCaches(table, "") {
+			if stopped[cache] {
+				continue
+			}
+
+			if err := cache.Stop(); err != nil {
+				log.Debugf("Stop %s cache failed: %v", table, err)
+			}
+
+			stopped[cache] = true
+		}
+	}
+
+	if s.dataCancel != nil {
+		s.dataCancel()
+	}
+
+	if s.recorder != nil {
+		if err := s.recorder.Close(); err != nil {
+			log.Error("Close recorder failed: ", err)
+		}
+	}
+}
+
+func (s *server) incClients(conn *websocket.Conn, req *http.Request, remaining int) Session {
 	clientCtx := context.WithValue(s.ctx, SvrConfigKey, s.cfg)
 
 	session := NewSession(clientCtx, conn, req)
 
-	if err := session.Welcome(); err != nil {
+	var limit map[string]interface{}
+	if remaining >= 0 {
+		limit = map[string]interface{}{"remaining": remaining}
+	}
+
+	if err := session.Welcome(limit); err != nil {
 		log.Error(err)
 		session.Close(-1, "Send welcom message failed.")
 
 		return nil
 	}
 
+	s.clientLock.Lock()
 	s.clients[session.GetID()] = session
+	s.clientLock.Unlock()
+
+	labels := s.metricLabels(metrics.Labels{"session": session.GetID()})
+	metrics.DefaultRegistry.GaugeFunc(
+		"wstester_session_queue_depth", "Messages waiting for sending in session.", labels,
+		func() float64 { return float64(session.QueueDepth()) })
+	metrics.DefaultRegistry.CounterFunc(
+		"wstester_session_dropped_messages_total", "Data messages dropped for slow consumer in session.", labels,
+		func() float64 { return float64(session.GetStatus().Dropped) })
+	metrics.DefaultRegistry.CounterFunc(
+		"wstester_session_conflated_messages_total", "Data messages conflated for slow consumer in session.", labels,
+		func() float64 { return float64(session.GetStatus().Conflated) })
+
 	atomic.AddInt64(&s.statics.Clients, 1)
 	log.Infof("Client session[%s] connected from: %s.", session.GetID(), session.GetAddr().String())
 
 	return session
 }
 
+// metricLabels add server label to metric labels,
+// so metrics of servers in one process never share series.
+func (s *server) metricLabels(labels metrics.Labels) metrics.Labels {
+	if labels == nil {
+		labels = make(metrics.Labels)
+	}
+
+	labels["server"] = s.cfg.Load().GetListenAddr()
+
+	return labels
+}
+
 func (s *server) decClients(session interface{}) {
 	if session == nil {
 		return
@@ -103,6 +283,9 @@ func (s *server) decClients(session interface{}) {
 
 	var client Session
 
+	s.clientLock.Lock()
+	defer s.clientLock.Unlock()
+
 	switch session.(type) {
 	case string:
 		client = s.clients[session.(string)]
@@ -117,29 +300,34 @@ func (s *server) decClients(session interface{}) {
 	delete(s.clients, client.GetID())
 	atomic.AddInt64(&s.statics.Clients, -1)
 
+	labels := s.metricLabels(metrics.Labels{"session": client.GetID()})
+	metrics.DefaultRegistry.Unregister("wstester_session_queue_depth", labels)
+	metrics.DefaultRegistry.Unregister("wstester_session_dropped_messages_total", labels)
+	metrics.DefaultRegistry.Unregister("wstester_session_conflated_messages_total", labels)
+
 	log.Infof("Client session[%s] disconnected.", client.GetID())
 }
 
-func (s *server) getReqAuth(r *http.Request) error {
+func (s *server) getReqAuth(r *http.Request) (*APIKey, error) {
 	apiKey := r.Header.Get("api-key")
 	if apiKey == "" {
-
+		return nil, nil
 	}
 
 	apiSignature := r.Header.Get("api-signature")
 	if apiSignature == "" {
-
+		return nil, ErrMissingSignature
 	}
 
 	apiExpires, err := strconv.ParseInt(r.Header.Get("api-expires"), 10, 64)
 	if err != nil {
-
-	}
-	if apiExpires > time.Now().Unix() {
-		return NewAPIExpires(apiExpires)
+		return nil, ErrMissingSignature
 	}
 
-	return nil
+	cfg := s.cfg.Load()
+
+	return CheckSignature(
+		s.keyStore, apiKey, apiSignature, apiExpires, cfg.SignatureURI, cfg.BaseURI)
 }
 
 func (s *server) getReqSubscribe(r *http.Request, c Session) *models.OperationRequest {
@@ -172,73 +360,254 @@ func (s *server) parseOperation(msg []byte) (*models.OperationRequest, error) {
 }
 
 func (s *server) handleAuth(req models.Request, client Session) models.Response {
-	// TODO: client authentication & authorization
-	return nil
+	args := req.GetArgs()
+
+	request := map[string]interface{}{
+		"op":   req.GetOperation(),
+		"args": args,
+	}
+
+	authErr := func(err error) models.Response {
+		rsp := models.ErrResponse{
+			Error:  err.Error(),
+			Status: http.StatusUnauthorized,
+			Request: models.OperationRequest{
+				Operation: req.GetOperation(),
+				Args:      args,
+			},
+		}
+
+		client.WriteJSONMessage(&rsp, false)
+
+		return &rsp
+	}
+
+	if len(args) != 3 {
+		return authErr(ErrMissingSignature)
+	}
+
+	expires, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return authErr(ErrMissingSignature)
+	}
+
+	cfg := s.cfg.Load()
+
+	apiKey, err := CheckSignature(
+		s.keyStore, args[0], args[2], expires, cfg.SignatureURI, cfg.BaseURI)
+	if err != nil {
+		log.Warnf("Client session[%s] authentication failed: %v", client.GetID(), err)
+
+		return authErr(err)
+	}
+
+	// connection's key quota is released by wsUpgrader with session's api key
+	if err := s.connQuota.moveKey(cfg, client.GetAPIKey(), apiKey.Key); err != nil {
+		log.Warnf("Client session[%s] authentication rejected: %v", client.GetID(), err)
+
+		rsp := models.ErrResponse{
+			Error:  err.Error(),
+			Status: http.StatusTooManyRequests,
+			Meta:   err.(*ErrConnectLimit).Meta(),
+			Request: models.OperationRequest{
+				Operation: req.GetOperation(),
+				Args:      args,
+			},
+		}
+
+		client.WriteJSONMessage(&rsp, false)
+
+		return &rsp
+	}
+
+	client.Authorize(apiKey.Key, apiKey.ClientID, apiKey.AccountID)
+
+	rsp := models.AuthResponse{
+		Success: true,
+		Request: request,
+	}
+
+	client.WriteJSONMessage(&rsp, false)
+
+	return &rsp
+}
+
+// parseTopic parse table, symbol & channel depth from topic,
+// orderBookL2_25 for realtime channel in depth 25, orderBook10 for snapshot channel in depth 10.
+func (s *server) parseTopic(topicStr string) (table, symbol string, chType utils.ChannelType, depth int) {
+	parsed := strings.SplitN(topicStr, ":", 2)
+
+	table = parsed[0]
+	if len(parsed) > 1 {
+		symbol = parsed[1]
+	}
+
+	for _, topic := range depthTopic {
+		if strings.HasPrefix(table, topic) {
+			match := depthPattern.FindStringSubmatch(table[len(topic):])
+
+			if len(match) > 0 {
+				depth, _ = strconv.Atoi(match[2])
+
+				if match[1] == "" {
+					chType = utils.Snapshot
+				}
+			}
+		}
+	}
+
+	return
+}
+
+// subscription forwarding state of subscribed data
+type subscription struct {
+	lock    sync.Mutex
+	stopped bool
+}
+
+// stop stop forwarding data, no data will be forwarded after stop returned,
+// so data buffered in destination is discarded once unsubscribe acknowledged.
+func (sub *subscription) stop() {
+	sub.lock.Lock()
+	sub.stopped = true
+	sub.lock.Unlock()
+}
+
+// forward call fn to forward data if subscription not stopped
+func (sub *subscription) forward(fn func()) bool {
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+
+	if sub.stopped {
+		return false
+	}
+
+	fn()
+
+	return true
+}
+
+// takeSnapshot publish cache snapshot to destination session of channel as partial
+func takeSnapshot(cache utils.Cache, rspChan utils.Channel, chType utils.ChannelType, depth int, session string) {
+	if book, ok := cache.(*utils.MBLCache); ok && chType == utils.Snapshot {
+		book.TakeBookSnapshot(depth, rspChan, session)
+	} else {
+		cache.TakeSnapshot(depth, rspChan, session)
+	}
+}
+
+// newUnknownTableRsp make error response for topic without any table cache
+func newUnknownTableRsp(req models.Request, topic string) *models.ErrResponse {
+	rsp := models.ErrResponse{
+		Error:  "Unknown or expired table: " + topic,
+		Status: http.StatusBadRequest,
+		Request: models.OperationRequest{
+			Operation: req.GetOperation(),
+			Args:      req.GetArgs(),
+		},
+	}
+
+	return &rsp
 }
 
 func (s *server) handleSubscribe(req models.Request, client Session) []models.Response {
-	var (
-		rspList      []models.Response
-		cache        utils.Cache
-		exist        bool
-		depthPattern = regexp.MustCompile(`(?:L2_)(\d+)`)
-		depthTopic   = []string{"orderBook"}
-	)
+	var rspList []models.Response
 
 	for _, topicStr := range req.GetArgs() {
-		parsed := strings.Split(topicStr, ":")
-		// TODO: handle symbol
-		topicName := parsed[0]
+		if client.IsSubscribed(topicStr) {
+			rsp := models.ErrResponse{
+				Error:  "You are already subscribed to this topic: " + topicStr,
+				Status: http.StatusBadRequest,
+				Request: models.OperationRequest{
+					Operation: req.GetOperation(),
+					Args:      req.GetArgs(),
+				},
+			}
 
-		waitRsp := make(chan bool, 0)
+			rspList = append(rspList, &rsp)
+			client.WriteJSONMessage(&rsp, false)
+
+			continue
+		}
 
-		if cache, exist = s.dataCaches[topicName]; exist {
-			// TODO: private flow subscribe
-			// cache.Subscribe() or something like
+		tableName, symbol, chType, depth := s.parseTopic(topicStr)
 
-			depth := 0
+		if _, isPrivate := privateTables[tableName]; isPrivate {
+			rspList = append(rspList, s.handlePrivateSubscribe(req, topicStr, client))
+			continue
+		}
 
-			for _, topic := range depthTopic {
-				if strings.HasPrefix(topicName, topic) {
-					match := depthPattern.FindStringSubmatch(topicName[len(topic):])
+		symbols := []string{symbol}
+		if symbol == "" {
+			symbols = s.dataCaches.GetSymbols(tableName)
+		}
 
-					if len(match) > 0 {
-						depth, _ = strconv.Atoi(match[1])
-					}
-				}
+		waitRsp := make(chan bool, 0)
+		subscribed := 0
+
+		// subscribe without symbol will fan in all symbols' data in table
+		for _, sym := range symbols {
+			cache := s.dataCaches.GetCache(tableName, sym)
+			if cache == nil {
+				continue
 			}
+			subscribed++
 
-			go func(cache utils.Cache, chType utils.ChannelType, depth int) {
-				<-waitRsp
+			rspChan := cache.GetRspChannel(chType, depth)
 
-				rspChan := cache.GetRspChannel(chType, depth)
-
-				if rspChan == nil {
-					err := models.ErrResponse{
-						Error: fmt.Sprintf("Fail to get response Channel for %s on depth %d", topicName, depth),
-						Request: models.OperationRequest{
-							Operation: req.GetOperation(),
-							Args:      req.GetArgs(),
-						},
-					}
-					client.WriteJSONMessage(&err, false)
-					client.Close(-1, err.Error)
-					return
+			if rspChan == nil {
+				err := models.ErrResponse{
+					Error: fmt.Sprintf("Fail to get response Channel for %s on depth %d", tableName, depth),
+					Request: models.OperationRequest{
+						Operation: req.GetOperation(),
+						Args:      req.GetArgs(),
+					},
 				}
+				client.WriteJSONMessage(&err, false)
+				client.Close(-1, err.Error)
+				return append(rspList, &err)
+			}
+
+			session, dataChan := rspChan.RetriveSharedData()
+			sub := subscription{}
+			client.Subscribe(topicStr, func() {
+				sub.stop()
+				rspChan.ShutdownRetrive(session)
+			})
+			client.WatchQueue(topicStr, func() int { return len(dataChan) })
 
-				session, dataChan := rspChan.RetriveData()
-				client.SetCleanup(func() { rspChan.ShutdownRetrive(session) })
+			outMeter := metrics.DefaultRegistry.Meter(
+				"wstester_table_out_messages", "Outbound table messages sent to sessions.",
+				s.metricLabels(metrics.Labels{"table": tableName, "symbol": sym}))
+
+			go func(cache utils.Cache, rspChan utils.Channel, depth int) {
+				<-waitRsp
 
-				cache.TakeSnapshot(depth, rspChan, session)
+				// destination is drained while taking snapshot, so dispatch never blocked by this session
+				go takeSnapshot(cache, rspChan, chType, depth, session)
 
+				// destination is drained after unsubscribed, so ShutdownRetrive never blocked
 				for data := range dataChan {
-					client.WriteJSONMessage(data, false)
+					sub.forward(func() {
+						if client.WriteTopicMessage(topicStr, data) == nil {
+							outMeter.Mark(1)
+						}
+					})
 				}
-			}(cache, utils.Realtime, depth)
+			}(cache, rspChan, depth)
+		}
+
+		if subscribed == 0 {
+			rsp := newUnknownTableRsp(req, topicStr)
+
+			rspList = append(rspList, rsp)
+			client.WriteJSONMessage(rsp, false)
+
+			continue
 		}
 
 		rsp := models.SubscribeResponse{
-			Success:   exist,
+			Success:   true,
 			Subscribe: topicStr,
 			Request:   *req.(*models.OperationRequest),
 		}
@@ -252,32 +621,98 @@ func (s *server) handleSubscribe(req models.Request, client Session) []models.Re
 	return rspList
 }
 
+func (s *server) handleUnsubscribe(req models.Request, client Session) []models.Response {
+	var rspList []models.Response
+
+	for _, topicStr := range req.GetArgs() {
+		err := client.UnSubscribe(topicStr)
+		if err != nil {
+			log.Warnf("Client session[%s] unsubscribe failed: %v", client.GetID(), err)
+		}
+
+		rsp := models.UnsubscribeResponse{
+			Success:     err == nil,
+			Unsubscribe: topicStr,
+			Request:     *req.(*models.OperationRequest),
+		}
+
+		rspList = append(rspList, &rsp)
+		client.WriteJSONMessage(&rsp, false)
+	}
+
+	return rspList
+}
+
 func (s *server) wsUpgrader(w http.ResponseWriter, r *http.Request) {
 	var (
-		conn *websocket.Conn
-		err  error
+		conn   *websocket.Conn
+		apiKey *APIKey
+		err    error
 	)
 
-	if err = s.getReqAuth(r); err != nil {
-		log.Error(err)
+	if apiKey, err = s.getReqAuth(r); err != nil {
+		log.Warnf("Client from %s authentication failed: %v", r.RemoteAddr, err)
+
+		writeHTTPError(w, http.StatusUnauthorized, err, nil)
+
 		return
 	}
 
+	remoteIP := getRemoteIP(r, s.cfg.Load().TrustedProxies)
+	var (
+		key            string
+		clientSenssion Session
+	)
+	if apiKey != nil {
+		key = apiKey.Key
+	}
+
+	remaining, err := s.connQuota.acquire(s.cfg.Load(), remoteIP, key)
+	if err != nil {
+		log.Warnf("Client from %s rejected: %v", r.RemoteAddr, err)
+
+		w.Header().Set("Retry-After", "1")
+		writeHTTPError(w, http.StatusTooManyRequests, err, err.(*ErrConnectLimit).Meta())
+
+		return
+	}
+	defer func() {
+		// api key may be changed by re-authorization
+		if clientSenssion != nil {
+			key = clientSenssion.GetAPIKey()
+		}
+
+		s.connQuota.release(remoteIP, key)
+	}()
+
 	conn, err = s.upgrader.Upgrade(w, r, w.Header())
 
 	if err != nil {
-		http.Error(w, err.Error(), 400)
+		log.Error(err)
+		return
 	}
 
-	clientSenssion := s.incClients(conn, r)
+	clientSenssion = s.incClients(conn, r, remaining)
+	if clientSenssion == nil {
+		return
+	}
 	defer func() {
 		s.decClients(clientSenssion)
 	}()
 
+	if apiKey != nil {
+		clientSenssion.Authorize(apiKey.Key, apiKey.ClientID, apiKey.AccountID)
+
+		log.Infof("Client session[%s] authorized with key: %s", clientSenssion.GetID(), apiKey.Key)
+	}
+
 	var (
 		msg     []byte
 		req     models.Request
 		rspList []models.Response
+
+		opBucket   = newSessionBucket(s.cfg.Load())
+		violations int
 	)
 
 	if headerSub := s.getReqSubscribe(r, clientSenssion); headerSub != nil {
@@ -294,6 +729,8 @@ func (s *server) wsUpgrader(w http.ResponseWriter, r *http.Request) {
 			clientSenssion.Close(0, "Server exit.")
 			return
 		default:
+			rspList = nil
+
 			if msg, err = clientSenssion.ReadMessage(); err != nil {
 				clientSenssion.Close(-1, err.Error())
 				return
@@ -310,6 +747,23 @@ func (s *server) wsUpgrader(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
+			if limitRsp := s.checkOpRate(opBucket, req, clientSenssion); limitRsp != nil {
+				violations++
+
+				log.Warnf("Client session[%s] operation rate limited[%d]: %s", clientSenssion.GetID(), violations, req.String())
+
+				if limit := s.cfg.Load().RateViolationLimit; limit > 0 && violations >= limit {
+					clientSenssion.WriteJSONMessage(limitRsp, true)
+					clientSenssion.Close(websocket.ClosePolicyViolation, "Rate limit exceeded too many times.")
+
+					return
+				}
+
+				clientSenssion.WriteJSONMessage(limitRsp, false)
+
+				continue
+			}
+
 			switch req.GetOperation() {
 			case "subscribe":
 				log.Infof("Client session[%s] operation subscribe: %s\n", clientSenssion.GetID(), req.String())
@@ -317,7 +771,19 @@ func (s *server) wsUpgrader(w http.ResponseWriter, r *http.Request) {
 				if subRsp := s.handleSubscribe(req, clientSenssion); subRsp != nil {
 					rspList = append(rspList, subRsp...)
 				}
-			case "auth":
+			case "subscribeSQL":
+				log.Infof("Client session[%s] operation subscribeSQL: %s\n", clientSenssion.GetID(), req.String())
+
+				if subRsp := s.handleSubscribeSQL(req, clientSenssion); subRsp != nil {
+					rspList = append(rspList, subRsp...)
+				}
+			case "unsubscribe":
+				log.Infof("Client session[%s] operation unsubscribe: %s\n", clientSenssion.GetID(), req.String())
+
+				if unsubRsp := s.handleUnsubscribe(req, clientSenssion); unsubRsp != nil {
+					rspList = append(rspList, unsubRsp...)
+				}
+			case "auth", "authKeyExpires":
 				log.Infof("Client session[%s] operation auth: %s\n", clientSenssion.GetID(), req.String())
 
 				if authRsp := s.handleAuth(req, clientSenssion); authRsp != nil {
@@ -339,6 +805,24 @@ func (s *server) wsUpgrader(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func writeHTTPError(w http.ResponseWriter, status int, err error, meta map[string]interface{}) {
+	rsp := models.ErrResponse{
+		Error:  err.Error(),
+		Status: status,
+		Meta:   meta,
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(status)
+	w.Write([]byte(rsp.String()))
+}
+
+func (s *server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-type", "text/plain; version=0.0.4")
+
+	metrics.DefaultRegistry.WriteTo(w)
+}
+
 func (s *server) statusHandler(w http.ResponseWriter, r *http.Request) {
 	status := Status{
 		serverStatics: s.statics,
@@ -357,7 +841,7 @@ func NewServer(ctx context.Context, cfg *Config) Server {
 	}
 
 	svr := server{
-		cfg: cfg,
+		cfg: newLiveConfig(cfg),
 		upgrader: &websocket.Upgrader{
 			ReadBufferSize:    4096,
 			WriteBufferSize:   4096,
@@ -376,28 +860,117 @@ func NewServer(ctx context.Context, cfg *Config) Server {
 		ctx:        ctx,
 		statics:    serverStatics{},
 		clients:    make(map[string]Session),
-		dataCaches: make(map[string]utils.Cache),
+		dataCaches: NewCacheRegistry(),
+		engines:    make(map[string]*orderbook.Engine),
+		orders:     newOrderStore(),
+		connQuota:  newConnQuota(),
+		keyLimiter: newKeyLimiter(),
 	}
 
-	td := utils.NewTradeCache(ctx, "XBTUSD")
-	ins := utils.NewInstrumentCache(ctx, "XBTUSD")
-	mbl := utils.NewMBLCache(ctx, "XBTUSD")
+	if cfg.KeyStore != "" {
+		store, err := LoadKeyStore(cfg.KeyStore)
+		if err != nil {
+			log.Panic(err)
+		}
 
-	svr.dataCaches["trade"] = td
-	svr.dataCaches["instrument"] = ins
-	svr.dataCaches["orderBookL2"] = mbl
-	if err := mbl.(*utils.MBLCache).NewDepthChannel(25); err != nil {
-		log.Panic(err)
+		svr.keyStore = store
 	}
-	svr.dataCaches["orderBookL2_25"] = mbl
 
-	// FIXME: mock的临时方案
-	// go mock.Trade(td)
-	go mock.Upstream(map[string]utils.Cache{
-		"orderBookL2": mbl,
-		"trade":       td,
-		"instrument":  ins,
-	})
+	if cfg.MockMode == MockUpstream && cfg.Record != "" {
+		recorder, err := client.NewRecorder(cfg.Record)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		log.Info("Recording upstream frames to: ", recorder.Path())
+
+		svr.recorder = recorder
+	}
+
+	var dataCtx context.Context
+	dataCtx, svr.dataCancel = context.WithCancel(ctx)
+	dataCtx = utils.WithMetricLabels(dataCtx, svr.metricLabels(nil))
+
+	replayCaches := make(map[string]map[string]utils.Cache)
+
+	for _, symbol := range cfg.Symbols {
+		td := utils.NewTradeCache(dataCtx, symbol)
+		ins := utils.NewInstrumentCache(dataCtx, symbol)
+		mbl := utils.NewMBLCache(dataCtx, symbol)
+
+		svr.dataCaches.Register("trade", symbol, td)
+		svr.dataCaches.Register("instrument", symbol, ins)
+		mbl.(*utils.MBLCache).SetConflateInterval(cfg.GetConflateInterval())
+		svr.dataCaches.Register("orderBookL2", symbol, mbl)
+		if err := mbl.(*utils.MBLCache).NewDepthChannel(25); err != nil {
+			log.Panic(err)
+		}
+		svr.dataCaches.Register("orderBookL2_25", symbol, mbl)
+		if err := mbl.(*utils.MBLCache).NewSnapshotChannel(10); err != nil {
+			log.Panic(err)
+		}
+		svr.dataCaches.Register("orderBook10", symbol, mbl)
+
+		quote := utils.NewQuoteCache(dataCtx, symbol)
+		mbl.(*utils.MBLCache).SetQuoteCache(quote)
+		svr.dataCaches.Register("quote", symbol, quote)
+
+		for _, binSize := range utils.BinSizes {
+			bin := utils.NewQuoteBinCache(dataCtx, symbol, binSize)
+			quote.(*utils.QuoteCache).AddBinCache(bin)
+			svr.dataCaches.Register("quoteBin"+binSize, symbol, bin)
+		}
+
+		for _, binSize := range utils.BinSizes {
+			bin := utils.NewTradeBinCache(dataCtx, symbol, binSize)
+			td.(*utils.TradeCache).AddBinCache(bin)
+			svr.dataCaches.Register("tradeBin"+binSize, symbol, bin)
+		}
+
+		switch cfg.MockMode {
+		case MockUpstream:
+			if !cfg.Upstream.HasSymbol(symbol) {
+				log.Warnf("No upstream for %s, public flow will be empty.", symbol)
+				break
+			}
+
+			go mock.Upstream(dataCtx, cfg.Upstream, symbol, map[string]utils.Cache{
+				"orderBookL2": mbl,
+				"trade":       td,
+				"instrument":  ins,
+			}, svr.recorder)
+		case MockTrade:
+			go mock.Trade(dataCtx, symbol, td)
+		case MockMatch:
+			svr.engines[symbol] = orderbook.NewEngine(symbol, cfg.Generator.TickSize, mbl, td)
+			svr.engines[symbol].SetFillHandler(svr.handleFills)
+		case MockGenerate:
+			engine := orderbook.NewEngine(symbol, cfg.Generator.TickSize, mbl, td)
+			engine.SetFillHandler(svr.handleFills)
+			generator := mock.NewGenerator(cfg.Generator, engine, ins)
+
+			svr.engines[symbol] = engine
+			svr.generators = append(svr.generators, generator)
+
+			go generator.Run(dataCtx)
+		case MockReplay:
+			replayCaches[symbol] = map[string]utils.Cache{
+				"orderBookL2": mbl,
+				"trade":       td,
+				"instrument":  ins,
+			}
+		}
+	}
+
+	if cfg.MockMode == MockReplay {
+		svr.replay = mock.NewReplay(cfg.Replay, replayCaches)
+
+		go func() {
+			if err := svr.replay.Run(dataCtx); err != nil && err != context.Canceled {
+				log.Error("Replay failed: ", err)
+			}
+		}()
+	}
 
 	return &svr
 }
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/orderbook"
+	"github.com/frozenpine/wstester/utils"
+	"github.com/gorilla/websocket"
+)
+
+func newTestServer(ctx context.Context, t *testing.T) (*server, *httptest.Server) {
+	svr := server{
+		cfg:        newLiveConfig(NewConfig()),
+		ctx:        ctx,
+		upgrader:   &websocket.Upgrader{},
+		clients:    make(map[string]Session),
+		dataCaches: NewCacheRegistry(),
+		engines:    make(map[string]*orderbook.Engine),
+		orders:     newOrderStore(),
+		connQuota:  newConnQuota(),
+		keyLimiter: newKeyLimiter(),
+	}
+
+	svr.dataCaches.Register("trade", "XBTUSD", utils.NewTradeCache(
+		utils.WithMetricLabels(ctx, svr.metricLabels(nil)), "XBTUSD"))
+
+	httpSvr := httptest.NewServer(http.HandlerFunc(svr.wsUpgrader))
+
+	return &svr, httpSvr
+}
+
+func dialTestServer(t *testing.T, httpSvr *httptest.Server) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(
+		strings.Replace(httpSvr.URL, "http", "ws", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// welcome message
+	readTestMessage(t, conn, models.InfoPattern.String())
+
+	return conn
+}
+
+func readTestMessage(t *testing.T, conn *websocket.Conn, expect string) string {
+	conn.SetReadDeadline(time.Now().Add(time.Second * 3))
+
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(msg), expect) {
+		t.Fatalf("expect message contains %s, got: %s", expect, string(msg))
+	}
+
+	return string(msg)
+}
+
+func newTestTrade(price float64) *models.TradeResponse {
+	ts := ngerest.NGETime(time.Now())
+
+	td := models.TradeResponse{}
+	td.Table = "trade"
+	td.Action = models.InsertAction
+	td.Data = []*ngerest.Trade{
+		{Symbol: "XBTUSD", Side: "Buy", Price: price, Size: 1, Timestamp: &ts},
+	}
+
+	return &td
+}
+
+func newTestMBL(action string, levels ...*ngerest.OrderBookL2) *models.MBLResponse {
+	rsp := models.MBLResponse{}
+	rsp.Table = "orderBookL2"
+	rsp.Action = action
+	rsp.Data = levels
+
+	return &rsp
+}
+
+func TestSubscribeAndUnsubscribe(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	conn := dialTestServer(t, httpSvr)
+	defer conn.Close()
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribe", Args: []string{"trade:XBTUSD"}})
+	readTestMessage(t, conn, `"subscribe":"trade:XBTUSD"`)
+	readTestMessage(t, conn, `"action":"partial"`)
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribe", Args: []string{"trade:XBTUSD"}})
+	readTestMessage(t, conn, `"status":400`)
+
+	cache := svr.dataCaches.GetCache("trade", "XBTUSD")
+	cache.Append(utils.NewCacheInput(newTestTrade(9000)))
+	readTestMessage(t, conn, `"price":9000`)
+
+	conn.WriteJSON(models.OperationRequest{Operation: "unsubscribe", Args: []string{"trade:XBTUSD"}})
+	readTestMessage(t, conn, `"success":true,"unsubscribe":"trade:XBTUSD"`)
+
+	conn.WriteJSON(models.OperationRequest{Operation: "unsubscribe", Args: []string{"trade:XBTUSD"}})
+	readTestMessage(t, conn, `"success":false,"unsubscribe":"trade:XBTUSD"`)
+
+	cache.Append(utils.NewCacheInput(newTestTrade(9001)))
+
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond * 500))
+	if _, msg, err := conn.ReadMessage(); err == nil {
+		t.Fatal("unexpected message after unsubscribe:", string(msg))
+	}
+}
+
+func TestUnsubscribeBufferedData(t *testing.T) {
+	sub := subscription{}
+
+	if !sub.forward(func() {}) {
+		t.Fatal("data not forwarded")
+	}
+
+	sub.stop()
+
+	if sub.forward(func() { t.Fatal("data forwarded after stopped") }) {
+		t.Fatal("stopped subscription forwarded data")
+	}
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	conn := dialTestServer(t, httpSvr)
+	defer conn.Close()
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribe", Args: []string{"trade:XBTUSD"}})
+	readTestMessage(t, conn, `"subscribe":"trade:XBTUSD"`)
+	readTestMessage(t, conn, `"action":"partial"`)
+
+	cache := svr.dataCaches.GetCache("trade", "XBTUSD")
+	for i := 0; i < 500; i++ {
+		cache.Append(utils.NewCacheInput(newTestTrade(9000 + float64(i))))
+	}
+
+	conn.WriteJSON(models.OperationRequest{Operation: "unsubscribe", Args: []string{"trade:XBTUSD"}})
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(time.Second * 3))
+
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Contains(string(msg), `"unsubscribe":"trade:XBTUSD"`) {
+			break
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond * 500))
+	if _, msg, err := conn.ReadMessage(); err == nil {
+		t.Fatal("buffered data sent after unsubscribe acknowledged:", string(msg))
+	}
+}
+
+func TestSubscribeQuote(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	mbl := utils.NewMBLCache(ctx, "XBTUSD")
+	quote := utils.NewQuoteCache(ctx, "XBTUSD")
+	mbl.(*utils.MBLCache).SetQuoteCache(quote)
+	svr.dataCaches.Register("quote", "XBTUSD", quote)
+
+	partial := models.NewMBLPartial()
+	partial.Data = []*ngerest.OrderBookL2{
+		{Symbol: "XBTUSD", ID: 1, Side: "Sell", Size: 10, Price: 9000.5},
+		{Symbol: "XBTUSD", ID: 2, Side: "Buy", Size: 10, Price: 9000},
+	}
+	mbl.Append(utils.NewCacheInput(partial))
+	mbl.TakeSnapshot(0, nil, "")
+
+	conn := dialTestServer(t, httpSvr)
+	defer conn.Close()
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribe", Args: []string{"quote:XBTUSD"}})
+	readTestMessage(t, conn, `"subscribe":"quote:XBTUSD"`)
+	readTestMessage(t, conn, `"table":"quote","action":"partial"`)
+
+	insert := models.MBLResponse{}
+	insert.Table = "orderBookL2"
+	insert.Action = models.InsertAction
+	insert.Data = []*ngerest.OrderBookL2{{Symbol: "XBTUSD", ID: 3, Side: "Buy", Size: 3, Price: 9000.1}}
+	mbl.Append(utils.NewCacheInput(&insert))
+
+	msg := readTestMessage(t, conn, `"table":"quote","action":"insert"`)
+	if !strings.Contains(msg, `"bidSize":3,"bidPrice":9000.1`) {
+		t.Fatal("quote miss-match:", msg)
+	}
+}
+
+func TestSubscribeOrderBook10(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	mbl := utils.NewMBLCache(ctx, "XBTUSD")
+	if err := mbl.(*utils.MBLCache).NewSnapshotChannel(10); err != nil {
+		t.Fatal(err)
+	}
+	svr.dataCaches.Register("orderBook10", "XBTUSD", mbl)
+
+	partial := models.NewMBLPartial()
+	for idx := 0; idx < 11; idx++ {
+		partial.Data = append(partial.Data, &ngerest.OrderBookL2{
+			Symbol: "XBTUSD", ID: idx + 1, Side: "Buy", Size: 10, Price: 9000 - float64(idx)})
+	}
+	partial.Data = append(partial.Data, &ngerest.OrderBookL2{
+		Symbol: "XBTUSD", ID: 100, Side: "Sell", Size: 5, Price: 9000.5})
+	mbl.Append(utils.NewCacheInput(partial))
+	mbl.TakeSnapshot(0, nil, "")
+
+	conn := dialTestServer(t, httpSvr)
+	defer conn.Close()
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribe", Args: []string{"orderBook10:XBTUSD"}})
+	readTestMessage(t, conn, `"subscribe":"orderBook10:XBTUSD"`)
+
+	msg := readTestMessage(t, conn, `"table":"orderBook10","action":"partial"`)
+	if !strings.Contains(msg, `"bids":[[9000,10],[8999,10]`) || strings.Contains(msg, `[8990,10]`) ||
+		!strings.Contains(msg, `"asks":[[9000.5,5]]`) {
+		t.Fatal("orderBook10 partial miss-match:", msg)
+	}
+
+	update := models.MBLResponse{}
+	update.Table = "orderBookL2"
+	update.Action = models.UpdateAction
+
+	// level out of top 10 changed, no snapshot published
+	update.Data = []*ngerest.OrderBookL2{{Symbol: "XBTUSD", ID: 11, Side: "Buy", Size: 1, Price: 8990}}
+	mbl.Append(utils.NewCacheInput(&update))
+
+	top := update
+	top.Data = []*ngerest.OrderBookL2{{Symbol: "XBTUSD", ID: 100, Side: "Sell", Size: 3, Price: 9000.5}}
+	mbl.Append(utils.NewCacheInput(&top))
+
+	msg = readTestMessage(t, conn, `"table":"orderBook10","action":"update"`)
+	if !strings.Contains(msg, `"asks":[[9000.5,3]]`) {
+		t.Fatal("orderBook10 update miss-match:", msg)
+	}
+}
@@ -3,15 +3,20 @@ package server
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/frozenpine/ngerest"
 	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils"
 	"github.com/frozenpine/wstester/utils/log"
+	"github.com/frozenpine/wstester/utils/metrics"
 	"github.com/gorilla/websocket"
 	uuid "github.com/satori/go.uuid"
 )
@@ -19,6 +24,13 @@ import (
 var (
 	pingPattern = []byte(`ping`)
 	pongPattern = []byte(`pong`)
+
+	closeTimeout = time.Second
+
+	heartbeatFailures = metrics.DefaultRegistry.Counter(
+		"wstester_heartbeat_failures_total", "Sessions closed for heartbeat timeout or miss-match.", nil)
+	slowConsumerDisconnects = metrics.DefaultRegistry.Counter(
+		"wstester_slow_consumer_disconnects_total", "Sessions closed for send queue full.", nil)
 )
 
 // Session interface interactive with client session
@@ -27,16 +39,22 @@ type Session interface {
 	IsClosed() bool
 	// GetAddr get client side addr.
 	GetAddr() net.Addr
-	// Welcome send welcome message to client
-	Welcome() error
+	// Welcome send welcome message to client with connection limit info
+	Welcome(limit map[string]interface{}) error
 	// GetID to get session's unique id
 	GetID() string
 	// Close to close current session
 	Close(code int, msg string) error
-	// Authorize to authorize current session as logged in
-	Authorize(key, secret string)
+	// Authorize to authorize current session as logged in with api key owner's identity
+	Authorize(key, clientID, accountID string)
 	// IsAuthorized to specify wether current session is authrozied
 	IsAuthorized() bool
+	// GetClientID get authorized client id
+	GetClientID() string
+	// GetAccountID get authorized account id
+	GetAccountID() string
+	// GetAPIKey get api key used in authorization
+	GetAPIKey() string
 
 	// ReadMessage receive message from client session
 	ReadMessage() ([]byte, error)
@@ -44,49 +62,128 @@ type Session interface {
 	WriteTextMessage(msg string, isSync bool) error
 	// WriteJSONMessage send json object to client
 	WriteJSONMessage(obj interface{}, isSync bool) error
+	// WriteTopicMessage send subscribed topic's data to client without blocking,
+	// data is dropped, conflated or session is closed by topic's policy when send queue full.
+	WriteTopicMessage(topic string, rsp models.TableResponse) error
 
-	// SetCleanup set clean up function, this func will be called when session close.
+	// SetCleanup add clean up function, all added funcs will be called when session close.
 	SetCleanup(func())
+
+	// Subscribe add subscribed topic in current session with topic's clean up function,
+	// topic's clean up funcs will be called on unsubscribe or session close.
+	Subscribe(topic string, cleanup func())
+	// UnSubscribe remove subscribed topic and call topic's clean up funcs.
+	UnSubscribe(topic string) error
+	// IsSubscribed to specify wether topic is subscribed in current session.
+	IsSubscribed(topic string) bool
+	// GetSubscribed get subscribed topics in current session.
+	GetSubscribed() []string
+	// WatchQueue add queue depth func for subscribed topic, removed on unsubscribe or close.
+	WatchQueue(topic string, depth func() int)
+	// QueueDepth get count of messages waiting for sending in current session.
+	QueueDepth() int
+
+	// GetStatus get session status
+	GetStatus() *SessionStatus
+
+	// ResetHeartbeat restart heartbeat timer with current heartbeat config.
+	ResetHeartbeat()
+
+	// SetChaos set fault injection config for current session, nil to disable fault injection.
+	SetChaos(cfg *ChaosConfig) error
+	// GetChaos get fault injection config of current session, nil if disabled.
+	GetChaos() *ChaosConfig
+}
+
+// SessionStatus status of client session
+type SessionStatus struct {
+	ID          string    `json:"id"`
+	Addr        string    `json:"addr"`
+	APIKey      string    `json:"apiKey,omitempty"`
+	ClientID    string    `json:"clientId,omitempty"`
+	AccountID   string    `json:"accountId,omitempty"`
+	Subscribed  []string  `json:"subscribed"`
+	ConnectTime time.Time `json:"connectTime"`
+	BytesSent   int64     `json:"bytesSent"`
+	QueueDepth  int       `json:"queueDepth"`
+	// Dropped data messages dropped for send queue full
+	Dropped int64 `json:"dropped"`
+	// Conflated data messages replaced by newer one for send queue full
+	Conflated int64 `json:"conflated"`
+	// Chaos fault injection config, omitted if disabled
+	Chaos *ChaosConfig `json:"chaos,omitempty"`
 }
 
 type message struct {
 	json    interface{}
 	txt     string
 	errChan chan error
+	// topic of data message, empty for messages never dropped
+	topic string
 }
 
-type clientSession struct {
-	cfg       *Config
-	sessionID uuid.UUID
+// sessionIdentity authorized identity of session, replaced as a whole on re-authorization
+type sessionIdentity struct {
+	apiKey    string
 	clientID  string
 	accountID string
+}
+
+type clientSession struct {
+	cfg       *liveConfig
+	sessionID uuid.UUID
+	// identity sessionIdentity read by notify & order handlers out of read loop
+	identity atomic.Value
 
 	conn      *websocket.Conn
 	req       *http.Request
 	addr      net.Addr
-	sendChan  chan *message
+	sendQueue *sendQueue
 	isClosed  bool
 	closeOnce sync.Once
 	ctx       context.Context
 	cancelFn  context.CancelFunc
 
-	cleanupFn func()
+	cleanupFns  []func()
+	cleanupLock sync.Mutex
 
-	hbChan         chan *models.HeartBeat
-	heartbeatTimer *time.Timer
+	subscribed map[string][]func()
+	queues     map[string][]func() int
+
+	// preparedMessage send shared responses with prepared message
+	preparedMessage bool
+
+	chaos     *chaosInjector
+	chaosLock sync.RWMutex
+
+	connectTime time.Time
+	bytesSent   int64
+	dropped     int64
+	conflated   int64
+	// queueFull 1 if last data message dropped or conflated by slow consumer policy
+	queueFull int32
+
+	hbChan      chan *models.HeartBeat
+	hbResetChan chan struct{}
 }
 
 func (c *clientSession) IsClosed() bool {
+	c.cleanupLock.Lock()
+	defer c.cleanupLock.Unlock()
+
 	return c.isClosed
 }
 
-func (c *clientSession) Welcome() error {
+func (c *clientSession) Welcome(limit map[string]interface{}) error {
+	cfg := c.cfg.Load()
+
 	info := models.InfoResponse{
-		Info:      c.cfg.WelcomMsg,
+		Info:      cfg.WelcomMsg,
 		Version:   version,
 		Timestamp: ngerest.NGETime(time.Now().UTC()),
-		Docs:      c.cfg.DocsURI,
-		FrontID:   c.cfg.FrontID,
+		Docs:      cfg.DocsURI,
+		Limit:     limit,
+		FrontID:   cfg.FrontID,
 		SessionID: c.GetID(),
 	}
 
@@ -107,32 +204,204 @@ func (c *clientSession) Close(code int, reason string) error {
 	}
 
 	c.closeOnce.Do(func() {
+		c.cleanupLock.Lock()
 		c.isClosed = true
+		cleanupFns := c.cleanupFns
+		c.cleanupFns = nil
+		for _, topicFns := range c.subscribed {
+			cleanupFns = append(cleanupFns, topicFns...)
+		}
+		c.subscribed = nil
+		c.queues = nil
+		c.cleanupLock.Unlock()
+
 		c.cancelFn()
 
-		if c.cleanupFn != nil {
-			c.cleanupFn()
+		for _, fn := range cleanupFns {
+			fn()
+		}
+
+		if code >= websocket.CloseNormalClosure {
+			c.conn.WriteControl(
+				websocket.CloseMessage, websocket.FormatCloseMessage(code, reason),
+				time.Now().Add(closeTimeout))
 		}
 
 		c.conn.Close()
-	})
 
-	log.Infof("Client session[%s] closed with code[%d]: %s", c.GetID(), code, reason)
+		log.Infof("Client session[%s] closed with code[%d]: %s", c.GetID(), code, reason)
+	})
 
 	return nil
 }
 
-func (c *clientSession) Authorize(clientID, accountID string) {
-	c.accountID = accountID
-	c.clientID = clientID
+func (c *clientSession) getIdentity() sessionIdentity {
+	identity, _ := c.identity.Load().(sessionIdentity)
+
+	return identity
+}
+
+func (c *clientSession) Authorize(key, clientID, accountID string) {
+	c.identity.Store(sessionIdentity{apiKey: key, clientID: clientID, accountID: accountID})
 }
 
 func (c *clientSession) IsAuthorized() bool {
-	return c.clientID != "" && c.accountID != ""
+	identity := c.getIdentity()
+
+	return identity.clientID != "" && identity.accountID != ""
+}
+
+func (c *clientSession) GetClientID() string {
+	return c.getIdentity().clientID
+}
+
+func (c *clientSession) GetAccountID() string {
+	return c.getIdentity().accountID
+}
+
+func (c *clientSession) GetAPIKey() string {
+	return c.getIdentity().apiKey
 }
 
 func (c *clientSession) SetCleanup(fn func()) {
-	c.cleanupFn = fn
+	if fn == nil {
+		return
+	}
+
+	c.cleanupLock.Lock()
+
+	if c.isClosed {
+		c.cleanupLock.Unlock()
+
+		// session already closed, clean up immediately
+		fn()
+
+		return
+	}
+
+	c.cleanupFns = append(c.cleanupFns, fn)
+	c.cleanupLock.Unlock()
+}
+
+func (c *clientSession) Subscribe(topic string, cleanup func()) {
+	c.cleanupLock.Lock()
+
+	if c.isClosed {
+		c.cleanupLock.Unlock()
+
+		if cleanup != nil {
+			cleanup()
+		}
+
+		return
+	}
+
+	fnList := c.subscribed[topic]
+	if cleanup != nil {
+		fnList = append(fnList, cleanup)
+	}
+	c.subscribed[topic] = fnList
+
+	c.cleanupLock.Unlock()
+}
+
+func (c *clientSession) UnSubscribe(topic string) error {
+	c.cleanupLock.Lock()
+
+	fnList, exist := c.subscribed[topic]
+	delete(c.subscribed, topic)
+	delete(c.queues, topic)
+
+	c.cleanupLock.Unlock()
+
+	if !exist {
+		return fmt.Errorf("topic[%s] is not subscribed", topic)
+	}
+
+	for _, fn := range fnList {
+		fn()
+	}
+
+	return nil
+}
+
+func (c *clientSession) IsSubscribed(topic string) bool {
+	c.cleanupLock.Lock()
+	defer c.cleanupLock.Unlock()
+
+	_, exist := c.subscribed[topic]
+
+	return exist
+}
+
+func (c *clientSession) GetSubscribed() []string {
+	c.cleanupLock.Lock()
+	defer c.cleanupLock.Unlock()
+
+	var topics []string
+
+	for topic := range c.subscribed {
+		topics = append(topics, topic)
+	}
+
+	sort.Strings(topics)
+
+	return topics
+}
+
+func (c *clientSession) ResetHeartbeat() {
+	select {
+	case c.hbResetChan <- struct{}{}:
+	default:
+		// reset already pending
+	}
+}
+
+func (c *clientSession) WatchQueue(topic string, depth func() int) {
+	c.cleanupLock.Lock()
+	defer c.cleanupLock.Unlock()
+
+	if c.isClosed || depth == nil {
+		return
+	}
+
+	c.queues[topic] = append(c.queues[topic], depth)
+}
+
+func (c *clientSession) QueueDepth() int {
+	c.cleanupLock.Lock()
+	defer c.cleanupLock.Unlock()
+
+	depth := c.sendQueue.Len()
+
+	for _, fnList := range c.queues {
+		for _, fn := range fnList {
+			depth += fn()
+		}
+	}
+
+	return depth
+}
+
+func (c *clientSession) GetStatus() *SessionStatus {
+	identity := c.getIdentity()
+
+	status := SessionStatus{
+		ID:          c.GetID(),
+		Addr:        c.GetAddr().String(),
+		APIKey:      identity.apiKey,
+		ClientID:    identity.clientID,
+		AccountID:   identity.accountID,
+		Subscribed:  c.GetSubscribed(),
+		ConnectTime: c.connectTime,
+		BytesSent:   atomic.LoadInt64(&c.bytesSent),
+		QueueDepth:  c.QueueDepth(),
+		Dropped:     atomic.LoadInt64(&c.dropped),
+		Conflated:   atomic.LoadInt64(&c.conflated),
+		Chaos:       c.GetChaos(),
+	}
+
+	return &status
 }
 
 func (c *clientSession) heartbeatLoop() {
@@ -141,30 +410,39 @@ func (c *clientSession) heartbeatLoop() {
 		err       error
 	)
 
-	if c.cfg.ReversHeartbeat {
+	// reverse heartbeat can not be changed by reload
+	reverse := c.cfg.Load().ReversHeartbeat
+
+	if reverse {
 		go func() {
-			ticker := time.NewTicker(time.Second * time.Duration(c.cfg.HeartbeatInterval))
+			ticker := time.NewTicker(c.cfg.Load().GetHeartbeatInterval())
 
 			for {
 				select {
 				case <-c.ctx.Done():
 					ticker.Stop()
 					return
+				case <-c.hbResetChan:
+					ticker.Stop()
+					ticker = time.NewTicker(c.cfg.Load().GetHeartbeatInterval())
 				case <-ticker.C:
 					c.hbChan <- models.NewPing()
 				}
 			}
 		}()
 	} else {
-		c.heartbeatTimer = time.NewTimer(time.Second * time.Duration(c.cfg.HeartbeatInterval*c.cfg.HeartbeatFailCount))
+		heartbeatTimer := time.NewTimer(c.cfg.Load().GetHeartbeatTimeout())
 
 		go func() {
 			for {
 				select {
 				case <-c.ctx.Done():
-					c.heartbeatTimer.Stop()
+					heartbeatTimer.Stop()
 					return
-				case <-c.heartbeatTimer.C:
+				case <-c.hbResetChan:
+					heartbeatTimer.Reset(c.cfg.Load().GetHeartbeatTimeout())
+				case <-heartbeatTimer.C:
+					heartbeatFailures.Inc()
 					c.Close(-1, "Receive data timeout.")
 				}
 			}
@@ -174,7 +452,11 @@ func (c *clientSession) heartbeatLoop() {
 	for hb := range c.hbChan {
 		switch hb.Type() {
 		case "Ping":
-			if c.cfg.ReversHeartbeat {
+			if chaos := c.getChaos(); chaos != nil && chaos.stopHeartbeat() {
+				continue
+			}
+
+			if reverse {
 				if err = c.WriteTextMessage("ping", true); err != nil {
 					c.Close(-1, fmt.Sprintf("Send heatbeat to client session[%s] failed.", c.GetID()))
 					return
@@ -194,7 +476,8 @@ func (c *clientSession) heartbeatLoop() {
 			continue
 		}
 
-		if hbCounter >= c.cfg.HeartbeatFailCount || hbCounter < 0 {
+		if hbCounter >= c.cfg.Load().HeartbeatFailCount || hbCounter < 0 {
+			heartbeatFailures.Inc()
 			c.Close(-1, fmt.Sprint("Heartbeat miss-match:", hbCounter))
 
 			return
@@ -216,8 +499,9 @@ HEARTBEAT:
 			return nil, err
 		}
 
-		if c.heartbeatTimer != nil {
-			c.heartbeatTimer.Reset(time.Second * time.Duration(c.cfg.HeartbeatInterval*c.cfg.HeartbeatFailCount))
+		// data received, reset timeout in heartbeat loop
+		if !c.cfg.Load().ReversHeartbeat {
+			c.ResetHeartbeat()
 		}
 
 		switch {
@@ -233,54 +517,145 @@ HEARTBEAT:
 	return msg, err
 }
 
-func (c *clientSession) WriteTextMessage(txt string, sync bool) (err error) {
+// writeMessage queue message for sending, wait for sent result if msg is sync
+func (c *clientSession) writeMessage(msg *message) error {
+	if c.ctx.Err() != nil {
+		return ErrSessionClosed
+	}
+
+	c.sendQueue.push(msg, "")
+
+	if msg.errChan == nil {
+		return nil
+	}
+
+	select {
+	case err := <-msg.errChan:
+		return err
+	case <-c.ctx.Done():
+		return ErrSessionClosed
+	}
+}
+
+func (c *clientSession) WriteTextMessage(txt string, sync bool) error {
 	msg := message{txt: txt}
 
 	if sync {
 		msg.errChan = make(chan error, 1)
-
-		defer func() {
-			err = <-msg.errChan
-		}()
 	}
 
-	c.sendChan <- &msg
-
-	return
+	return c.writeMessage(&msg)
 }
 
-func (c *clientSession) WriteJSONMessage(obj interface{}, sync bool) (err error) {
+func (c *clientSession) WriteJSONMessage(obj interface{}, sync bool) error {
 	msg := message{json: obj}
 
 	if sync {
 		msg.errChan = make(chan error, 1)
+	}
 
-		defer func() {
-			err = <-msg.errChan
-		}()
+	return c.writeMessage(&msg)
+}
+
+func (c *clientSession) WriteTopicMessage(topic string, rsp models.TableResponse) error {
+	if rsp.GetAction() == models.PartialAction {
+		// partial is base of following data, never dropped
+		return c.WriteJSONMessage(rsp, false)
+	}
+
+	if c.ctx.Err() != nil {
+		return ErrSessionClosed
+	}
+
+	policy := c.cfg.Load().SendQueue.GetPolicy(topic)
+
+	dropped, conflated, err := c.sendQueue.push(&message{json: rsp, topic: topic}, policy)
+	if err != nil {
+		slowConsumerDisconnects.Inc()
+		log.Warnf("Client session[%s] send queue full on topic %s.", c.GetID(), topic)
+		c.Close(websocket.ClosePolicyViolation, err.Error())
+
+		return err
+	}
+
+	totalDropped := atomic.AddInt64(&c.dropped, int64(dropped))
+	totalConflated := atomic.AddInt64(&c.conflated, int64(conflated))
+
+	// log only on queue state transition, not for each message dropped or conflated
+	if dropped+conflated > 0 {
+		if atomic.CompareAndSwapInt32(&c.queueFull, 0, 1) {
+			log.Warnf("Client session[%s] send queue full on topic %s, policy[%s] applied, dropped: %d, conflated: %d.",
+				c.GetID(), topic, policy, totalDropped, totalConflated)
+		}
+	} else if atomic.CompareAndSwapInt32(&c.queueFull, 1, 0) {
+		log.Infof("Client session[%s] send queue recovered, dropped: %d, conflated: %d.",
+			c.GetID(), totalDropped, totalConflated)
+	}
+
+	return nil
+}
+
+// writeData write message to conn, shared response is encoded once for all sessions
+// and its prepared message is used if enabled, return data length sent.
+func (c *clientSession) writeData(msg *message) (int, error) {
+	if shared, ok := msg.json.(*utils.SharedResponse); ok {
+		data, err := shared.Bytes()
+		if err != nil {
+			return 0, err
+		}
+
+		if !c.preparedMessage {
+			return len(data), c.conn.WriteMessage(websocket.TextMessage, data)
+		}
+
+		prepared, err := shared.PreparedMessage()
+		if err != nil {
+			return 0, err
+		}
+
+		return len(data), c.conn.WritePreparedMessage(prepared)
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+
+	if msg.json != nil {
+		if data, err = json.Marshal(msg.json); err != nil {
+			return 0, err
+		}
+	} else {
+		data = []byte(msg.txt)
 	}
 
-	c.sendChan <- &msg
+	if len(data) == 0 {
+		return 0, nil
+	}
 
-	return
+	return len(data), c.conn.WriteMessage(websocket.TextMessage, data)
 }
 
 func (c *clientSession) sendMessageLoop() {
 	var err error
 
 	for {
-		select {
-		case <-c.ctx.Done():
+		msg := c.sendQueue.pop(c.ctx)
+		if msg == nil {
 			return
-		case msg := <-c.sendChan:
-			if msg == nil {
-				return
-			}
+		}
+
+		msgs := []*message{msg}
+
+		if chaos := c.getChaos(); chaos != nil {
+			msgs = chaos.inject(msg, c)
+		}
+
+		for _, msg := range msgs {
+			var sent int
 
-			if msg.json != nil {
-				err = c.conn.WriteJSON(msg.json)
-			} else if msg.txt != "" {
-				err = c.conn.WriteMessage(websocket.TextMessage, []byte(msg.txt))
+			if sent, err = c.writeData(msg); err == nil {
+				atomic.AddInt64(&c.bytesSent, int64(sent))
 			}
 
 			if msg.errChan != nil {
@@ -289,14 +664,45 @@ func (c *clientSession) sendMessageLoop() {
 
 			if err != nil {
 				c.Close(-1, err.Error())
+				break
 			}
 		}
 	}
 }
 
+func (c *clientSession) getChaos() *chaosInjector {
+	c.chaosLock.RLock()
+	defer c.chaosLock.RUnlock()
+
+	return c.chaos
+}
+
+func (c *clientSession) SetChaos(cfg *ChaosConfig) error {
+	if cfg != nil {
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+	}
+
+	c.chaosLock.Lock()
+	c.chaos = newChaosInjector(cfg)
+	c.chaosLock.Unlock()
+
+	return nil
+}
+
+func (c *clientSession) GetChaos() *ChaosConfig {
+	if chaos := c.getChaos(); chaos != nil {
+		return chaos.cfg
+	}
+
+	return nil
+}
+
 // NewSession create client session from webosocket conn
 func NewSession(ctx context.Context, conn *websocket.Conn, req *http.Request) Session {
-	cfg := ctx.Value(SvrConfigKey).(*Config)
+	live := ctx.Value(SvrConfigKey).(*liveConfig)
+	cfg := live.Load()
 
 	var ip string
 	if xForwared, exist := req.Header["x-forwared-for"]; exist {
@@ -311,13 +717,22 @@ func NewSession(ctx context.Context, conn *websocket.Conn, req *http.Request) Se
 	}
 
 	session := clientSession{
-		cfg:       cfg,
-		req:       req,
-		conn:      conn,
-		addr:      conn.RemoteAddr(),
-		sessionID: sessionID,
-		hbChan:    make(chan *models.HeartBeat),
-		sendChan:  make(chan *message),
+		cfg:         live,
+		req:         req,
+		conn:        conn,
+		addr:        conn.RemoteAddr(),
+		sessionID:   sessionID,
+		hbChan:      make(chan *models.HeartBeat),
+		hbResetChan: make(chan struct{}, 1),
+		sendQueue:   newSendQueue(cfg.SendQueue.Size),
+
+		preparedMessage: cfg.PreparedMessage,
+		chaos:           newChaosInjector(cfg.Chaos),
+
+		subscribed: make(map[string][]func()),
+		queues:     make(map[string][]func() int),
+
+		connectTime: time.Now().UTC(),
 	}
 
 	session.ctx, session.cancelFn = context.WithCancel(ctx)
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils"
+	"github.com/gorilla/websocket"
+)
+
+// discardConn net conn discarding all written data
+type discardConn struct {
+	net.Conn
+}
+
+func (c *discardConn) Read(b []byte) (int, error)         { select {} }
+func (c *discardConn) Write(b []byte) (int, error)        { return len(b), nil }
+func (c *discardConn) Close() error                       { return nil }
+func (c *discardConn) LocalAddr() net.Addr                { return &net.TCPAddr{} }
+func (c *discardConn) RemoteAddr() net.Addr               { return &net.TCPAddr{} }
+func (c *discardConn) SetDeadline(t time.Time) error      { return nil }
+func (c *discardConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *discardConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// discardWriter http response writer hijacked with discard conn
+type discardWriter struct {
+	httptest.ResponseRecorder
+}
+
+func (w *discardWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn := &discardConn{}
+
+	return conn, bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)), nil
+}
+
+// newDiscardConn upgrade a websocket conn discarding all written data
+func newDiscardConn(tb testing.TB, compress bool) *websocket.Conn {
+	req := httptest.NewRequest(http.MethodGet, "/realtime", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-Websocket-Version", "13")
+	req.Header.Set("Sec-Websocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if compress {
+		req.Header.Set("Sec-Websocket-Extensions", "permessage-deflate")
+	}
+
+	upgrader := websocket.Upgrader{EnableCompression: true}
+
+	conn, err := upgrader.Upgrade(&discardWriter{}, req, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return conn
+}
+
+func newTestLevels(count int) []*ngerest.OrderBookL2 {
+	var levels []*ngerest.OrderBookL2
+
+	for idx := 0; idx < count; idx++ {
+		levels = append(levels, &ngerest.OrderBookL2{
+			ID:     idx,
+			Symbol: "XBTUSD",
+			Side:   "Sell",
+			Size:   float32(idx + 100),
+			Price:  10000 + float64(idx)*0.5,
+		})
+	}
+
+	return levels
+}
+
+func TestWriteSharedData(t *testing.T) {
+	for _, prepared := range []bool{false, true} {
+		session, clientConn := newTestSession(t, NewConfig())
+		session.preparedMessage = prepared
+		go session.sendMessageLoop()
+
+		rsp := newTestMBL(models.UpdateAction, newTestLevels(5)...)
+		shared := utils.NewSharedResponse(rsp)
+
+		for idx := 0; idx < 2; idx++ {
+			if err := session.WriteTopicMessage("orderBookL2", shared); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		expected, _ := shared.Bytes()
+
+		for idx := 0; idx < 2; idx++ {
+			clientConn.SetReadDeadline(time.Now().Add(time.Second * 3))
+
+			_, data, err := clientConn.ReadMessage()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(data) != string(expected) {
+				t.Fatalf("prepared[%t] data mismatch: %s", prepared, string(data))
+			}
+		}
+
+		if status := session.GetStatus(); status.BytesSent != int64(len(expected)*2) {
+			t.Fatalf("bytes sent mismatch: %d", status.BytesSent)
+		}
+	}
+}
+
+// benchmarkBroadcast write one table response to all sessions for each op,
+// sessions are written sequentially so ns/op is the cpu cost of one broadcast.
+func benchmarkBroadcast(b *testing.B, sessions int, compress bool, mode string) {
+	var sessionList []*clientSession
+
+	for idx := 0; idx < sessions; idx++ {
+		sessionList = append(sessionList, &clientSession{
+			conn:            newDiscardConn(b, compress),
+			preparedMessage: mode == "Prepared",
+		})
+	}
+
+	rsp := newTestMBL(models.UpdateAction, newTestLevels(25)...)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	start := time.Now()
+
+	for idx := 0; idx < b.N; idx++ {
+		var data interface{} = rsp
+
+		if mode != "PerSession" {
+			data = utils.NewSharedResponse(rsp)
+		}
+
+		for _, session := range sessionList {
+			if _, err := session.writeData(&message{json: data, topic: "orderBookL2"}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.ReportMetric(float64(sessions*b.N)/time.Since(start).Seconds(), "msgs/s")
+}
+
+func BenchmarkBroadcast(b *testing.B) {
+	for _, sessions := range []int{1000, 5000} {
+		for _, compress := range []bool{false, true} {
+			for _, mode := range []string{"PerSession", "Shared", "Prepared"} {
+				name := fmt.Sprintf("Sessions%d/Compress%t/%s", sessions, compress, mode)
+
+				b.Run(name, func(b *testing.B) {
+					benchmarkBroadcast(b, sessions, compress, mode)
+				})
+			}
+		}
+	}
+}
+
+func TestSessionIdentity(t *testing.T) {
+	session, _ := newTestSession(t, NewConfig())
+
+	if session.IsAuthorized() || session.GetAPIKey() != "" {
+		t.Fatal("session authorized before authorize")
+	}
+
+	done := make(chan struct{})
+
+	// re-authorization in read loop while identity read by notify & order handlers
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 1000; i++ {
+			session.Authorize(fmt.Sprintf("key%d", i), fmt.Sprint(i), fmt.Sprint(i))
+		}
+	}()
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+
+		if status := session.GetStatus(); status.ClientID != status.AccountID ||
+			(status.APIKey != "" && status.APIKey != "key"+status.ClientID) {
+			t.Fatalf("session identity miss-match: %+v", status)
+		}
+
+		session.IsClosed()
+	}
+
+	if session.GetAPIKey() != "key999" || !session.IsAuthorized() {
+		t.Fatal("session identity not updated")
+	}
+}
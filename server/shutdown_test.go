@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func getFreePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func dialRunningServer(t *testing.T, cfg *Config) *websocket.Conn {
+	url := fmt.Sprintf("ws://127.0.0.1:%d%s", cfg.Port, cfg.BaseURI)
+
+	var (
+		conn *websocket.Conn
+		err  error
+	)
+
+	// wait for server listening
+	for i := 0; i < 10; i++ {
+		if conn, _, err = websocket.DefaultDialer.Dial(url, nil); err == nil {
+			return conn
+		}
+
+		time.Sleep(time.Millisecond * 100)
+	}
+
+	t.Fatal(err)
+
+	return nil
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	var (
+		conns   []*websocket.Conn
+		results []chan error
+	)
+
+	// multiple servers in one process
+	for i := 0; i < 2; i++ {
+		cfg := NewConfig()
+		cfg.Listen = net.ParseIP("127.0.0.1")
+		cfg.Port = getFreePort(t)
+		cfg.MockMode = MockNone
+
+		svr := NewServer(ctx, cfg)
+
+		result := make(chan error, 1)
+		go func() {
+			result <- svr.RunForever(ctx)
+		}()
+		results = append(results, result)
+
+		conn := dialRunningServer(t, cfg)
+		defer conn.Close()
+
+		readTestMessage(t, conn, `"info"`)
+
+		conns = append(conns, conn)
+	}
+
+	cancelFn()
+
+	for _, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(time.Second * 3))
+
+		_, _, err := conn.ReadMessage()
+		if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+			t.Fatal("close frame miss-match:", err)
+		}
+	}
+
+	for _, result := range results {
+		select {
+		case err := <-result:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second * 6):
+			t.Fatal("server shutdown timeout")
+		}
+	}
+}
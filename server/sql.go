@@ -0,0 +1,276 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils"
+	"github.com/frozenpine/wstester/utils/log"
+	"github.com/frozenpine/wstester/utils/metrics"
+)
+
+var sqlModelsOnce sync.Once
+
+// registerSQLModels register public table models for subscribeSQL
+func registerSQLModels() {
+	sqlModelsOnce.Do(func() {
+		tables := map[string]interface{}{
+			"trade":          new(ngerest.Trade),
+			"instrument":     new(ngerest.Instrument),
+			"orderBookL2":    new(ngerest.OrderBookL2),
+			"orderBookL2_25": new(ngerest.OrderBookL2),
+			"orderBook10":    new(models.OrderBook10),
+			"quote":          new(ngerest.Quote),
+		}
+
+		for _, binSize := range utils.BinSizes {
+			tables["quoteBin"+binSize] = new(ngerest.Quote)
+			tables["tradeBin"+binSize] = new(ngerest.TradeBin)
+		}
+
+		for table, model := range tables {
+			if err := utils.RegisterTableModel(table, model); err != nil {
+				log.Warn("Register table model failed: ", err)
+			}
+		}
+	})
+}
+
+// filterResponse filter rows & project columns of table response by SQL table definition,
+// return nil if no row matched, partial is always returned as base of following data.
+func filterResponse(tableDef *utils.TableDef, filter utils.LinqFilter, rsp models.TableResponse) models.TableResponse {
+	data := filter(rsp.GetData())
+
+	if len(data) == 0 && rsp.GetAction() != models.PartialAction {
+		return nil
+	}
+
+	return models.NewFilteredResponse(tableDef.GetAliasName(), rsp.GetAction(), data)
+}
+
+// deltaTables tables whose insert, update & delete rows change book levels identified by id
+var deltaTables = map[string]bool{
+	"orderBookL2":    true,
+	"orderBookL2_25": true,
+}
+
+// deltaFilter filter book deltas by WHERE condition with levels sent to session,
+// level leaving condition is sent as delete & level entering condition is sent as insert,
+// so session's book always holds levels matching condition.
+type deltaFilter struct {
+	tableDef *utils.TableDef
+	project  utils.LinqFilter
+	// visible levels sent to session
+	visible map[int]bool
+}
+
+func (f *deltaFilter) filter(rsp models.TableResponse) []models.TableResponse {
+	mbl, ok := rsp.(*models.MBLResponse)
+	if !ok {
+		if filtered := filterResponse(f.tableDef, f.tableDef.GetFilter(), rsp); filtered != nil {
+			return []models.TableResponse{filtered}
+		}
+
+		return nil
+	}
+
+	var deletes, updates, inserts []interface{}
+
+	switch mbl.Action {
+	case models.PartialAction:
+		f.visible = make(map[int]bool)
+
+		for _, l2 := range mbl.Data {
+			if f.tableDef.Match(l2) {
+				f.visible[l2.ID] = true
+				inserts = append(inserts, l2)
+			}
+		}
+
+		return []models.TableResponse{
+			models.NewFilteredResponse(f.tableDef.GetAliasName(), models.PartialAction, f.project(inserts))}
+	case models.DeleteAction:
+		for _, l2 := range mbl.Data {
+			if f.visible[l2.ID] {
+				delete(f.visible, l2.ID)
+				deletes = append(deletes, l2)
+			}
+		}
+	default:
+		for _, l2 := range mbl.Data {
+			matched, visible := f.tableDef.Match(l2), f.visible[l2.ID]
+
+			switch {
+			case matched && visible && mbl.Action == models.UpdateAction:
+				updates = append(updates, l2)
+			case matched:
+				f.visible[l2.ID] = true
+				inserts = append(inserts, l2)
+			case visible:
+				delete(f.visible, l2.ID)
+				deletes = append(deletes, l2)
+			}
+		}
+	}
+
+	var results []models.TableResponse
+
+	for _, changes := range []struct {
+		action string
+		rows   []interface{}
+	}{
+		{models.DeleteAction, deletes}, {models.UpdateAction, updates}, {models.InsertAction, inserts},
+	} {
+		if len(changes.rows) > 0 {
+			results = append(results, models.NewFilteredResponse(
+				f.tableDef.GetAliasName(), changes.action, f.project(changes.rows)))
+		}
+	}
+
+	return results
+}
+
+// newSQLFilter make filter for table subscribed with SQL, deltas of book tables are filtered by deltaFilter
+func newSQLFilter(tableDef *utils.TableDef) func(models.TableResponse) []models.TableResponse {
+	if deltaTables[tableDef.GetName()] && tableDef.HasCondition() {
+		delta := deltaFilter{
+			tableDef: tableDef,
+			project:  tableDef.GetProjection(),
+			visible:  make(map[int]bool),
+		}
+
+		return delta.filter
+	}
+
+	filter := tableDef.GetFilter()
+
+	return func(rsp models.TableResponse) []models.TableResponse {
+		if filtered := filterResponse(tableDef, filter, rsp); filtered != nil {
+			return []models.TableResponse{filtered}
+		}
+
+		return nil
+	}
+}
+
+// handleSubscribeSQL subscribe public tables with SQL,
+// session only get rows matched WHERE condition with columns in SELECT statement,
+// orderBookL2 levels leaving WHERE condition are sent as delete,
+// SQL itself is the topic for unsubscribe.
+func (s *server) handleSubscribeSQL(req models.Request, client Session) []models.Response {
+	var rspList []models.Response
+
+	registerSQLModels()
+
+	errRsp := func(err string) models.Response {
+		rsp := models.ErrResponse{
+			Error:  err,
+			Status: http.StatusBadRequest,
+			Request: models.OperationRequest{
+				Operation: req.GetOperation(),
+				Args:      req.GetArgs(),
+			},
+		}
+
+		client.WriteJSONMessage(&rsp, false)
+
+		return &rsp
+	}
+
+	for _, sql := range req.GetArgs() {
+		if client.IsSubscribed(sql) {
+			rspList = append(rspList, errRsp("You are already subscribed to this topic: "+sql))
+			continue
+		}
+
+		tables, err := utils.ParseSQL(sql)
+		if err != nil {
+			rspList = append(rspList, errRsp("Invalid SQL: "+err.Error()))
+			continue
+		}
+
+		if len(tables) == 0 {
+			rspList = append(rspList, errRsp("Invalid SQL: no table selected"))
+			continue
+		}
+
+		waitRsp := make(chan bool, 0)
+		subscribed := 0
+
+		for _, tableDef := range tables {
+			tableName := tableDef.GetName()
+			_, _, chType, depth := s.parseTopic(tableName)
+
+			// table name in topic for slow consumer policy
+			topicStr := tableName + ":" + sql
+
+			for _, sym := range s.dataCaches.GetSymbols(tableName) {
+				cache := s.dataCaches.GetCache(tableName, sym)
+				if cache == nil {
+					continue
+				}
+
+				rspChan := cache.GetRspChannel(chType, depth)
+				if rspChan == nil {
+					continue
+				}
+				subscribed++
+
+				session, dataChan := rspChan.RetriveData()
+				sub := subscription{}
+				client.Subscribe(sql, func() {
+					sub.stop()
+					rspChan.ShutdownRetrive(session)
+				})
+				client.WatchQueue(sql, func() int { return len(dataChan) })
+
+				outMeter := metrics.DefaultRegistry.Meter(
+					"wstester_table_out_messages", "Outbound table messages sent to sessions.",
+					s.metricLabels(metrics.Labels{"table": tableName, "symbol": sym}))
+
+				go func(cache utils.Cache, rspChan utils.Channel, tableDef *utils.TableDef, chType utils.ChannelType, depth int) {
+					<-waitRsp
+
+					// destination is drained while taking snapshot, so dispatch never blocked by this session
+					go takeSnapshot(cache, rspChan, chType, depth, session)
+
+					filter := newSQLFilter(tableDef)
+
+					for data := range dataChan {
+						sub.forward(func() {
+							for _, filtered := range filter(data) {
+								if client.WriteTopicMessage(topicStr, filtered) == nil {
+									outMeter.Mark(1)
+								}
+							}
+						})
+					}
+				}(cache, rspChan, tableDef, chType, depth)
+			}
+		}
+
+		if subscribed == 0 {
+			rsp := newUnknownTableRsp(req, sql)
+
+			rspList = append(rspList, rsp)
+			client.WriteJSONMessage(rsp, false)
+
+			continue
+		}
+
+		rsp := models.SubscribeResponse{
+			Success:   true,
+			Subscribe: sql,
+			Request:   *req.(*models.OperationRequest),
+		}
+
+		rspList = append(rspList, &rsp)
+		client.WriteJSONMessage(&rsp, false)
+
+		close(waitRsp)
+	}
+
+	return rspList
+}
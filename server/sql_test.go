@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils"
+)
+
+func TestSubscribeSQL(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	conn := dialTestServer(t, httpSvr)
+	defer conn.Close()
+
+	sql := "SELECT price, size FROM trade WHERE size > 1000"
+	// html characters are escaped in json
+	topic := strings.Replace(sql, ">", `\u003e`, 1)
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribeSQL", Args: []string{"SELECT price FROM trade WHERE"}})
+	readTestMessage(t, conn, `"status":400`)
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribeSQL", Args: []string{"SELECT * FROM position"}})
+	readTestMessage(t, conn, `"status":400`)
+
+	// table without cache
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribeSQL", Args: []string{"SELECT * FROM instrument"}})
+	readTestMessage(t, conn, `"error":"Unknown or expired table: SELECT * FROM instrument","status":400`)
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribe", Args: []string{"instrument"}})
+	readTestMessage(t, conn, `"error":"Unknown or expired table: instrument","status":400`)
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribeSQL", Args: []string{sql}})
+	readTestMessage(t, conn, `"success":true,"subscribe":"`+topic)
+	readTestMessage(t, conn, `"table":"trade","action":"partial","data":[]`)
+
+	conn.WriteJSON(models.OperationRequest{Operation: "subscribeSQL", Args: []string{sql}})
+	readTestMessage(t, conn, `"status":400`)
+
+	small, big := newTestTrade(9000), newTestTrade(9001)
+	big.Data[0].Size = 2000
+
+	cache := svr.dataCaches.GetCache("trade", "XBTUSD")
+	cache.Append(utils.NewCacheInput(small))
+	cache.Append(utils.NewCacheInput(big))
+
+	readTestMessage(t, conn, `"table":"trade","action":"insert","data":[{"price":9001,"size":2000}]`)
+
+	conn.WriteJSON(models.OperationRequest{Operation: "unsubscribe", Args: []string{sql}})
+	readTestMessage(t, conn, `"success":true,"unsubscribe":"`+topic)
+
+	cache.Append(utils.NewCacheInput(big))
+
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond * 500))
+	if _, msg, err := conn.ReadMessage(); err == nil {
+		t.Fatal("unexpected message after unsubscribe:", string(msg))
+	}
+}
+
+func TestFilterResponse(t *testing.T) {
+	registerSQLModels()
+
+	tables, err := utils.ParseSQL("SELECT symbol, price AS px FROM trade AS bigTrade WHERE size >= 10 AND side = 'Buy'")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tableDef := tables["trade"]
+	filter := tableDef.GetFilter()
+
+	rsp := newTestTrade(9000)
+	if filterResponse(tableDef, filter, rsp) != nil {
+		t.Fatal("unmatched rows should be filtered")
+	}
+
+	rsp.Data[0].Size = 10
+	filtered := filterResponse(tableDef, filter, rsp)
+	if filtered == nil {
+		t.Fatal("matched rows filtered")
+	}
+
+	if msg := filtered.String(); !strings.Contains(msg, `"table":"bigTrade","action":"insert","data":[{"px":9000,"symbol":"XBTUSD"}]`) {
+		t.Fatal("filtered response miss-match:", msg)
+	}
+
+	partial := models.NewTradePartial()
+	if filtered := filterResponse(tableDef, filter, partial); filtered == nil || filtered.GetAction() != models.PartialAction {
+		t.Fatal("partial should never be filtered")
+	}
+}
+
+func TestDeltaFilter(t *testing.T) {
+	registerSQLModels()
+
+	tables, err := utils.ParseSQL("SELECT id, size FROM orderBookL2 WHERE size >= 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter := newSQLFilter(tables["orderBookL2"])
+
+	for _, c := range []struct {
+		rsp    *models.MBLResponse
+		expect []string
+	}{
+		{newTestMBL(models.PartialAction, &ngerest.OrderBookL2{ID: 1, Size: 20}, &ngerest.OrderBookL2{ID: 2, Size: 5}),
+			[]string{`"action":"partial","data":[{"id":1,"size":20}]`}},
+		// level leaving condition
+		{newTestMBL(models.UpdateAction, &ngerest.OrderBookL2{ID: 1, Size: 5}),
+			[]string{`"action":"delete","data":[{"id":1,"size":5}]`}},
+		// level entering condition
+		{newTestMBL(models.UpdateAction, &ngerest.OrderBookL2{ID: 2, Size: 15}),
+			[]string{`"action":"insert","data":[{"id":2,"size":15}]`}},
+		{newTestMBL(models.UpdateAction, &ngerest.OrderBookL2{ID: 1, Size: 6}, &ngerest.OrderBookL2{ID: 2, Size: 20}),
+			[]string{`"action":"update","data":[{"id":2,"size":20}]`}},
+		{newTestMBL(models.DeleteAction, &ngerest.OrderBookL2{ID: 1}, &ngerest.OrderBookL2{ID: 2}),
+			[]string{`"action":"delete","data":[{"id":2,"size":0}]`}},
+		{newTestMBL(models.InsertAction, &ngerest.OrderBookL2{ID: 3, Size: 1}), nil},
+	} {
+		var results []string
+		for _, filtered := range filter(c.rsp) {
+			results = append(results, filtered.String())
+		}
+
+		if len(results) != len(c.expect) {
+			t.Fatalf("filtered %s miss-match: %v", c.rsp.String(), results)
+		}
+
+		for idx, expect := range c.expect {
+			if !strings.Contains(results[idx], expect) {
+				t.Fatalf("filtered %s miss-match: %v", c.rsp.String(), results)
+			}
+		}
+	}
+}
+
+// BenchmarkFilterResponse server side filter cost for each session
+func BenchmarkFilterResponse(b *testing.B) {
+	registerSQLModels()
+
+	tables, err := utils.ParseSQL("SELECT price, size FROM trade WHERE size > 1000")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	tableDef := tables["trade"]
+	filter := tableDef.GetFilter()
+
+	rsp := newTestTrade(9000)
+	for idx := 0; idx < 9; idx++ {
+		rsp.Data = append(rsp.Data, newTestTrade(9000+float64(idx)).Data...)
+		rsp.Data[idx].Size = float32(idx * 500)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for idx := 0; idx < b.N; idx++ {
+		if filtered := filterResponse(tableDef, filter, rsp); filtered != nil {
+			if _, err := json.Marshal(filtered); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/frozenpine/wstester/utils/log"
+)
+
+const (
+	selfSignedOrg      = "wstester"
+	selfSignedValidity = time.Hour * 24 * 365
+)
+
+// TLSConfig tls config for wss listener
+type TLSConfig struct {
+	// Cert certificate file in PEM format
+	Cert string `toml:"cert"`
+	// Key private key file in PEM format
+	Key string `toml:"key"`
+	// SelfSigned generate self-signed certificate at startup if no cert specified
+	SelfSigned bool `toml:"self_signed"`
+	// SelfSignedOut file to write generated self-signed certificate in PEM format,
+	// so that clients verifying certificates can trust it
+	SelfSignedOut string `toml:"self_signed_out"`
+	// ClientCA CA file in PEM format to verify client certificates, empty means no client verify
+	ClientCA string `toml:"client_ca"`
+}
+
+// IsEnabled wether tls listener enabled
+func (c *TLSConfig) IsEnabled() bool {
+	return c != nil && (c.Cert != "" || c.SelfSigned)
+}
+
+// Validate check tls config
+func (c *TLSConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+
+	if (c.Cert == "") != (c.Key == "") {
+		return errors.New("tls cert & key must be specified together")
+	}
+
+	if c.ClientCA != "" && !c.IsEnabled() {
+		return errors.New("tls client ca specified without server certificate")
+	}
+
+	if c.SelfSignedOut != "" && (!c.SelfSigned || c.Cert != "") {
+		return errors.New("tls self-signed out specified without self-signed certificate")
+	}
+
+	return nil
+}
+
+// getTLSConfig create tls config for listener,
+// hosts used in self-signed certificate's subject alt names.
+func (c *TLSConfig) getTLSConfig(hosts ...string) (*tls.Config, error) {
+	var (
+		cert tls.Certificate
+		err  error
+	)
+
+	if c.Cert != "" {
+		cert, err = tls.LoadX509KeyPair(c.Cert, c.Key)
+	} else {
+		var certPEM, keyPEM []byte
+
+		if certPEM, keyPEM, err = generateCertificate(hosts...); err == nil {
+			cert, err = tls.X509KeyPair(certPEM, keyPEM)
+		}
+
+		if err == nil {
+			log.Info("Self-signed certificate generated with SHA-256 fingerprint: ",
+				certFingerprint(cert.Certificate[0]))
+		}
+
+		if err == nil && c.SelfSignedOut != "" {
+			if err = ioutil.WriteFile(c.SelfSignedOut, certPEM, 0644); err == nil {
+				log.Info("Self-signed certificate written to: ", c.SelfSignedOut)
+			}
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.ClientCA != "" {
+		caPEM, err := ioutil.ReadFile(c.ClientCA)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("no valid certificate found in client ca: " + c.ClientCA)
+		}
+
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return &cfg, nil
+}
+
+// certFingerprint format SHA-256 fingerprint of DER certificate as colon separated hex
+func certFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+
+	hexes := make([]string, len(sum))
+	for idx, b := range sum {
+		hexes[idx] = fmt.Sprintf("%02X", b)
+	}
+
+	return strings.Join(hexes, ":")
+}
+
+// generateCertificate generate self-signed certificate & key in PEM format,
+// certificate can also be used as CA & client certificate.
+func generateCertificate(hosts ...string) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{selfSignedOrg},
+			CommonName:   selfSignedOrg,
+		},
+		NotBefore: now.Add(-time.Hour),
+		NotAfter:  now.Add(selfSignedValidity),
+
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		hosts = append(hosts, hostname)
+	}
+	hosts = append(hosts, "localhost", "127.0.0.1", "::1")
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			if !ip.IsUnspecified() {
+				template.IPAddresses = append(template.IPAddresses, ip)
+			}
+		} else if host != "" {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return
+	}
+
+	certBuf := bytes.NewBuffer(nil)
+	pem.Encode(certBuf, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	keyBuf := bytes.NewBuffer(nil)
+	pem.Encode(keyBuf, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	return certBuf.Bytes(), keyBuf.Bytes(), nil
+}
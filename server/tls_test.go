@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestTLSValidate(t *testing.T) {
+	if err := (&TLSConfig{Cert: "cert.pem"}).Validate(); err == nil {
+		t.Fatal("cert without key should be invalid.")
+	}
+
+	if err := (&TLSConfig{ClientCA: "ca.pem"}).Validate(); err == nil {
+		t.Fatal("client ca without server certificate should be invalid.")
+	}
+
+	if err := (&TLSConfig{SelfSigned: true, ClientCA: "ca.pem"}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (&TLSConfig{Cert: "cert.pem", Key: "key.pem", SelfSignedOut: "out.pem"}).Validate(); err == nil {
+		t.Fatal("self-signed out without self-signed certificate should be invalid.")
+	}
+}
+
+func TestTLSListener(t *testing.T) {
+	certPEM, keyPEM, err := generateCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "wstester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(caFile, certPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	cfg := NewConfig()
+	cfg.Listen = net.ParseIP("127.0.0.1")
+	cfg.Port = getFreePort(t)
+	cfg.MockMode = MockNone
+	cfg.TLS.SelfSigned = true
+	cfg.TLS.ClientCA = caFile
+	cfg.TLS.SelfSignedOut = filepath.Join(dir, "server.pem")
+
+	svr := NewServer(ctx, cfg)
+	go svr.RunForever(ctx)
+
+	url := fmt.Sprintf("wss://127.0.0.1:%d%s", cfg.Port, cfg.BaseURI)
+
+	dialTLS := func(tlsCfg *tls.Config) (*websocket.Conn, error) {
+		dialer := websocket.Dialer{
+			TLSClientConfig:  tlsCfg,
+			HandshakeTimeout: time.Second,
+		}
+
+		var (
+			conn *websocket.Conn
+			err  error
+		)
+
+		// wait for server listening
+		for i := 0; i < 10; i++ {
+			if conn, _, err = dialer.Dial(url, nil); err == nil {
+				return conn, nil
+			}
+
+			time.Sleep(time.Millisecond * 100)
+		}
+
+		return nil, err
+	}
+
+	dial := func(certs ...tls.Certificate) (*websocket.Conn, error) {
+		return dialTLS(&tls.Config{InsecureSkipVerify: true, Certificates: certs})
+	}
+
+	conn, err := dial(clientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	readTestMessage(t, conn, `"info"`)
+
+	if conn, err := dial(); err == nil {
+		conn.Close()
+		t.Fatal("client without certificate should be rejected.")
+	}
+
+	// generated certificate written out can be trusted by verifying clients
+	serverPEM, err := ioutil.ReadFile(cfg.TLS.SelfSignedOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(serverPEM) {
+		t.Fatal("no certificate in self-signed out file")
+	}
+
+	verified, err := dialTLS(&tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified.Close()
+}
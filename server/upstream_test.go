@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frozenpine/wstester/mock"
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils"
+)
+
+func TestUpstreamConfig(t *testing.T) {
+	cfg := NewConfig()
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, modify := range []func(*mock.UpstreamConfig){
+		func(c *mock.UpstreamConfig) { c.URL = "tcp://127.0.0.1:9988" },
+		func(c *mock.UpstreamConfig) { c.Symbols = []string{"ETHUSD"} },
+		func(c *mock.UpstreamConfig) { c.Topics = []string{"order"} },
+		func(c *mock.UpstreamConfig) { c.APIKey = "testKey" },
+		func(c *mock.UpstreamConfig) { c.APIKey, c.APISecret, c.AuthURI = "testKey", "testSecret", "signature" },
+		func(c *mock.UpstreamConfig) { c.MaxReconnectDelay = 1 },
+	} {
+		cfg = NewConfig()
+		modify(cfg.Upstream)
+
+		if err := cfg.Validate(); err == nil {
+			t.Fatalf("invalid upstream config passed: %+v", cfg.Upstream)
+		}
+	}
+
+	upstream := mock.NewUpstreamConfig()
+	upstream.ReconnectDelay = 1
+	upstream.MaxReconnectDelay = 10
+
+	for failures, expect := range []time.Duration{
+		time.Second, time.Second * 2, time.Second * 4, time.Second * 8, time.Second * 10, time.Second * 10,
+	} {
+		if delay := upstream.Backoff(failures); delay != expect {
+			t.Fatalf("backoff delay miss-match after %d failures: %v", failures, delay)
+		}
+	}
+}
+
+func TestUpstreamLocalServer(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	svr, httpSvr := newTestServer(ctx, t)
+	defer httpSvr.Close()
+
+	svrCfg := *svr.cfg.Load()
+	svrCfg.SignatureURI = "/custom/signature"
+	svr.cfg.Store(&svrCfg)
+
+	svr.keyStore = NewKeyStore()
+	svr.keyStore.AddKey(&APIKey{Key: "upstreamKey", Secret: "upstreamSecret", ClientID: "1", AccountID: "2"})
+
+	cfg := mock.NewUpstreamConfig()
+	cfg.URL = strings.Replace(httpSvr.URL, "http", "ws", 1) + defaultBaseURI
+	cfg.Topics = []string{"trade"}
+	// upstream connection rejected if signed on wrong uri
+	cfg.APIKey, cfg.APISecret, cfg.AuthURI = "upstreamKey", "upstreamSecret", "/custom/signature"
+
+	td := utils.NewTradeCache(ctx, "XBTUSD")
+	mbl := utils.NewMBLCache(ctx, "XBTUSD")
+
+	go mock.Upstream(ctx, cfg, "XBTUSD", map[string]utils.Cache{"trade": td, "orderBookL2": mbl}, nil)
+
+	upTrade := svr.dataCaches.GetCache("trade", "XBTUSD")
+
+	deadline := time.Now().Add(time.Second * 3)
+	for time.Now().Before(deadline) {
+		upTrade.Append(utils.NewCacheInput(newTestTrade(9000)))
+
+		if snap := td.TakeSnapshot(0, nil, "").(*models.TradeResponse); len(snap.Data) > 0 {
+			if snap.Data[0].Price != 9000 {
+				t.Fatal("trade miss-match from upstream:", snap.String())
+			}
+
+			if mblSnap := mbl.TakeSnapshot(0, nil, "").(*models.MBLResponse); len(mblSnap.Data) > 0 {
+				t.Fatal("topic not configured cascaded from upstream")
+			}
+
+			return
+		}
+
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	t.Fatal("no trade cascaded from local upstream server")
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/frozenpine/wstester/models"
+)
+
+const maxBinLen int = 200
+
+var (
+	// BinSizes bin sizes for bucketed tables, e.g. quoteBin1m, tradeBin1m
+	BinSizes = []string{"1m", "5m", "1h", "1d"}
+
+	binDurations = map[string]time.Duration{
+		"1m": time.Minute,
+		"5m": time.Minute * 5,
+		"1h": time.Hour,
+		"1d": time.Hour * 24,
+	}
+)
+
+// binClock bucket clock for bin caches, bucket is identified by its end time
+type binClock struct {
+	size time.Duration
+	// end of open bucket, zero before first input
+	end time.Time
+}
+
+func (b *binClock) isOpen() bool {
+	return !b.end.IsZero()
+}
+
+// open open bucket for ts if no bucket opened
+func (b *binClock) open(ts time.Time) {
+	if !b.isOpen() {
+		b.end = ts.Truncate(b.size).Add(b.size)
+	}
+}
+
+// closeBefore close buckets ended before ts with closeFn,
+// at most maxBinLen buckets will be closed for a long gap.
+func (b *binClock) closeBefore(ts time.Time, closeFn func(end time.Time)) {
+	for count := 0; b.isOpen() && !ts.Before(b.end); count++ {
+		if count >= maxBinLen {
+			b.end = ts.Truncate(b.size).Add(b.size)
+			return
+		}
+
+		closeFn(b.end)
+
+		b.end = b.end.Add(b.size)
+	}
+}
+
+// closeLoop close live bucket on each bucket boundary even if no new input arrived,
+// buckets far behind wall clock (e.g. replayed data) are only closed by new inputs.
+func (c *tableCache) closeLoop(clock *binClock, closeFn func(end time.Time)) {
+	for {
+		now := time.Now()
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(now.Truncate(clock.size).Add(clock.size).Sub(now)):
+		}
+
+		if !c.enqueue(NewBreakpoint(func() models.TableResponse {
+			if now := time.Now(); clock.isOpen() && now.Sub(clock.end) < clock.size {
+				clock.closeBefore(now, closeFn)
+			}
+
+			return nil
+		})) {
+			return
+		}
+	}
+}
+
+func trimHistory(hisLen, depth, maxLen int) int {
+	if depth < 1 {
+		return MinInt(hisLen, maxLen)
+	}
+
+	return MinInts(maxLen, hisLen, depth)
+}
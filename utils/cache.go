@@ -3,10 +3,12 @@ package utils
 import (
 	"context"
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/frozenpine/wstester/models"
 	"github.com/frozenpine/wstester/utils/log"
+	"github.com/frozenpine/wstester/utils/metrics"
 )
 
 const (
@@ -52,6 +54,19 @@ type Cache interface {
 
 	// GetDefaultChannel get default channel with realtime all depth notify
 	GetDefaultChannel() Channel
+
+	// Status get cache status, detail status is queued in cache pipeline like TakeSnapshot.
+	Status() *CacheStatus
+}
+
+// CacheStatus status of table cache
+type CacheStatus struct {
+	Table     string                 `json:"table"`
+	Symbol    string                 `json:"symbol"`
+	Ready     bool                   `json:"ready"`
+	StartTime time.Time              `json:"startTime"`
+	Pipeline  int                    `json:"pipeline"`
+	Detail    map[string]interface{} `json:"detail,omitempty"`
 }
 
 // CacheInput wrapper structure for table response
@@ -91,6 +106,7 @@ func NewBreakpoint(breakpointFn func() models.TableResponse) *CacheInput {
 type tableCache struct {
 	channelGroup [3]map[int]Channel
 
+	Table      string
 	Symbol     string
 	pipeline   chan *CacheInput
 	cacheStart time.Time
@@ -98,9 +114,37 @@ type tableCache struct {
 	ctx        context.Context
 	IsReady    bool
 	IsClosed   bool
+	closeLock  sync.RWMutex
+	loopDone   chan struct{}
 
 	snapshotFn    func(int) models.TableResponse
 	handleInputFn func(*CacheInput)
+	statusFn      func() map[string]interface{}
+}
+
+// metricLabelsKey context key of extra metric labels for caches created with context
+type metricLabelsKey struct{}
+
+// WithMetricLabels make a context with extra labels for metrics of caches created with it,
+// so caches of different servers in one process never share metric series.
+func WithMetricLabels(ctx context.Context, labels metrics.Labels) context.Context {
+	return context.WithValue(ctx, metricLabelsKey{}, labels)
+}
+
+func (c *tableCache) metricLabels() metrics.Labels {
+	labels := metrics.Labels{"table": c.Table, "symbol": c.Symbol}
+
+	if c.ctx == nil {
+		return labels
+	}
+
+	if extra, ok := c.ctx.Value(metricLabelsKey{}).(metrics.Labels); ok {
+		for k, v := range extra {
+			labels[k] = v
+		}
+	}
+
+	return labels
 }
 
 func (c *tableCache) handleBreakpoint(in *CacheInput) bool {
@@ -114,6 +158,9 @@ func (c *tableCache) handleBreakpoint(in *CacheInput) bool {
 }
 
 func (c *tableCache) Start() error {
+	c.closeLock.Lock()
+	defer c.closeLock.Unlock()
+
 	if c.IsReady {
 		return errors.New("cache is already started")
 	}
@@ -132,10 +179,27 @@ func (c *tableCache) Start() error {
 		}
 	}
 
+	c.loopDone = make(chan struct{})
+	c.cacheStart = time.Now()
+
+	labels := c.metricLabels()
+	inMeter := metrics.DefaultRegistry.Meter(
+		"wstester_table_in_messages", "Inbound messages appended to table cache.", labels)
+	metrics.DefaultRegistry.GaugeFunc(
+		"wstester_cache_pipeline_length", "Inputs queued in table cache pipeline.", labels,
+		func() float64 { return float64(len(c.pipeline)) })
+	metrics.DefaultRegistry.GaugeFunc(
+		"wstester_cache_pipeline_capacity", "Table cache pipeline capacity.", labels,
+		func() float64 { return float64(cap(c.pipeline)) })
+
 	go func() {
 		defer func() {
+			c.closeLock.Lock()
 			c.IsReady = false
 			c.IsClosed = true
+			c.closeLock.Unlock()
+
+			close(c.loopDone)
 		}()
 
 		for {
@@ -155,6 +219,10 @@ func (c *tableCache) Start() error {
 					log.Panic("handleInputFn is nil.")
 				}
 
+				if !obj.IsBreakPoint() {
+					inMeter.Mark(1)
+				}
+
 				c.handleInputFn(obj)
 			}
 		}
@@ -163,22 +231,35 @@ func (c *tableCache) Start() error {
 	c.IsReady = true
 	c.IsClosed = false
 	close(c.ready)
-	c.cacheStart = time.Now()
 
 	return nil
 }
 
 func (c *tableCache) Stop() error {
-	if c.IsClosed == true {
+	c.closeLock.Lock()
+
+	if c.IsClosed {
+		c.closeLock.Unlock()
+
 		return errors.New("cache is already stopped")
 	}
-	if c.IsReady == false {
+	if !c.IsReady {
+		c.closeLock.Unlock()
+
 		return errors.New("cache is not ready")
 	}
 
 	c.IsClosed = true
 	c.IsReady = false
 	close(c.pipeline)
+	c.closeLock.Unlock()
+
+	// wait for inputs in pipeline finished before channels closed
+	<-c.loopDone
+
+	labels := c.metricLabels()
+	metrics.DefaultRegistry.Unregister("wstester_cache_pipeline_length", labels)
+	metrics.DefaultRegistry.Unregister("wstester_cache_pipeline_capacity", labels)
 
 	for _, chGroup := range c.channelGroup {
 		for _, rspChan := range chGroup {
@@ -189,19 +270,34 @@ func (c *tableCache) Stop() error {
 	return nil
 }
 
+// isReady check cache state with close lock
+func (c *tableCache) isReady() (ready, closed bool) {
+	c.closeLock.RLock()
+	defer c.closeLock.RUnlock()
+
+	return c.IsReady, c.IsClosed
+}
+
 func (c *tableCache) Ready() <-chan struct{} {
 	return c.ready
 }
 
 func (c *tableCache) TakeSnapshot(depth int, publish Channel, session string) models.TableResponse {
-	ch := make(chan models.TableResponse, 1)
-
-	snapFn := func() models.TableResponse {
+	return c.takeSnapshot(func() models.TableResponse {
 		if c.snapshotFn == nil {
 			log.Panic("snapshotFn is nil.")
 		}
 
-		snap := c.snapshotFn(depth)
+		return c.snapshotFn(depth)
+	}, publish, session)
+}
+
+// takeSnapshot queue snapshotFn in cache pipeline & wait for its result
+func (c *tableCache) takeSnapshot(snapshotFn func() models.TableResponse, publish Channel, session string) models.TableResponse {
+	ch := make(chan models.TableResponse, 1)
+
+	snapFn := func() models.TableResponse {
+		snap := snapshotFn()
 
 		if publish != nil {
 			if err := publish.PublishDataToDestination(snap, session); err != nil {
@@ -215,9 +311,22 @@ func (c *tableCache) TakeSnapshot(depth int, publish Channel, session string) mo
 		return snap
 	}
 
-	c.pipeline <- NewBreakpoint(snapFn)
+	if !c.enqueue(NewBreakpoint(snapFn)) {
+		return nil
+	}
 
-	return <-ch
+	select {
+	case snap := <-ch:
+		return snap
+	case <-c.loopDone:
+		// loop exited, breakpoint may be finished just before exit
+		select {
+		case snap := <-ch:
+			return snap
+		default:
+			return nil
+		}
+	}
 }
 
 func (c *tableCache) GetRspChannel(chType ChannelType, depth int) Channel {
@@ -233,15 +342,71 @@ func (c *tableCache) GetRspChannel(chType ChannelType, depth int) Channel {
 }
 
 func (c *tableCache) Append(in *CacheInput) {
-	c.pipeline <- in
+	c.enqueue(in)
 }
 
-func (c *tableCache) GetDefaultChannel() Channel {
+// enqueue put input in pipeline, input will be dropped if cache closed
+func (c *tableCache) enqueue(in *CacheInput) bool {
+	c.closeLock.RLock()
+	defer c.closeLock.RUnlock()
+
 	if c.IsClosed {
+		return false
+	}
+
+	select {
+	case c.pipeline <- in:
+		return true
+	case <-c.ctx.Done():
+		return false
+	}
+}
+
+func (c *tableCache) Status() *CacheStatus {
+	ready, _ := c.isReady()
+
+	status := CacheStatus{
+		Table:     c.Table,
+		Symbol:    c.Symbol,
+		Ready:     ready,
+		StartTime: c.cacheStart,
+		Pipeline:  len(c.pipeline),
+	}
+
+	if c.statusFn == nil {
+		return &status
+	}
+
+	ch := make(chan map[string]interface{}, 1)
+
+	statusFn := func() models.TableResponse {
+		ch <- c.statusFn()
+
 		return nil
 	}
 
-	if !c.IsReady {
+	if c.enqueue(NewBreakpoint(statusFn)) {
+		select {
+		case status.Detail = <-ch:
+		case <-c.loopDone:
+			select {
+			case status.Detail = <-ch:
+			default:
+			}
+		}
+	}
+
+	return &status
+}
+
+func (c *tableCache) GetDefaultChannel() Channel {
+	ready, closed := c.isReady()
+
+	if closed {
+		return nil
+	}
+
+	if !ready {
 		<-c.Ready()
 	}
 
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"context"
+
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils/log"
+)
+
+// OrderBook10Cache retrive & store latest orderBook10 snapshot
+type OrderBook10Cache struct {
+	tableCache
+
+	book         *models.OrderBook10
+	historyCount int64
+}
+
+func (c *OrderBook10Cache) snapshot(depth int) models.TableResponse {
+	snap := models.NewOrderBook10Partial()
+	snap.Table = c.Table
+
+	if c.book != nil {
+		snap.Data = []*models.OrderBook10{c.book}
+	}
+
+	return snap
+}
+
+func (c *OrderBook10Cache) status() map[string]interface{} {
+	status := map[string]interface{}{
+		"historyCount": c.historyCount,
+	}
+
+	if c.book != nil {
+		status["bidDepth"] = len(c.book.Bids)
+		status["askDepth"] = len(c.book.Asks)
+	}
+
+	return status
+}
+
+func (c *OrderBook10Cache) handleInput(input *CacheInput) {
+	if c.handleBreakpoint(input) {
+		return
+	}
+
+	if input.msg == nil {
+		log.Error("OrderBook10 notify content is empty")
+		return
+	}
+
+	book, ok := input.msg.(*models.OrderBook10Response)
+	if !ok {
+		log.Error("Can not convert cache input to OrderBook10Response: ", input.msg.String())
+		return
+	}
+
+	switch book.Action {
+	case models.PartialAction:
+		if c.book == nil {
+			// 防止client端使用cache时，partial数据无输出的问题
+			c.channelGroup[Realtime][0].PublishData(book)
+		}
+	case models.UpdateAction:
+		c.channelGroup[Realtime][0].PublishData(book)
+	default:
+		log.Error("Invalid action for orderBook10 cache: ", book.Action)
+		return
+	}
+
+	c.historyCount += int64(len(book.Data))
+
+	// each data is a full snapshot, only the latest one is kept
+	if dataLen := len(book.Data); dataLen > 0 {
+		c.book = book.Data[dataLen-1]
+	}
+}
+
+// NewOrderBook10Cache make a new orderBook10 cache.
+func NewOrderBook10Cache(ctx context.Context, symbol string) Cache {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	book := OrderBook10Cache{}
+	book.Table = "orderBook10"
+	book.Symbol = symbol
+	book.ctx = ctx
+	book.handleInputFn = book.handleInput
+	book.snapshotFn = book.snapshot
+	book.statusFn = book.status
+	book.pipeline = make(chan *CacheInput, 1000)
+	book.ready = make(chan struct{})
+	book.channelGroup[Realtime] = map[int]Channel{
+		0: &rspChannel{
+			ctx:           ctx,
+			destinations:  map[string]chan<- models.TableResponse{},
+			childChannels: map[string]Channel{},
+		},
+	}
+
+	if err := book.Start(); err != nil {
+		log.Panic(err)
+	}
+
+	return &book
+}
@@ -40,6 +40,13 @@ func (c *InstrumentCache) snapshot(depth int) models.TableResponse {
 	return rsp
 }
 
+func (c *InstrumentCache) status() map[string]interface{} {
+	return map[string]interface{}{
+		"instruments":     len(c.insCache),
+		"markPriceLength": len(c.markPriceList),
+	}
+}
+
 func (c *InstrumentCache) handleInput(input *CacheInput) {
 	if c.handleBreakpoint(input) {
 		return
@@ -196,10 +203,12 @@ func NewInstrumentCache(ctx context.Context, symbol string) Cache {
 	}
 
 	ins := InstrumentCache{}
+	ins.Table = "instrument"
 	ins.Symbol = symbol
 	ins.ctx = ctx
 	ins.handleInputFn = ins.handleInput
 	ins.snapshotFn = ins.snapshot
+	ins.statusFn = ins.status
 	ins.pipeline = make(chan *CacheInput, 1000)
 	ins.ready = make(chan struct{})
 	ins.channelGroup[Realtime] = map[int]Channel{
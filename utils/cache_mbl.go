@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/frozenpine/ngerest"
 	"github.com/frozenpine/wstester/models"
@@ -28,6 +29,127 @@ type MBLCache struct {
 		bestSize, lastSize   float32
 	}
 	l2Cache map[float64]*ngerest.OrderBookL2
+
+	// quote cache fed by best quote changes
+	quote Cache
+	// last published book snapshot for each snapshot channel depth
+	lastBook map[int]*models.OrderBook10
+
+	// conflate interval for realtime channels, 0 means publish immediately
+	conflateInterval time.Duration
+	conflateCancel   context.CancelFunc
+	// pending responses for each realtime channel depth in conflate interval
+	pending map[int][]*models.MBLResponse
+}
+
+// SetConflateInterval set interval for realtime channels publishing,
+// pending changes in interval are merged on price level before published, 0 means publish immediately.
+func (c *MBLCache) SetConflateInterval(interval time.Duration) {
+	c.enqueue(NewBreakpoint(func() models.TableResponse {
+		if interval == c.conflateInterval {
+			return nil
+		}
+
+		if c.conflateCancel != nil {
+			c.conflateCancel()
+			c.conflateCancel = nil
+		}
+
+		c.flushPending()
+
+		c.conflateInterval = interval
+
+		if interval > 0 {
+			var ctx context.Context
+			ctx, c.conflateCancel = context.WithCancel(c.ctx)
+
+			go c.flushLoop(ctx, interval)
+		}
+
+		return nil
+	}))
+}
+
+func (c *MBLCache) flushLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !c.enqueue(NewBreakpoint(func() models.TableResponse {
+				c.flushPending()
+
+				return nil
+			})) {
+				return
+			}
+		}
+	}
+}
+
+// flushPending publish merged pending responses in realtime channels
+func (c *MBLCache) flushPending() {
+	for depth, rspList := range c.pending {
+		if len(rspList) < 1 {
+			continue
+		}
+
+		ch := c.channelGroup[Realtime][depth]
+
+		for _, rsp := range ConflateMBL(rspList...) {
+			ch.PublishData(rsp)
+		}
+
+		c.pending[depth] = nil
+	}
+}
+
+// publishRealtime publish response in realtime channel or pend it in conflate interval
+func (c *MBLCache) publishRealtime(depth int, ch Channel, rsp *models.MBLResponse) {
+	if c.conflateInterval <= 0 {
+		ch.PublishData(rsp)
+		return
+	}
+
+	if c.pending == nil {
+		c.pending = make(map[int][]*models.MBLResponse)
+	}
+
+	c.pending[depth] = append(c.pending[depth], rsp)
+}
+
+// SetQuoteCache set quote cache fed by best quote changes
+func (c *MBLCache) SetQuoteCache(quote Cache) {
+	c.enqueue(NewBreakpoint(func() models.TableResponse {
+		c.quote = quote
+
+		return nil
+	}))
+}
+
+func (c *MBLCache) publishQuote() {
+	if c.quote == nil {
+		return
+	}
+
+	ts := ngerest.NGETime(time.Now())
+
+	rsp := models.QuoteResponse{}
+	rsp.Table = "quote"
+	rsp.Action = models.InsertAction
+	rsp.Data = []*ngerest.Quote{{
+		Timestamp: &ts,
+		Symbol:    c.Symbol,
+		BidSize:   c.BestBidSize(),
+		BidPrice:  c.BestBidPrice(),
+		AskPrice:  c.BestAskPrice(),
+		AskSize:   c.BestAskSize(),
+	}}
+
+	c.quote.Append(NewCacheInput(&rsp))
 }
 
 // BestBidPrice best bid price
@@ -73,6 +195,9 @@ func (c *MBLCache) snapshot(depth int) models.TableResponse {
 		sellLength, buyLength, sellDepth, buyDepth int
 	)
 
+	// pending changes already applied in snapshot, flush them before following changes
+	c.flushPending()
+
 	snap := models.NewMBLPartial()
 
 	sellLength = len(c.askPrices)
@@ -105,12 +230,87 @@ func (c *MBLCache) snapshot(depth int) models.TableResponse {
 	return snap
 }
 
+// bookSnapshot top levels snapshot in depth, levels are ordered from best price
+func (c *MBLCache) bookSnapshot(depth int) *models.OrderBook10 {
+	ts := ngerest.NGETime(time.Now())
+
+	book := models.OrderBook10{
+		Symbol:    c.Symbol,
+		Bids:      make([][2]float64, 0, depth),
+		Asks:      make([][2]float64, 0, depth),
+		Timestamp: &ts,
+	}
+
+	for idx := len(c.bidPrices) - 1; idx >= 0 && len(book.Bids) < depth; idx-- {
+		price := c.bidPrices[idx]
+		book.Bids = append(book.Bids, [2]float64{price, float64(c.l2Cache[price].Size)})
+	}
+
+	for idx := len(c.askPrices) - 1; idx >= 0 && len(book.Asks) < depth; idx-- {
+		price := c.askPrices[idx]
+		book.Asks = append(book.Asks, [2]float64{price, float64(c.l2Cache[price].Size)})
+	}
+
+	return &book
+}
+
+// TakeBookSnapshot take top levels snapshot for snapshot channel in depth as partial,
+// snapshot operation is queued in cache pipeline like TakeSnapshot.
+func (c *MBLCache) TakeBookSnapshot(depth int, publish Channel, session string) models.TableResponse {
+	return c.takeSnapshot(func() models.TableResponse {
+		snap := models.NewOrderBook10Partial()
+		snap.Table = fmt.Sprintf("orderBook%d", depth)
+		snap.Data = []*models.OrderBook10{c.bookSnapshot(depth)}
+
+		return snap
+	}, publish, session)
+}
+
+// publishBooks publish top levels snapshot in snapshot channels if any level in depth changed
+func (c *MBLCache) publishBooks() {
+	for depth, ch := range c.channelGroup[Snapshot] {
+		book := c.bookSnapshot(depth)
+
+		if book.SameLevels(c.lastBook[depth]) {
+			continue
+		}
+
+		if c.lastBook == nil {
+			c.lastBook = make(map[int]*models.OrderBook10)
+		}
+		c.lastBook[depth] = book
+
+		rsp := models.OrderBook10Response{}
+		rsp.Table = fmt.Sprintf("orderBook%d", depth)
+		rsp.Action = models.UpdateAction
+		rsp.Data = []*models.OrderBook10{book}
+
+		ch.PublishData(&rsp)
+	}
+}
+
+func (c *MBLCache) status() map[string]interface{} {
+	return map[string]interface{}{
+		"bidDepth":     len(c.bidPrices),
+		"askDepth":     len(c.askPrices),
+		"bestBidPrice": c.BestBidPrice(),
+		"bestBidSize":  c.BestBidSize(),
+		"bestAskPrice": c.BestAskPrice(),
+		"bestAskSize":  c.BestAskSize(),
+		"historyCount": c.historyCount,
+	}
+}
+
 func (c *MBLCache) dispatchRsp(mbl *models.MBLResponse, limitRsp map[int][2]*models.MBLResponse) {
 	if c.IsQuoteChange() {
 		log.Debugf("Best Buy: %.1f@%.0f, Best Sell: %.1f@%.0f",
 			c.BestBidPrice(), c.BestBidSize(), c.BestAskPrice(), c.BestAskSize())
+
+		c.publishQuote()
 	}
 
+	c.publishBooks()
+
 	// apply an partial
 	if limitRsp == nil {
 		return
@@ -118,7 +318,7 @@ func (c *MBLCache) dispatchRsp(mbl *models.MBLResponse, limitRsp map[int][2]*mod
 
 	for depth, ch := range c.channelGroup[Realtime] {
 		if depth == 0 {
-			ch.PublishData(mbl)
+			c.publishRealtime(depth, ch, mbl)
 			continue
 		}
 
@@ -126,7 +326,7 @@ func (c *MBLCache) dispatchRsp(mbl *models.MBLResponse, limitRsp map[int][2]*mod
 			for _, rsp := range rspList {
 				if rsp != nil && len(rsp.Data) > 0 {
 					rsp.Table = fmt.Sprintf("%s_%d", rsp.Table, depth)
-					ch.PublishData(rsp)
+					c.publishRealtime(depth, ch, rsp)
 				}
 			}
 		}
@@ -245,7 +445,6 @@ func (c *MBLCache) applyData(data *models.MBLResponse) (map[int][2]*models.MBLRe
 				return nil, err
 			}
 
-			// TODO: 压缩合并两次L2_25更新，以减少更新数量
 			for limit, rspList := range limitRspMap {
 				if depth <= limit {
 					rspList[0].Data = append(rspList[0].Data, ord)
@@ -304,6 +503,17 @@ func (c *MBLCache) initCache() {
 	c.bidPrices = []float64{}
 }
 
+// bestQuote get best price & size from side's price list, zero if side is empty
+func (c *MBLCache) bestQuote(prices []float64) (float64, float32) {
+	if len(prices) < 1 {
+		return 0, 0
+	}
+
+	best := prices[len(prices)-1]
+
+	return best, c.l2Cache[best].Size
+}
+
 func (c *MBLCache) handlePartial(data []*ngerest.OrderBookL2) {
 	if len(c.l2Cache) < 1 {
 		c.initCache()
@@ -324,10 +534,8 @@ func (c *MBLCache) handlePartial(data []*ngerest.OrderBookL2) {
 
 		ReverseFloat64Slice(c.bidPrices)
 
-		c.bidQuote.bestPrice = c.bidPrices[len(c.bidPrices)-1]
-		c.bidQuote.bestSize = c.l2Cache[c.bidQuote.bestPrice].Size
-		c.askQuote.bestPrice = c.askPrices[len(c.askPrices)-1]
-		c.askQuote.bestSize = c.l2Cache[c.askQuote.bestPrice].Size
+		c.bidQuote.bestPrice, c.bidQuote.bestSize = c.bestQuote(c.bidPrices)
+		c.askQuote.bestPrice, c.askQuote.bestSize = c.bestQuote(c.askPrices)
 
 		snap := c.snapshot(0)
 
@@ -445,8 +653,8 @@ func (c *MBLCache) handleDelete(ord *ngerest.OrderBookL2) (int, error) {
 		depth = originLen - idx
 
 		if depth == 1 {
-			c.bidQuote.lastPrice, c.bidQuote.bestPrice = c.bidQuote.bestPrice, c.bidPrices[len(c.bidPrices)-1]
-			c.bidQuote.lastSize, c.bidQuote.bestSize = c.bidQuote.bestSize, c.l2Cache[c.bidQuote.bestPrice].Size
+			c.bidQuote.lastPrice, c.bidQuote.lastSize = c.bidQuote.bestPrice, c.bidQuote.bestSize
+			c.bidQuote.bestPrice, c.bidQuote.bestSize = c.bestQuote(c.bidPrices)
 		}
 	case "Sell":
 		originLen := len(c.askPrices)
@@ -454,8 +662,8 @@ func (c *MBLCache) handleDelete(ord *ngerest.OrderBookL2) (int, error) {
 		depth = originLen - idx
 
 		if depth == 1 {
-			c.askQuote.lastPrice, c.askQuote.bestPrice = c.askQuote.bestPrice, c.askPrices[len(c.askPrices)-1]
-			c.askQuote.lastSize, c.askQuote.bestSize = c.askQuote.bestSize, c.l2Cache[c.askQuote.bestPrice].Size
+			c.askQuote.lastPrice, c.askQuote.lastSize = c.askQuote.bestPrice, c.askQuote.bestSize
+			c.askQuote.bestPrice, c.askQuote.bestSize = c.bestQuote(c.askPrices)
 		}
 	default:
 		err = errors.New("invalid order side: " + ord.Side)
@@ -562,13 +770,33 @@ func (c *MBLCache) NewDepthChannel(depth int) error {
 		ctx:           c.ctx,
 	}
 
-	if err := c.channelGroup[Realtime][25].Start(); err != nil {
+	if err := c.channelGroup[Realtime][depth].Start(); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// NewSnapshotChannel create an new snapshot channel in cache,
+// top levels snapshot in depth is published when any level in depth changed.
+func (c *MBLCache) NewSnapshotChannel(depth int) error {
+	if depth < 1 {
+		return fmt.Errorf("invalid snapshot depth: %d", depth)
+	}
+
+	if c.channelGroup[Snapshot] == nil {
+		c.channelGroup[Snapshot] = make(map[int]Channel)
+	}
+
+	c.channelGroup[Snapshot][depth] = &rspChannel{
+		destinations:  map[string]chan<- models.TableResponse{},
+		childChannels: map[string]Channel{},
+		ctx:           c.ctx,
+	}
+
+	return c.channelGroup[Snapshot][depth].Start()
+}
+
 // NewMBLCache make a new MBL cache.
 func NewMBLCache(ctx context.Context, symbol string) Cache {
 	if ctx == nil {
@@ -576,10 +804,12 @@ func NewMBLCache(ctx context.Context, symbol string) Cache {
 	}
 
 	mbl := MBLCache{}
+	mbl.Table = "orderBookL2"
 	mbl.Symbol = symbol
 	mbl.ctx = ctx
 	mbl.handleInputFn = mbl.handleInput
 	mbl.snapshotFn = mbl.snapshot
+	mbl.statusFn = mbl.status
 	mbl.pipeline = make(chan *CacheInput, 1000)
 	mbl.ready = make(chan struct{})
 	mbl.channelGroup[Realtime] = map[int]Channel{
@@ -1,9 +1,11 @@
 package utils
 
 import (
+	"context"
 	"testing"
 
 	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/models"
 )
 
 func TestSnapshot(t *testing.T) {
@@ -69,3 +71,43 @@ func BenchmarkInsertSell(b *testing.B) {
 		}
 	}
 }
+
+func TestEmptySide(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	cache := NewMBLCache(ctx, "XBTUSD").(*MBLCache)
+
+	cache.Append(NewCacheInput(models.NewMBLPartial()))
+	cache.TakeSnapshot(0, nil, "")
+
+	if cache.BestBidPrice() != 0 || cache.BestAskPrice() != 0 {
+		t.Fatal("best quote not empty for empty partial")
+	}
+
+	ord := ngerest.OrderBookL2{Symbol: "XBTUSD", ID: 1, Price: 9990, Size: 10, Side: "Buy"}
+
+	insert := models.MBLResponse{}
+	insert.Table = "orderBookL2"
+	insert.Action = models.InsertAction
+	insert.Data = []*ngerest.OrderBookL2{&ord}
+
+	cache.Append(NewCacheInput(&insert))
+	cache.TakeSnapshot(0, nil, "")
+
+	if cache.BestBidPrice() != 9990 || cache.BestBidSize() != 10 {
+		t.Fatal("best bid miss-match after insert")
+	}
+
+	del := models.MBLResponse{}
+	del.Table = "orderBookL2"
+	del.Action = models.DeleteAction
+	del.Data = []*ngerest.OrderBookL2{{Symbol: "XBTUSD", ID: 1, Price: 9990, Side: "Buy"}}
+
+	cache.Append(NewCacheInput(&del))
+	cache.TakeSnapshot(0, nil, "")
+
+	if cache.BestBidPrice() != 0 || cache.BestBidSize() != 0 {
+		t.Fatal("best bid not empty after last level deleted")
+	}
+}
@@ -0,0 +1,270 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/models"
+	"github.com/frozenpine/wstester/utils/log"
+)
+
+const maxQuoteLen int = 200
+
+// QuoteCache retrive & store quote data, quote inserts are also fed to bin caches
+type QuoteCache struct {
+	tableCache
+
+	historyQuote []*ngerest.Quote
+	bins         []Cache
+}
+
+// AddBinCache add bin cache fed by quote inserts
+func (c *QuoteCache) AddBinCache(bin Cache) {
+	c.enqueue(NewBreakpoint(func() models.TableResponse {
+		c.bins = append(c.bins, bin)
+
+		return nil
+	}))
+}
+
+func (c *QuoteCache) snapshot(depth int) models.TableResponse {
+	snap := models.NewQuotePartial()
+
+	hisLen := len(c.historyQuote)
+
+	snap.Data = c.historyQuote[hisLen-trimHistory(hisLen, depth, maxQuoteLen):]
+
+	return snap
+}
+
+func (c *QuoteCache) status() map[string]interface{} {
+	status := map[string]interface{}{
+		"historyLength": len(c.historyQuote),
+		"bins":          len(c.bins),
+	}
+
+	if hisLen := len(c.historyQuote); hisLen > 0 {
+		last := c.historyQuote[hisLen-1]
+
+		status["bidPrice"] = last.BidPrice
+		status["askPrice"] = last.AskPrice
+	}
+
+	return status
+}
+
+func (c *QuoteCache) handleInput(input *CacheInput) {
+	if c.handleBreakpoint(input) {
+		return
+	}
+
+	if input.msg == nil {
+		log.Error("Quote notify content is empty")
+		return
+	}
+
+	quote, ok := input.msg.(*models.QuoteResponse)
+	if !ok {
+		log.Error("Can not convert cache input to QuoteResponse: ", input.msg.String())
+		return
+	}
+
+	switch quote.Action {
+	case models.PartialAction:
+		if len(c.historyQuote) < 1 {
+			// 防止client端使用cache时，partial数据无输出的问题
+			c.channelGroup[Realtime][0].PublishData(quote)
+		}
+
+		c.historyQuote = quote.Data
+	case models.InsertAction:
+		c.historyQuote = append(c.historyQuote, quote.Data...)
+
+		if hisLen := len(c.historyQuote); hisLen > maxQuoteLen*maxMultiple {
+			c.historyQuote = c.historyQuote[hisLen-maxQuoteLen*maxMultiple/2:]
+		}
+
+		c.channelGroup[Realtime][0].PublishData(quote)
+
+		for _, bin := range c.bins {
+			bin.Append(input)
+		}
+	default:
+		log.Error("Invalid action for quote cache: ", quote.Action)
+	}
+}
+
+// NewQuoteCache make a new quote cache.
+func NewQuoteCache(ctx context.Context, symbol string) Cache {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	quote := QuoteCache{}
+	quote.Table = "quote"
+	quote.Symbol = symbol
+	quote.ctx = ctx
+	quote.handleInputFn = quote.handleInput
+	quote.snapshotFn = quote.snapshot
+	quote.statusFn = quote.status
+	quote.pipeline = make(chan *CacheInput, 1000)
+	quote.ready = make(chan struct{})
+	quote.channelGroup[Realtime] = map[int]Channel{
+		0: &rspChannel{
+			ctx:           ctx,
+			destinations:  map[string]chan<- models.TableResponse{},
+			childChannels: map[string]Channel{},
+		},
+	}
+
+	if err := quote.Start(); err != nil {
+		log.Panic(err)
+	}
+
+	return &quote
+}
+
+// QuoteBinCache bucketed quote cache, quote at bucket close is published with bucket end timestamp,
+// bins received from upstream are stored & published as they are.
+type QuoteBinCache struct {
+	tableCache
+
+	clock     binClock
+	lastQuote *ngerest.Quote
+
+	historyBin []*ngerest.Quote
+}
+
+func (c *QuoteBinCache) snapshot(depth int) models.TableResponse {
+	snap := models.NewQuotePartial()
+	snap.Table = c.Table
+
+	hisLen := len(c.historyBin)
+
+	snap.Data = c.historyBin[hisLen-trimHistory(hisLen, depth, maxBinLen):]
+
+	return snap
+}
+
+func (c *QuoteBinCache) status() map[string]interface{} {
+	return map[string]interface{}{
+		"historyLength": len(c.historyBin),
+		"binEnd":        c.clock.end,
+	}
+}
+
+func (c *QuoteBinCache) appendBins(bins ...*ngerest.Quote) {
+	c.historyBin = append(c.historyBin, bins...)
+
+	if hisLen := len(c.historyBin); hisLen > maxBinLen*maxMultiple {
+		c.historyBin = c.historyBin[hisLen-maxBinLen*maxMultiple/2:]
+	}
+}
+
+// closeBin publish last quote as bin for bucket, buckets without new quote carry last quote
+func (c *QuoteBinCache) closeBin(end time.Time) {
+	ts := ngerest.NGETime(end)
+
+	bin := *c.lastQuote
+	bin.Timestamp = &ts
+
+	c.appendBins(&bin)
+
+	rsp := models.QuoteResponse{}
+	rsp.Table = c.Table
+	rsp.Action = models.InsertAction
+	rsp.Data = []*ngerest.Quote{&bin}
+
+	c.channelGroup[Realtime][0].PublishData(&rsp)
+}
+
+func (c *QuoteBinCache) handleQuote(quote *ngerest.Quote) {
+	ts := time.Now()
+	if quote.Timestamp != nil {
+		ts = time.Time(*quote.Timestamp)
+	}
+
+	c.clock.closeBefore(ts, c.closeBin)
+	c.clock.open(ts)
+
+	c.lastQuote = quote
+}
+
+func (c *QuoteBinCache) handleInput(input *CacheInput) {
+	if c.handleBreakpoint(input) {
+		return
+	}
+
+	if input.msg == nil {
+		log.Error("Quote bin notify content is empty")
+		return
+	}
+
+	quote, ok := input.msg.(*models.QuoteResponse)
+	if !ok {
+		log.Error("Can not convert cache input to QuoteResponse: ", input.msg.String())
+		return
+	}
+
+	if quote.Table != c.Table {
+		for _, q := range quote.Data {
+			c.handleQuote(q)
+		}
+
+		return
+	}
+
+	switch quote.Action {
+	case models.PartialAction:
+		if len(c.historyBin) < 1 {
+			// 防止client端使用cache时，partial数据无输出的问题
+			c.channelGroup[Realtime][0].PublishData(quote)
+		}
+
+		c.historyBin = quote.Data
+	case models.InsertAction:
+		c.appendBins(quote.Data...)
+
+		c.channelGroup[Realtime][0].PublishData(quote)
+	default:
+		log.Error("Invalid action for quote bin cache: ", quote.Action)
+	}
+}
+
+// NewQuoteBinCache make a new quote bin cache, binSize must be one of BinSizes.
+func NewQuoteBinCache(ctx context.Context, symbol, binSize string) Cache {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	duration, exist := binDurations[binSize]
+	if !exist {
+		log.Panic("invalid bin size: ", binSize)
+	}
+
+	bin := QuoteBinCache{clock: binClock{size: duration}}
+	bin.Table = "quoteBin" + binSize
+	bin.Symbol = symbol
+	bin.ctx = ctx
+	bin.handleInputFn = bin.handleInput
+	bin.snapshotFn = bin.snapshot
+	bin.statusFn = bin.status
+	bin.pipeline = make(chan *CacheInput, 1000)
+	bin.ready = make(chan struct{})
+	bin.channelGroup[Realtime] = map[int]Channel{
+		0: &rspChannel{
+			ctx:           ctx,
+			destinations:  map[string]chan<- models.TableResponse{},
+			childChannels: map[string]Channel{},
+		},
+	}
+
+	if err := bin.Start(); err != nil {
+		log.Panic(err)
+	}
+
+	go bin.closeLoop(&bin.clock, bin.closeBin)
+
+	return &bin
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/models"
+)
+
+func newTestQuote(ts time.Time, bidPrice float64) *models.QuoteResponse {
+	nge := ngerest.NGETime(ts)
+
+	rsp := models.QuoteResponse{}
+	rsp.Table = "quote"
+	rsp.Action = models.InsertAction
+	rsp.Data = []*ngerest.Quote{
+		{Timestamp: &nge, Symbol: "XBTUSD", BidPrice: bidPrice, BidSize: 1, AskPrice: bidPrice + 0.5, AskSize: 1},
+	}
+
+	return &rsp
+}
+
+func TestQuoteFromMBL(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	mbl := NewMBLCache(ctx, "XBTUSD")
+	quote := NewQuoteCache(ctx, "XBTUSD")
+	mbl.(*MBLCache).SetQuoteCache(quote)
+
+	partial := models.NewMBLPartial()
+	partial.Data = []*ngerest.OrderBookL2{
+		{Symbol: "XBTUSD", ID: 1, Side: "Sell", Size: 10, Price: 9000.5},
+		{Symbol: "XBTUSD", ID: 2, Side: "Buy", Size: 10, Price: 9000},
+		{Symbol: "XBTUSD", ID: 3, Side: "Buy", Size: 10, Price: 8999.5},
+	}
+	mbl.Append(NewCacheInput(partial))
+
+	update := models.MBLResponse{}
+	update.Table = "orderBookL2"
+	update.Action = models.UpdateAction
+
+	// best bid size changed
+	update.Data = []*ngerest.OrderBookL2{{Symbol: "XBTUSD", ID: 2, Side: "Buy", Size: 5, Price: 9000}}
+	mbl.Append(NewCacheInput(&update))
+
+	// second level changed, best quote not changed
+	deeper := update
+	deeper.Data = []*ngerest.OrderBookL2{{Symbol: "XBTUSD", ID: 3, Side: "Buy", Size: 5, Price: 8999.5}}
+	mbl.Append(NewCacheInput(&deeper))
+
+	// quotes from mbl must be queued after mbl snapshot taken
+	mbl.TakeSnapshot(0, nil, "")
+
+	snap := quote.TakeSnapshot(0, nil, "").(*models.QuoteResponse)
+	if len(snap.Data) != 2 || snap.Table != "quote" {
+		t.Fatal("quote count miss-match:", snap.String())
+	}
+
+	if last := snap.Data[1]; last.BidPrice != 9000 || last.BidSize != 5 || last.AskPrice != 9000.5 ||
+		last.AskSize != 10 || last.Timestamp == nil {
+		t.Fatal("last quote miss-match:", snap.String())
+	}
+}
+
+func TestQuoteBin(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	quote := NewQuoteCache(ctx, "XBTUSD")
+	bin := NewQuoteBinCache(ctx, "XBTUSD", "1m")
+	quote.(*QuoteCache).AddBinCache(bin)
+
+	start := time.Date(2019, 10, 31, 7, 0, 10, 0, time.UTC)
+
+	quote.Append(NewCacheInput(newTestQuote(start, 9000)))
+	quote.Append(NewCacheInput(newTestQuote(start.Add(time.Second*20), 9001)))
+	// close 07:01 bucket & carry last quote to 07:02 bucket
+	quote.Append(NewCacheInput(newTestQuote(start.Add(time.Minute*2), 9002)))
+
+	quote.TakeSnapshot(0, nil, "")
+
+	snap := bin.TakeSnapshot(0, nil, "").(*models.QuoteResponse)
+	if snap.Table != "quoteBin1m" || len(snap.Data) < 2 {
+		t.Fatal("quote bin miss-match:", snap.String())
+	}
+
+	for idx, expect := range []time.Time{
+		time.Date(2019, 10, 31, 7, 1, 0, 0, time.UTC),
+		time.Date(2019, 10, 31, 7, 2, 0, 0, time.UTC),
+	} {
+		if data := snap.Data[idx]; data.BidPrice != 9001 || !time.Time(*data.Timestamp).Equal(expect) {
+			t.Fatalf("quote bin[%d] miss-match: %s", idx, snap.String())
+		}
+	}
+
+	// bins from upstream stored as they are
+	upstream := newTestQuote(time.Now(), 9100)
+	upstream.Table = "quoteBin1m"
+	bin.Append(NewCacheInput(upstream))
+
+	snap = bin.TakeSnapshot(0, nil, "").(*models.QuoteResponse)
+	if last := snap.Data[len(snap.Data)-1]; last.BidPrice != 9100 {
+		t.Fatal("upstream quote bin not stored:", snap.String())
+	}
+}
@@ -3,6 +3,8 @@ package utils
 import (
 	"context"
 	"encoding/json"
+	"math"
+	"time"
 
 	"github.com/frozenpine/ngerest"
 	"github.com/frozenpine/wstester/models"
@@ -13,11 +15,21 @@ const (
 	maxTradeLen int = 200
 )
 
-// TradeCache retrive & store trade data
+// TradeCache retrive & store trade data, trade inserts are also fed to bin caches
 type TradeCache struct {
 	tableCache
 
 	historyTrade []*ngerest.Trade
+	bins         []Cache
+}
+
+// AddBinCache add bin cache fed by trade inserts
+func (c *TradeCache) AddBinCache(bin Cache) {
+	c.enqueue(NewBreakpoint(func() models.TableResponse {
+		c.bins = append(c.bins, bin)
+
+		return nil
+	}))
 }
 
 func (c *TradeCache) snapshot(depth int) models.TableResponse {
@@ -37,6 +49,19 @@ func (c *TradeCache) snapshot(depth int) models.TableResponse {
 	return snap
 }
 
+func (c *TradeCache) status() map[string]interface{} {
+	status := map[string]interface{}{
+		"historyLength": len(c.historyTrade),
+		"bins":          len(c.bins),
+	}
+
+	if hisLen := len(c.historyTrade); hisLen > 0 {
+		status["lastPrice"] = c.historyTrade[hisLen-1].Price
+	}
+
+	return status
+}
+
 func (c *TradeCache) handleInput(input *CacheInput) {
 	if input.IsBreakPoint() {
 		c.handleBreakpoint(input)
@@ -53,6 +78,12 @@ func (c *TradeCache) handleInput(input *CacheInput) {
 		if c.applyData(td) {
 			c.channelGroup[Realtime][0].PublishData(td)
 		}
+
+		if td.Action == models.InsertAction {
+			for _, bin := range c.bins {
+				bin.Append(input)
+			}
+		}
 	} else {
 		log.Error("Can not convert cache input to TradeResponse: ", input.msg.String())
 	}
@@ -97,10 +128,12 @@ func NewTradeCache(ctx context.Context, symbol string) Cache {
 	}
 
 	td := TradeCache{}
+	td.Table = "trade"
 	td.Symbol = symbol
 	td.ctx = ctx
 	td.handleInputFn = td.handleInput
 	td.snapshotFn = td.snapshot
+	td.statusFn = td.status
 	td.pipeline = make(chan *CacheInput, 1000)
 	td.ready = make(chan struct{})
 	td.channelGroup[Realtime] = map[int]Channel{
@@ -117,3 +150,189 @@ func NewTradeCache(ctx context.Context, symbol string) Cache {
 
 	return &td
 }
+
+// TradeBinCache bucketed OHLCV cache aggregated from trade inserts, bin is published on bucket close
+// with bucket end timestamp, bins received from upstream are stored & published as they are.
+type TradeBinCache struct {
+	tableCache
+
+	clock binClock
+	// aggregating bin for open bucket, nil if no trade in bucket
+	current   *ngerest.TradeBin
+	lastClose float64
+	// sum of price * size in open bucket for vwap
+	priceVolume float64
+
+	historyBin []*ngerest.TradeBin
+}
+
+func (c *TradeBinCache) snapshot(depth int) models.TableResponse {
+	snap := models.NewTradeBinPartial("")
+	snap.Table = c.Table
+
+	hisLen := len(c.historyBin)
+
+	snap.Data = c.historyBin[hisLen-trimHistory(hisLen, depth, maxBinLen):]
+
+	return snap
+}
+
+func (c *TradeBinCache) status() map[string]interface{} {
+	status := map[string]interface{}{
+		"historyLength": len(c.historyBin),
+		"binEnd":        c.clock.end,
+	}
+
+	if c.current != nil {
+		status["trades"] = c.current.Trades
+		status["volume"] = c.current.Volume
+	}
+
+	return status
+}
+
+func (c *TradeBinCache) appendBins(bins ...*ngerest.TradeBin) {
+	c.historyBin = append(c.historyBin, bins...)
+
+	if hisLen := len(c.historyBin); hisLen > maxBinLen*maxMultiple {
+		c.historyBin = c.historyBin[hisLen-maxBinLen*maxMultiple/2:]
+	}
+}
+
+// closeBin publish aggregated bin for bucket, buckets without trade use last close price with zero volume
+func (c *TradeBinCache) closeBin(end time.Time) {
+	ts := ngerest.NGETime(end)
+
+	bin := c.current
+	if bin == nil {
+		bin = &ngerest.TradeBin{
+			Symbol: c.Symbol,
+			Open:   c.lastClose,
+			High:   c.lastClose,
+			Low:    c.lastClose,
+			Close:  c.lastClose,
+		}
+	} else if bin.Volume > 0 {
+		bin.Vwap = c.priceVolume / float64(bin.Volume)
+	}
+	bin.Timestamp = &ts
+
+	c.current = nil
+	c.priceVolume = 0
+
+	c.appendBins(bin)
+
+	rsp := models.TradeBinResponse{}
+	rsp.Table = c.Table
+	rsp.Action = models.InsertAction
+	rsp.Data = []*ngerest.TradeBin{bin}
+
+	c.channelGroup[Realtime][0].PublishData(&rsp)
+}
+
+func (c *TradeBinCache) handleTrade(td *ngerest.Trade) {
+	ts := time.Now()
+	if td.Timestamp != nil {
+		ts = time.Time(*td.Timestamp)
+	}
+
+	c.clock.closeBefore(ts, c.closeBin)
+	c.clock.open(ts)
+
+	if c.current == nil {
+		c.current = &ngerest.TradeBin{
+			Symbol: td.Symbol,
+			Open:   td.Price,
+			High:   td.Price,
+			Low:    td.Price,
+		}
+	}
+
+	bin := c.current
+
+	bin.High = math.Max(bin.High, td.Price)
+	bin.Low = math.Min(bin.Low, td.Price)
+	bin.Close = td.Price
+	bin.Trades++
+	bin.Volume += td.Size
+	bin.LastSize = td.Size
+	bin.Turnover += td.GrossValue
+	bin.HomeNotional += td.HomeNotional
+	bin.ForeignNotional += td.ForeignNotional
+
+	c.priceVolume += td.Price * float64(td.Size)
+	c.lastClose = td.Price
+}
+
+func (c *TradeBinCache) handleInput(input *CacheInput) {
+	if c.handleBreakpoint(input) {
+		return
+	}
+
+	if input.msg == nil {
+		log.Error("Trade bin notify content is empty")
+		return
+	}
+
+	switch rsp := input.msg.(type) {
+	case *models.TradeResponse:
+		for _, td := range rsp.Data {
+			c.handleTrade(td)
+		}
+	case *models.TradeBinResponse:
+		switch rsp.Action {
+		case models.PartialAction:
+			if len(c.historyBin) < 1 {
+				// 防止client端使用cache时，partial数据无输出的问题
+				c.channelGroup[Realtime][0].PublishData(rsp)
+			}
+
+			c.historyBin = rsp.Data
+		case models.InsertAction:
+			c.appendBins(rsp.Data...)
+
+			c.channelGroup[Realtime][0].PublishData(rsp)
+		default:
+			log.Error("Invalid action for trade bin cache: ", rsp.Action)
+		}
+	default:
+		log.Error("Can not convert cache input to TradeBinResponse: ", input.msg.String())
+	}
+}
+
+// NewTradeBinCache make a new trade bin cache, binSize must be one of BinSizes.
+func NewTradeBinCache(ctx context.Context, symbol, binSize string) Cache {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	duration, exist := binDurations[binSize]
+	if !exist {
+		log.Panic("invalid bin size: ", binSize)
+	}
+
+	bin := TradeBinCache{clock: binClock{size: duration}}
+	bin.Table = "tradeBin" + binSize
+	bin.Symbol = symbol
+	bin.ctx = ctx
+	bin.handleInputFn = bin.handleInput
+	bin.snapshotFn = bin.snapshot
+	bin.statusFn = bin.status
+	bin.pipeline = make(chan *CacheInput, 1000)
+	bin.ready = make(chan struct{})
+	bin.channelGroup[Realtime] = map[int]Channel{
+		0: &rspChannel{
+			ctx:           ctx,
+			destinations:  map[string]chan<- models.TableResponse{},
+			childChannels: map[string]Channel{},
+		},
+	}
+
+	if err := bin.Start(); err != nil {
+		log.Panic(err)
+	}
+
+	go bin.closeLoop(&bin.clock, bin.closeBin)
+
+	return &bin
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/models"
+)
+
+func newTestTrade(ts time.Time, price float64, size float32) *models.TradeResponse {
+	nge := ngerest.NGETime(ts)
+
+	rsp := models.TradeResponse{}
+	rsp.Table = "trade"
+	rsp.Action = models.InsertAction
+	rsp.Data = []*ngerest.Trade{
+		{Timestamp: &nge, Symbol: "XBTUSD", Side: "Buy", Price: price, Size: size},
+	}
+
+	return &rsp
+}
+
+func TestTradeBin(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	td := NewTradeCache(ctx, "XBTUSD")
+	bin := NewTradeBinCache(ctx, "XBTUSD", "1m")
+	td.(*TradeCache).AddBinCache(bin)
+
+	start := time.Date(2019, 10, 31, 7, 0, 10, 0, time.UTC)
+
+	td.Append(NewCacheInput(newTestTrade(start, 9000, 10)))
+	td.Append(NewCacheInput(newTestTrade(start.Add(time.Second*10), 9002, 10)))
+	td.Append(NewCacheInput(newTestTrade(start.Add(time.Second*20), 8999, 20)))
+	// close 07:01 bucket & 07:02 bucket without trade
+	td.Append(NewCacheInput(newTestTrade(start.Add(time.Minute*2), 9005, 1)))
+
+	td.TakeSnapshot(0, nil, "")
+
+	snap := bin.TakeSnapshot(0, nil, "").(*models.TradeBinResponse)
+	if snap.Table != "tradeBin1m" || len(snap.Data) != 2 {
+		t.Fatal("trade bin miss-match:", snap.String())
+	}
+
+	if data := snap.Data[0]; data.Open != 9000 || data.High != 9002 || data.Low != 8999 ||
+		data.Close != 8999 || data.Trades != 3 || data.Volume != 40 || data.LastSize != 20 ||
+		data.Vwap != 9000 || !time.Time(*data.Timestamp).Equal(time.Date(2019, 10, 31, 7, 1, 0, 0, time.UTC)) {
+		t.Fatal("trade bin[0] miss-match:", snap.String())
+	}
+
+	if data := snap.Data[1]; data.Open != 8999 || data.Close != 8999 || data.Volume != 0 ||
+		!time.Time(*data.Timestamp).Equal(time.Date(2019, 10, 31, 7, 2, 0, 0, time.UTC)) {
+		t.Fatal("trade bin[1] miss-match:", snap.String())
+	}
+
+	if depth := bin.TakeSnapshot(1, nil, "").(*models.TradeBinResponse); len(depth.Data) != 1 {
+		t.Fatal("trade bin depth miss-match:", depth.String())
+	}
+}
+
+func TestCacheLoopExited(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	td := NewTradeCache(ctx, "XBTUSD")
+
+	// hold cache loop, so breakpoints queued before loop exited by ctx
+	release := make(chan struct{})
+	td.(*TradeCache).enqueue(NewBreakpoint(func() models.TableResponse {
+		<-release
+		return nil
+	}))
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		td.TakeSnapshot(0, nil, "")
+		td.Status()
+		// stopped by ctx or stop itself
+		td.Stop()
+	}()
+
+	cancelFn()
+	close(release)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 3):
+		t.Fatal("snapshot or status blocked after cache loop exited")
+	}
+
+	if err := td.Stop(); err == nil {
+		t.Fatal("cache stopped twice")
+	}
+}
@@ -4,15 +4,25 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"time"
+	"sync"
 
 	"github.com/frozenpine/wstester/models"
 	"github.com/frozenpine/wstester/utils/log"
+	"github.com/frozenpine/wstester/utils/metrics"
 	uuid "github.com/satori/go.uuid"
 )
 
 const (
-	dispatchTimeout = 3
+	destinationSize = 1000
+	// dispatchLogInterval seconds between logs for dispatch blocked
+	dispatchLogInterval = 10
+)
+
+var (
+	dispatchBlocks = metrics.DefaultRegistry.Counter(
+		"wstester_dispatch_blocked_total", "Data dispatch blocked for destination channel full.", nil)
+
+	dispatchLogBucket = NewTokenBucket(1.0/dispatchLogInterval, 1)
 )
 
 // Input cache & channel input
@@ -83,84 +93,143 @@ type Channel interface {
 	// RetriveData to get an chan to retrive data in current channel
 	RetriveData() (string, <-chan models.TableResponse)
 
+	// RetriveSharedData to get an chan to retrive data wrapped in *SharedResponse,
+	// data dispatched to all shared destinations is encoded only once
+	RetriveSharedData() (string, <-chan models.TableResponse)
+
 	// ShutdownRetrive shutdown data chan specified by session
 	ShutdownRetrive(session string) error
 }
 
+// destState dispatch state of destination
+type destState struct {
+	// shared destination get data wrapped in *SharedResponse
+	shared bool
+	// done closed when destination shutdown, so dispatch blocked on it is released
+	done chan struct{}
+}
+
 type rspChannel struct {
 	source chan *ChannelInput
 
 	destinations  map[string]chan<- models.TableResponse
+	destStates    map[string]*destState
 	childChannels map[string]Channel
 
-	ctx      context.Context
-	IsReady  bool
-	IsClosed bool
+	// retrived done chan of destinations, closed in ShutdownRetrive out of dispatch loop
+	retrived    map[string]chan struct{}
+	retriveLock sync.Mutex
+
+	ctx       context.Context
+	IsReady   bool
+	IsClosed  bool
+	closeLock sync.RWMutex
+	loopDone  chan struct{}
 }
 
-func (c *rspChannel) PublishData(data models.TableResponse) error {
+// send put input in source, return false if channel closed
+func (c *rspChannel) send(in *ChannelInput) bool {
+	c.closeLock.RLock()
+	defer c.closeLock.RUnlock()
+
 	if c.IsClosed {
-		return fmt.Errorf("channel is already closed")
+		return false
 	}
 
-	c.source <- &ChannelInput{
-		rsp: data,
+	select {
+	case c.source <- in:
+		return true
+	case <-c.ctx.Done():
+		return false
 	}
+}
 
-	return nil
+// wait wait for breakpoint result until dispatch loop exited
+func (c *rspChannel) wait(ch <-chan error) error {
+	select {
+	case err := <-ch:
+		return err
+	case <-c.loopDone:
+		return nil
+	}
 }
 
-func (c *rspChannel) PublishDataToDestination(data models.TableResponse, session string) error {
-	if c.IsClosed {
+func (c *rspChannel) PublishData(data models.TableResponse) error {
+	if !c.send(&ChannelInput{rsp: data}) {
 		return fmt.Errorf("channel is already closed")
 	}
 
-	c.source <- &ChannelInput{
-		dstSession: session,
-		rsp:        data,
+	return nil
+}
+
+func (c *rspChannel) PublishDataToDestination(data models.TableResponse, session string) error {
+	if !c.send(&ChannelInput{dstSession: session, rsp: data}) {
+		return fmt.Errorf("channel is already closed")
 	}
 
 	return nil
 }
 
 func (c *rspChannel) PublishDataToSubChan(data models.TableResponse, session string) error {
-	if c.IsClosed {
+	if !c.send(&ChannelInput{subChanSession: session, rsp: data}) {
 		return fmt.Errorf("channel is already closed")
 	}
 
-	c.source <- &ChannelInput{
-		subChanSession: session,
-		rsp:            data,
-	}
-
 	return nil
 }
 
 func (c *rspChannel) RetriveData() (string, <-chan models.TableResponse) {
-	if c.IsClosed {
-		return "", nil
-	}
+	return c.retrive(false)
+}
+
+func (c *rspChannel) RetriveSharedData() (string, <-chan models.TableResponse) {
+	return c.retrive(true)
+}
 
-	ch := make(chan models.TableResponse, 1000)
+func (c *rspChannel) retrive(shared bool) (string, <-chan models.TableResponse) {
+	ch := make(chan models.TableResponse, destinationSize)
 	session := uuid.NewV4().String()
+	state := destState{shared: shared, done: make(chan struct{})}
+
+	c.retriveLock.Lock()
+	if c.retrived == nil {
+		c.retrived = make(map[string]chan struct{})
+	}
+	c.retrived[session] = state.done
+	c.retriveLock.Unlock()
+
+	if !c.send(NewChannelBreakpoint(func() {
+		if c.destStates == nil {
+			c.destStates = make(map[string]*destState)
+		}
 
-	c.source <- NewChannelBreakpoint(func() {
 		c.destinations[session] = ch
-	})
+		c.destStates[session] = &state
+	})) {
+		c.retriveLock.Lock()
+		delete(c.retrived, session)
+		c.retriveLock.Unlock()
+
+		return "", nil
+	}
 
 	return session, ch
 }
 
 func (c *rspChannel) ShutdownRetrive(session string) error {
-	if c.IsClosed {
-		return nil
+	c.retriveLock.Lock()
+	if done, exist := c.retrived[session]; exist {
+		close(done)
+		delete(c.retrived, session)
 	}
+	c.retriveLock.Unlock()
 
 	ch := make(chan error, 1)
 
-	c.source <- NewChannelBreakpoint(func() {
+	if !c.send(NewChannelBreakpoint(func() {
 		if dst, exist := c.destinations[session]; exist {
 			delete(c.destinations, session)
+			delete(c.destStates, session)
 			close(dst)
 			ch <- nil
 		} else {
@@ -168,37 +237,41 @@ func (c *rspChannel) ShutdownRetrive(session string) error {
 		}
 
 		close(ch)
-	})
+	})) {
+		return nil
+	}
 
-	return <-ch
+	return c.wait(ch)
 }
 
 func (c *rspChannel) Connect(child Channel) (string, error) {
-	if c.IsClosed {
+	c.closeLock.RLock()
+	ready, closed := c.IsReady, c.IsClosed
+	c.closeLock.RUnlock()
+
+	if closed {
 		return "", fmt.Errorf("channel is already closed")
 	}
 
-	if !c.IsReady {
+	if !ready {
 		c.Start()
 	}
 
 	session := uuid.NewV4().String()
 
-	c.source <- NewChannelBreakpoint(func() {
+	if !c.send(NewChannelBreakpoint(func() {
 		c.childChannels[session] = child
-	})
+	})) {
+		return "", fmt.Errorf("channel is already closed")
+	}
 
 	return session, nil
 }
 
 func (c *rspChannel) Disconnect(session string) error {
-	if c.IsClosed {
-		return nil
-	}
-
 	ch := make(chan error, 1)
 
-	c.source <- NewChannelBreakpoint(func() {
+	if !c.send(NewChannelBreakpoint(func() {
 		if _, exist := c.childChannels[session]; exist {
 			delete(c.childChannels, session)
 			ch <- nil
@@ -206,12 +279,17 @@ func (c *rspChannel) Disconnect(session string) error {
 			ch <- fmt.Errorf("invalid sub channel session[%s]", session)
 		}
 		close(ch)
-	})
+	})) {
+		return nil
+	}
 
-	return <-ch
+	return c.wait(ch)
 }
 
 func (c *rspChannel) Start() error {
+	c.closeLock.Lock()
+	defer c.closeLock.Unlock()
+
 	if c.IsReady {
 		return errors.New("channel is already started")
 	}
@@ -224,10 +302,19 @@ func (c *rspChannel) Start() error {
 		c.source = make(chan *ChannelInput, 1000)
 	}
 
-	c.IsClosed = false
+	c.loopDone = make(chan struct{})
 
 	go func() {
-		defer c.Close()
+		defer func() {
+			c.Close()
+
+			// destinations closed in dispatch loop, so no data sent to closed destination
+			for _, ch := range c.destinations {
+				close(ch)
+			}
+
+			close(c.loopDone)
+		}()
 
 		for {
 			select {
@@ -254,11 +341,16 @@ func (c *rspChannel) Start() error {
 	}()
 
 	c.IsReady = true
+	c.IsClosed = false
 
 	return nil
 }
 
+// Close close channel input, destinations are closed after dispatch loop exited
 func (c *rspChannel) Close() error {
+	c.closeLock.Lock()
+	defer c.closeLock.Unlock()
+
 	if !c.IsReady {
 		return fmt.Errorf("channel is not started")
 	}
@@ -273,51 +365,84 @@ func (c *rspChannel) Close() error {
 
 	c.IsClosed = true
 
-	for _, ch := range c.destinations {
-		close(ch)
-	}
-
 	return nil
 }
 
+// dispatchDistinations dispatch data to destinations, data is never dropped here,
+// slow consumer policy is applied by receiver of destination, dispatch blocks if destination full
+// until data retrived or destination shutdown.
+// All shared destinations get the same *SharedResponse, so data is encoded once for them.
 func (c *rspChannel) dispatchDistinations(data *ChannelInput) {
-	var invalidDest []string
+	var (
+		invalidDest []string
+		shared      *SharedResponse
+	)
+
+	getData := func(state *destState) models.TableResponse {
+		if state == nil || !state.shared {
+			return data.rsp
+		}
+
+		if shared == nil {
+			shared = NewSharedResponse(data.rsp)
+		}
+
+		return shared
+	}
 
-	handleInput := func(session string, dest chan<- models.TableResponse, writeTimeout *time.Timer) {
+	handleInput := func(session string, dest chan<- models.TableResponse) {
 		if dest == nil {
 			invalidDest = append(invalidDest, session)
 			log.Error("Destination channel is nil")
 			return
 		}
 
+		var (
+			state = c.destStates[session]
+			rsp   = getData(state)
+			done  <-chan struct{}
+		)
+
 		select {
-		case dest <- data.rsp:
-			writeTimeout.Reset(time.Second * dispatchTimeout)
-		case <-writeTimeout.C:
-			close(dest)
-			invalidDest = append(invalidDest, session)
-			writeTimeout = time.NewTimer(time.Second * dispatchTimeout)
-			log.Warn("Dispatch data to client timeout.")
+		case dest <- rsp:
+			return
+		default:
+		}
+
+		dispatchBlocks.Inc()
+
+		if ok, _ := dispatchLogBucket.Take(); ok {
+			log.Warnf("Destination[%s] channel full, dispatch blocked, %d blocked in total.",
+				session, dispatchBlocks.Value())
+		}
+
+		if state != nil {
+			done = state.done
+		}
+
+		select {
+		case dest <- rsp:
+		case <-done:
+		case <-c.ctx.Done():
 		}
 	}
 
-	writeTimeout := time.NewTimer(time.Second * dispatchTimeout)
 	if data.dstSession == "" {
 		for session, dest := range c.destinations {
-			handleInput(session, dest, writeTimeout)
+			handleInput(session, dest)
 		}
 	} else {
 		if dst, exist := c.destinations[data.dstSession]; exist {
-			handleInput(data.dstSession, dst, writeTimeout)
+			handleInput(data.dstSession, dst)
 		} else {
 			log.Errorf("Invalid destination session[%s] specified", data.dstSession)
 		}
 	}
-	writeTimeout.Stop()
 
 	if len(invalidDest) > 0 {
 		for _, invalid := range invalidDest {
 			delete(c.destinations, invalid)
+			delete(c.destStates, invalid)
 		}
 	}
 }
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/frozenpine/wstester/models"
+)
+
+func receiveData(t *testing.T, ch <-chan models.TableResponse, count int) {
+	timeout := time.After(time.Second * 3)
+
+	for idx := 0; idx < count; idx++ {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				t.Fatalf("destination closed, received %d", idx)
+			}
+		case <-timeout:
+			t.Fatalf("destination timeout, received %d", idx)
+		}
+	}
+}
+
+func TestDispatchBlocking(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	ch := &rspChannel{
+		ctx:           ctx,
+		destinations:  map[string]chan<- models.TableResponse{},
+		childChannels: map[string]Channel{},
+	}
+	if err := ch.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// slow destination never retrived before shutdown
+	slowSession, slow := ch.RetriveData()
+	_, fast := ch.RetriveData()
+
+	total := destinationSize * 2
+	blocked := dispatchBlocks.Value()
+
+	go func() {
+		for idx := 0; idx < total; idx++ {
+			ch.PublishData(models.NewTradePartial())
+		}
+	}()
+
+	// dispatch blocked after slow destination full
+	receiveData(t, fast, destinationSize)
+
+	for deadline := time.Now().Add(time.Second * 3); dispatchBlocks.Value() == blocked; {
+		if time.Now().After(deadline) {
+			t.Fatal("dispatch not blocked by full destination")
+		}
+
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	// blocked dispatch released by shutdown
+	if err := ch.ShutdownRetrive(slowSession); err != nil {
+		t.Fatal(err)
+	}
+
+	receiveData(t, fast, total-destinationSize)
+
+	count := 0
+	for range slow {
+		count++
+	}
+
+	if count != destinationSize {
+		t.Fatalf("data in slow destination before shutdown dropped, received %d", count)
+	}
+}
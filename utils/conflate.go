@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/models"
+)
+
+// levelKey price level identity, responses of all symbols can be merged together
+type levelKey struct {
+	symbol string
+	price  float64
+}
+
+// levelChange merged changes on one price level
+type levelChange struct {
+	// level existed before merged changes
+	existed bool
+	// first delete on existed level
+	deleted *ngerest.OrderBookL2
+	// level after merged changes, nil if deleted
+	level *ngerest.OrderBookL2
+}
+
+// ConflateMBL merge insert, update & delete in mbl responses on the same price level into
+// the smallest equivalent set, result is in delete, insert, update order with empty responses omitted.
+// Partial in responses is not supported and returned responses are newly allocated.
+func ConflateMBL(rspList ...*models.MBLResponse) []*models.MBLResponse {
+	var (
+		table  string
+		levels []levelKey
+	)
+
+	changes := make(map[levelKey]*levelChange)
+
+	for _, rsp := range rspList {
+		if rsp == nil {
+			continue
+		}
+
+		table = rsp.Table
+
+		for _, ord := range rsp.Data {
+			key := levelKey{symbol: ord.Symbol, price: ord.Price}
+
+			change, exist := changes[key]
+			if !exist {
+				change = &levelChange{existed: rsp.Action != models.InsertAction}
+				changes[key] = change
+				levels = append(levels, key)
+			}
+
+			switch rsp.Action {
+			case models.DeleteAction:
+				if change.existed && change.deleted == nil {
+					change.deleted = ord
+				}
+
+				change.level = nil
+			default:
+				change.level = ord
+			}
+		}
+	}
+
+	deleteRsp := models.MBLResponse{}
+	deleteRsp.Table = table
+	deleteRsp.Action = models.DeleteAction
+
+	insertRsp := models.MBLResponse{}
+	insertRsp.Table = table
+	insertRsp.Action = models.InsertAction
+
+	updateRsp := models.MBLResponse{}
+	updateRsp.Table = table
+	updateRsp.Action = models.UpdateAction
+
+	for _, key := range levels {
+		change := changes[key]
+
+		switch {
+		case !change.existed:
+			if change.level != nil {
+				insertRsp.Data = append(insertRsp.Data, change.level)
+			}
+		case change.level == nil:
+			deleteRsp.Data = append(deleteRsp.Data, change.deleted)
+		case change.deleted != nil && change.deleted.Side != change.level.Side:
+			// level crossed to the other side
+			deleteRsp.Data = append(deleteRsp.Data, change.deleted)
+			insertRsp.Data = append(insertRsp.Data, change.level)
+		default:
+			updateRsp.Data = append(updateRsp.Data, change.level)
+		}
+	}
+
+	var result []*models.MBLResponse
+
+	for _, rsp := range []*models.MBLResponse{&deleteRsp, &insertRsp, &updateRsp} {
+		if len(rsp.Data) > 0 {
+			result = append(result, rsp)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/models"
+)
+
+func newTestMBL(action string, levels ...*ngerest.OrderBookL2) *models.MBLResponse {
+	rsp := models.MBLResponse{}
+	rsp.Table = "orderBookL2"
+	rsp.Action = action
+	rsp.Data = levels
+
+	return &rsp
+}
+
+func newTestLevel(side string, price float64, size float32) *ngerest.OrderBookL2 {
+	return &ngerest.OrderBookL2{Symbol: "XBTUSD", ID: int(price * 10), Side: side, Price: price, Size: size}
+}
+
+func TestConflateMBL(t *testing.T) {
+	merged := ConflateMBL(
+		// inserted then updated: insert with latest size
+		newTestMBL(models.InsertAction, newTestLevel("Buy", 9000, 1), newTestLevel("Buy", 8999, 1)),
+		newTestMBL(models.UpdateAction, newTestLevel("Buy", 9000, 2), newTestLevel("Sell", 9001, 3)),
+		// inserted then deleted: omitted
+		newTestMBL(models.DeleteAction, newTestLevel("Buy", 8999, 1)),
+		// deleted then inserted on the same side: update
+		newTestMBL(models.DeleteAction, newTestLevel("Sell", 9002, 1), newTestLevel("Buy", 8998, 1)),
+		newTestMBL(models.InsertAction, newTestLevel("Sell", 9002, 5)),
+		// deleted then inserted on the other side: delete & insert
+		newTestMBL(models.InsertAction, newTestLevel("Sell", 8998, 4)),
+		newTestMBL(models.UpdateAction, newTestLevel("Buy", 9000, 6)),
+	)
+
+	expect := []struct {
+		action string
+		levels []*ngerest.OrderBookL2
+	}{
+		{models.DeleteAction, []*ngerest.OrderBookL2{newTestLevel("Buy", 8998, 1)}},
+		{models.InsertAction, []*ngerest.OrderBookL2{newTestLevel("Buy", 9000, 6), newTestLevel("Sell", 8998, 4)}},
+		{models.UpdateAction, []*ngerest.OrderBookL2{newTestLevel("Sell", 9001, 3), newTestLevel("Sell", 9002, 5)}},
+	}
+
+	if len(merged) != len(expect) {
+		t.Fatal("merged response count miss-match:", merged)
+	}
+
+	for idx, rsp := range merged {
+		if rsp.Action != expect[idx].action || len(rsp.Data) != len(expect[idx].levels) {
+			t.Fatalf("merged response[%d] miss-match: %s", idx, rsp.String())
+		}
+
+		for lvl, level := range rsp.Data {
+			if *level != *expect[idx].levels[lvl] {
+				t.Fatalf("merged response[%d] miss-match: %s", idx, rsp.String())
+			}
+		}
+	}
+
+	if merged := ConflateMBL(
+		newTestMBL(models.InsertAction, newTestLevel("Buy", 9000, 1)),
+		newTestMBL(models.DeleteAction, newTestLevel("Buy", 9000, 1)),
+	); len(merged) != 0 {
+		t.Fatal("canceled changes not omitted:", merged)
+	}
+}
+
+func TestConflateInterval(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	mbl := NewMBLCache(ctx, "XBTUSD")
+	mbl.(*MBLCache).SetConflateInterval(time.Hour)
+
+	mbl.Append(NewCacheInput(newTestMBL(models.PartialAction, newTestLevel("Buy", 9000, 1))))
+	mbl.TakeSnapshot(0, nil, "")
+
+	_, ch := mbl.GetDefaultChannel().RetriveData()
+
+	for _, size := range []float32{2, 3, 4} {
+		mbl.Append(NewCacheInput(newTestMBL(models.UpdateAction, newTestLevel("Buy", 9000, size))))
+	}
+	mbl.Append(NewCacheInput(newTestMBL(models.InsertAction, newTestLevel("Sell", 9001, 1))))
+
+	select {
+	case rsp := <-ch:
+		t.Fatal("changes published in conflate interval:", rsp.String())
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	// pending changes flushed before snapshot
+	snap := mbl.TakeSnapshot(0, nil, "").(*models.MBLResponse)
+	if len(snap.Data) != 2 {
+		t.Fatal("snapshot miss-match:", snap.String())
+	}
+
+	for _, expect := range []string{models.InsertAction, models.UpdateAction} {
+		select {
+		case rsp := <-ch:
+			if data := rsp.(*models.MBLResponse); data.Action != expect || len(data.Data) != 1 ||
+				(expect == models.UpdateAction && data.Data[0].Size != 4) {
+				t.Fatal("merged changes miss-match:", rsp.String())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("merged changes not published")
+		}
+	}
+
+	// publish immediately after conflate disabled
+	mbl.(*MBLCache).SetConflateInterval(0)
+	mbl.Append(NewCacheInput(newTestMBL(models.UpdateAction, newTestLevel("Sell", 9001, 2))))
+
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("change not published after conflate disabled")
+	}
+}
@@ -254,14 +254,33 @@ func (tbl *TableDef) GetAliasName() string {
 	return tbl.GetName()
 }
 
+// HasCondition determine wether table has WHERE condition defined by SQL
+func (tbl *TableDef) HasCondition() bool {
+	return tbl.where != nil
+}
+
+// Match check if data matches WHERE condition, always true if no condition defined
+func (tbl *TableDef) Match(data interface{}) bool {
+	return tbl.where == nil || tbl.where(data)
+}
+
 // GetFilter get a filter function for data slice
 func (tbl *TableDef) GetFilter() LinqFilter {
+	return tbl.query(true)
+}
+
+// GetProjection get a function selecting columns of data slice without WHERE condition
+func (tbl *TableDef) GetProjection() LinqFilter {
+	return tbl.query(false)
+}
+
+func (tbl *TableDef) query(filtered bool) LinqFilter {
 	return func(datas interface{}) []map[string]interface{} {
 		var results []map[string]interface{}
 
 		query := linq.From(datas)
 
-		if tbl.where != nil {
+		if filtered && tbl.where != nil {
 			query = query.Where(func(v interface{}) bool {
 				return tbl.where(v)
 			})
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// meterWindow window seconds for meter rate
+	meterWindow = 60
+)
+
+// Counter monotonically increasing counter
+type Counter struct {
+	value int64
+}
+
+// Inc increase counter by 1
+func (c *Counter) Inc() {
+	atomic.AddInt64(&c.value, 1)
+}
+
+// Add increase counter by n
+func (c *Counter) Add(n int64) {
+	atomic.AddInt64(&c.value, n)
+}
+
+// Value get counter value
+func (c *Counter) Value() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+// Gauge value can go up and down
+type Gauge struct {
+	bits uint64
+}
+
+// Set set gauge value
+func (g *Gauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+// Add add delta to gauge value
+func (g *Gauge) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&g.bits)
+		updated := math.Float64bits(math.Float64frombits(old) + delta)
+
+		if atomic.CompareAndSwapUint64(&g.bits, old, updated) {
+			return
+		}
+	}
+}
+
+// Value get gauge value
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+// Meter counter with average rate per second in recent window
+type Meter struct {
+	Counter
+
+	lock    sync.Mutex
+	buckets [meterWindow]int64
+	seconds [meterWindow]int64
+	start   time.Time
+}
+
+// Mark mark n events
+func (m *Meter) Mark(n int64) {
+	m.Add(n)
+
+	now := time.Now().Unix()
+	idx := now % meterWindow
+
+	m.lock.Lock()
+	if m.seconds[idx] != now {
+		m.seconds[idx] = now
+		m.buckets[idx] = 0
+	}
+	m.buckets[idx] += n
+	m.lock.Unlock()
+}
+
+// Rate average events per second in recent window
+func (m *Meter) Rate() float64 {
+	now := time.Now()
+	window := int64(meterWindow)
+
+	// meter started in window
+	if elapsed := int64(now.Sub(m.start).Seconds()) + 1; elapsed < window {
+		window = elapsed
+	}
+
+	var total int64
+
+	m.lock.Lock()
+	for idx, sec := range m.seconds {
+		if now.Unix()-sec < window {
+			total += m.buckets[idx]
+		}
+	}
+	m.lock.Unlock()
+
+	return float64(total) / float64(window)
+}
+
+// NewMeter create a new meter
+func NewMeter() *Meter {
+	meter := Meter{
+		start: time.Now(),
+	}
+
+	return &meter
+}
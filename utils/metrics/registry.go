@@ -0,0 +1,253 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Labels metric labels
+type Labels map[string]string
+
+// String format labels in prometheus text format
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, strconv.Quote(l[name])))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+type metricType string
+
+const (
+	counterType metricType = "counter"
+	gaugeType   metricType = "gauge"
+)
+
+type family struct {
+	name   string
+	help   string
+	mType  metricType
+	series map[string]func() float64
+	values map[string]interface{}
+}
+
+// Registry metrics registry
+type Registry struct {
+	lock     sync.RWMutex
+	families map[string]*family
+}
+
+func (r *Registry) getFamily(name, help string, mType metricType) *family {
+	f, exist := r.families[name]
+
+	if !exist {
+		f = &family{
+			name:   name,
+			help:   help,
+			mType:  mType,
+			series: make(map[string]func() float64),
+			values: make(map[string]interface{}),
+		}
+
+		r.families[name] = f
+	}
+
+	if f.mType != mType {
+		panic(fmt.Sprintf("metric %s already registered as %s", name, f.mType))
+	}
+
+	return f
+}
+
+// Counter get or create counter with name & labels
+func (r *Registry) Counter(name, help string, labels Labels) *Counter {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	f := r.getFamily(name, help, counterType)
+	key := labels.String()
+
+	if c, exist := f.values[key]; exist {
+		return c.(*Counter)
+	}
+
+	c := Counter{}
+	f.values[key] = &c
+	f.series[key] = func() float64 { return float64(c.Value()) }
+
+	return &c
+}
+
+// Gauge get or create gauge with name & labels
+func (r *Registry) Gauge(name, help string, labels Labels) *Gauge {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	f := r.getFamily(name, help, gaugeType)
+	key := labels.String()
+
+	if g, exist := f.values[key]; exist {
+		return g.(*Gauge)
+	}
+
+	g := Gauge{}
+	f.values[key] = &g
+	f.series[key] = g.Value
+
+	return &g
+}
+
+// GaugeFunc register gauge with value func, called when metrics collected
+func (r *Registry) GaugeFunc(name, help string, labels Labels, fn func() float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	f := r.getFamily(name, help, gaugeType)
+	key := labels.String()
+
+	f.values[key] = fn
+	f.series[key] = fn
+}
+
+// CounterFunc register counter with value func, called when metrics collected,
+// value func must return monotonically increasing value.
+func (r *Registry) CounterFunc(name, help string, labels Labels, fn func() float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	f := r.getFamily(name, help, counterType)
+	key := labels.String()
+
+	f.values[key] = fn
+	f.series[key] = fn
+}
+
+// Meter get or create meter with name & labels,
+// meter will be exported as {name}_total counter & {name}_rate gauge.
+func (r *Registry) Meter(name, help string, labels Labels) *Meter {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	counter := r.getFamily(name+"_total", help, counterType)
+	rate := r.getFamily(name+"_rate", help+" per second in recent minute", gaugeType)
+	key := labels.String()
+
+	if m, exist := counter.values[key]; exist {
+		return m.(*Meter)
+	}
+
+	m := NewMeter()
+	counter.values[key] = m
+	counter.series[key] = func() float64 { return float64(m.Value()) }
+	rate.values[key] = m
+	rate.series[key] = m.Rate
+
+	return m
+}
+
+// Unregister remove metric series with labels,
+// meter's counter & rate series will be removed together.
+func (r *Registry) Unregister(name string, labels Labels) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := labels.String()
+
+	for _, fName := range []string{name, name + "_total", name + "_rate"} {
+		if f, exist := r.families[fName]; exist {
+			delete(f.series, key)
+			delete(f.values, key)
+		}
+	}
+}
+
+// snapshot copy families' series sorted by name & labels
+func (r *Registry) snapshot() []*family {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	families := make([]*family, 0, len(r.families))
+
+	for _, f := range r.families {
+		if len(f.series) == 0 {
+			continue
+		}
+
+		copied := family{
+			name:   f.name,
+			help:   f.help,
+			mType:  f.mType,
+			series: make(map[string]func() float64, len(f.series)),
+		}
+
+		for key, fn := range f.series {
+			copied.series[key] = fn
+		}
+
+		families = append(families, &copied)
+	}
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+
+	return families
+}
+
+// WriteTo write all metrics in prometheus text format,
+// value funcs are called out of registry lock, so they can take other locks.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	buf := bufio.NewWriter(w)
+	var written int64
+
+	write := func(format string, args ...interface{}) {
+		n, _ := fmt.Fprintf(buf, format, args...)
+		written += int64(n)
+	}
+
+	for _, f := range r.snapshot() {
+		write("# HELP %s %s\n", f.name, f.help)
+		write("# TYPE %s %s\n", f.name, f.mType)
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			write("%s%s %s\n", f.name, key, strconv.FormatFloat(f.series[key](), 'g', -1, 64))
+		}
+	}
+
+	return written, buf.Flush()
+}
+
+// NewRegistry create a new metrics registry
+func NewRegistry() *Registry {
+	registry := Registry{
+		families: make(map[string]*family),
+	}
+
+	return &registry
+}
+
+// DefaultRegistry default registry for process wide metrics
+var DefaultRegistry = NewRegistry()
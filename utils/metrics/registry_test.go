@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+
+	counter := registry.Counter("test_count_total", "Test counter.", Labels{"table": "trade", "symbol": "XBTUSD"})
+	counter.Inc()
+	counter.Add(2)
+
+	if registry.Counter("test_count_total", "Test counter.", Labels{"symbol": "XBTUSD", "table": "trade"}) != counter {
+		t.Fatal("counter with same labels not reused")
+	}
+
+	registry.Gauge("test_gauge", "Test gauge.", nil).Set(1.5)
+	registry.GaugeFunc("test_func", "Test gauge func.", Labels{"session": "1"}, func() float64 { return 10 })
+	registry.CounterFunc("test_callback_total", "Test counter func.", nil, func() float64 { return 5 })
+
+	meter := registry.Meter("test_meter", "Test meter.", Labels{"table": "trade"})
+	meter.Mark(120)
+
+	buf := bytes.NewBuffer(nil)
+	if _, err := registry.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	output := buf.String()
+
+	for _, expect := range []string{
+		"# TYPE test_count_total counter\n",
+		`test_count_total{symbol="XBTUSD",table="trade"} 3` + "\n",
+		"test_gauge 1.5\n",
+		`test_func{session="1"} 10` + "\n",
+		"# TYPE test_callback_total counter\ntest_callback_total 5\n",
+		`test_meter_total{table="trade"} 120` + "\n",
+		`test_meter_rate{table="trade"} `,
+	} {
+		if !strings.Contains(output, expect) {
+			t.Fatalf("expect output contains %s, got:\n%s", expect, output)
+		}
+	}
+
+	registry.Unregister("test_func", Labels{"session": "1"})
+	registry.Unregister("test_meter", Labels{"table": "trade"})
+
+	buf.Reset()
+	registry.WriteTo(buf)
+
+	if strings.Contains(buf.String(), "test_func") || strings.Contains(buf.String(), "test_meter") {
+		t.Fatal("unregistered metrics still exported:", buf.String())
+	}
+}
+
+func TestWriteToOutOfLock(t *testing.T) {
+	registry := NewRegistry()
+
+	// value func registering metric needs registry's write lock
+	registry.GaugeFunc("test_func", "Test gauge func.", nil, func() float64 {
+		registry.Gauge("test_gauge", "Test gauge.", nil).Set(1)
+		return 1
+	})
+
+	done := make(chan struct{})
+
+	go func() {
+		registry.WriteTo(bytes.NewBuffer(nil))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 3):
+		t.Fatal("value func called with registry locked")
+	}
+}
@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket token bucket rate limiter
+type TokenBucket struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func (b *TokenBucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+
+	b.last = now
+}
+
+// Take take one token from bucket, if no token available,
+// duration to wait for next token will be returned.
+func (b *TokenBucket) Take() (bool, time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refill(time.Now())
+
+	if b.tokens >= 1 {
+		b.tokens--
+
+		return true, 0
+	}
+
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// NewTokenBucket create token bucket with rate tokens per second & burst size,
+// bucket is full when created.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	bucket := TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+
+	return &bucket
+}
+
+// IsFull check if bucket is refilled to burst size,
+// a full bucket is the same as a newly created one.
+func (b *TokenBucket) IsFull() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refill(time.Now())
+
+	return b.tokens >= b.burst
+}
+
+// TakeAll take one token from each of buckets only if all buckets have token available,
+// nil buckets are skipped. If any bucket is empty, no token is taken and
+// the longest duration to wait will be returned.
+// Buckets are locked in argument order, so callers must pass shared buckets in same order.
+func TakeAll(buckets ...*TokenBucket) (bool, time.Duration) {
+	var (
+		now  = time.Now()
+		ok   = true
+		wait time.Duration
+	)
+
+	for _, b := range buckets {
+		if b == nil {
+			continue
+		}
+
+		b.lock.Lock()
+		defer b.lock.Unlock()
+
+		b.refill(now)
+
+		if b.tokens < 1 {
+			ok = false
+
+			if w := time.Duration((1 - b.tokens) / b.rate * float64(time.Second)); w > wait {
+				wait = w
+			}
+		}
+	}
+
+	if !ok {
+		return false, wait
+	}
+
+	for _, b := range buckets {
+		if b != nil {
+			b.tokens--
+		}
+	}
+
+	return true, 0
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	bucket := NewTokenBucket(10, 3)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := bucket.Take(); !ok {
+			t.Fatal("burst token not available:", i)
+		}
+	}
+
+	ok, wait := bucket.Take()
+	if ok {
+		t.Fatal("token taken over burst size")
+	}
+
+	if wait <= 0 || wait > time.Millisecond*100 {
+		t.Fatal("wait duration miss-match:", wait)
+	}
+
+	time.Sleep(wait)
+
+	if ok, _ := bucket.Take(); !ok {
+		t.Fatal("token not refilled after wait")
+	}
+}
+
+func TestTakeAll(t *testing.T) {
+	session, key := NewTokenBucket(10, 2), NewTokenBucket(10, 1)
+
+	if ok, _ := TakeAll(session, key, nil); !ok {
+		t.Fatal("take tokens failed")
+	}
+
+	ok, wait := TakeAll(session, key)
+	if ok || wait <= 0 {
+		t.Fatal("empty bucket not checked:", wait)
+	}
+
+	// no token taken from session bucket when key bucket rejected
+	if ok, _ := session.Take(); !ok {
+		t.Fatal("token taken from session bucket")
+	}
+
+	if session.IsFull() || key.IsFull() {
+		t.Fatal("bucket full state miss-match")
+	}
+}
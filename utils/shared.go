@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/frozenpine/wstester/models"
+	"github.com/gorilla/websocket"
+)
+
+// SharedResponse table response shared by all destinations in one dispatch,
+// response is encoded at most once no matter how many destinations send it.
+type SharedResponse struct {
+	models.TableResponse
+
+	encodeOnce sync.Once
+	data       []byte
+	err        error
+
+	prepareOnce sync.Once
+	prepared    *websocket.PreparedMessage
+	prepareErr  error
+}
+
+// Bytes get encoded json of response
+func (r *SharedResponse) Bytes() ([]byte, error) {
+	r.encodeOnce.Do(func() {
+		r.data, r.err = json.Marshal(r.TableResponse)
+	})
+
+	return r.data, r.err
+}
+
+// MarshalJSON encode response with shared bytes
+func (r *SharedResponse) MarshalJSON() ([]byte, error) {
+	return r.Bytes()
+}
+
+// PreparedMessage get prepared text message of encoded response,
+// compressed frames are also shared by connections with the same compression settings.
+func (r *SharedResponse) PreparedMessage() (*websocket.PreparedMessage, error) {
+	r.prepareOnce.Do(func() {
+		var data []byte
+
+		if data, r.prepareErr = r.Bytes(); r.prepareErr != nil {
+			return
+		}
+
+		r.prepared, r.prepareErr = websocket.NewPreparedMessage(websocket.TextMessage, data)
+	})
+
+	return r.prepared, r.prepareErr
+}
+
+// NewSharedResponse wrap response for sharing encoded data
+func NewSharedResponse(rsp models.TableResponse) *SharedResponse {
+	if shared, ok := rsp.(*SharedResponse); ok {
+		return shared
+	}
+
+	return &SharedResponse{TableResponse: rsp}
+}
+
+// UnwrapResponse get origin response if rsp is shared
+func UnwrapResponse(rsp models.TableResponse) models.TableResponse {
+	if shared, ok := rsp.(*SharedResponse); ok {
+		return shared.TableResponse
+	}
+
+	return rsp
+}
@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/frozenpine/ngerest"
+	"github.com/frozenpine/wstester/models"
+)
+
+func newTestTrades(count int) *models.TradeResponse {
+	rsp := models.TradeResponse{}
+	rsp.Table = "trade"
+	rsp.Action = models.InsertAction
+
+	for idx := 0; idx < count; idx++ {
+		ts := ngerest.NGETime(time.Now().UTC())
+
+		rsp.Data = append(rsp.Data, &ngerest.Trade{
+			Symbol:     "XBTUSD",
+			Side:       "Buy",
+			Size:       float32(idx + 1),
+			Price:      10000 + float64(idx)*0.5,
+			Timestamp:  &ts,
+			TrdMatchID: "00000000-0000-0000-0000-000000000000",
+		})
+	}
+
+	return &rsp
+}
+
+func TestSharedResponse(t *testing.T) {
+	origin := newTestTrades(10)
+	shared := NewSharedResponse(origin)
+
+	if NewSharedResponse(shared) != shared {
+		t.Fatal("shared response wrapped twice")
+	}
+
+	if UnwrapResponse(shared) != origin {
+		t.Fatal("unwrap shared response failed")
+	}
+
+	expected, _ := json.Marshal(origin)
+
+	var (
+		wg      sync.WaitGroup
+		results = make([][]byte, 10)
+	)
+
+	for idx := range results {
+		wg.Add(1)
+
+		go func(idx int) {
+			defer wg.Done()
+
+			data, err := shared.Bytes()
+			if err != nil {
+				t.Error(err)
+			}
+
+			results[idx] = data
+		}(idx)
+	}
+
+	wg.Wait()
+
+	for _, data := range results {
+		if &data[0] != &results[0][0] {
+			t.Fatal("shared response encoded more than once")
+		}
+
+		if !bytes.Equal(data, expected) {
+			t.Fatalf("shared data mismatch: %s", string(data))
+		}
+	}
+
+	if data, _ := json.Marshal(shared); !bytes.Equal(data, expected) {
+		t.Fatalf("marshal shared response mismatch: %s", string(data))
+	}
+
+	prepared, err := shared.PreparedMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again, _ := shared.PreparedMessage(); again != prepared {
+		t.Fatal("prepared message created more than once")
+	}
+}
+
+func TestRetriveSharedData(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	ch := &rspChannel{
+		ctx:           ctx,
+		destinations:  map[string]chan<- models.TableResponse{},
+		childChannels: map[string]Channel{},
+	}
+	if err := ch.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, plain := ch.RetriveData()
+	_, shared1 := ch.RetriveSharedData()
+	session, shared2 := ch.RetriveSharedData()
+
+	origin := newTestTrades(1)
+	ch.PublishData(origin)
+
+	if rsp := <-plain; rsp != origin {
+		t.Fatalf("plain destination got wrapped data: %T", rsp)
+	}
+
+	rsp1, rsp2 := <-shared1, <-shared2
+
+	if _, ok := rsp1.(*SharedResponse); !ok {
+		t.Fatalf("shared destination got unwrapped data: %T", rsp1)
+	}
+
+	if rsp1 != rsp2 || UnwrapResponse(rsp1) != origin {
+		t.Fatal("shared destinations got different data")
+	}
+
+	if err := ch.ShutdownRetrive(session); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, exist := ch.destStates[session]; exist {
+		t.Fatal("shared destination not removed")
+	}
+}
+
+func benchmarkFanout(b *testing.B, sessions int, shared bool) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	ch := &rspChannel{
+		ctx:           ctx,
+		destinations:  map[string]chan<- models.TableResponse{},
+		childChannels: map[string]Channel{},
+	}
+	if err := ch.Start(); err != nil {
+		b.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+
+	for idx := 0; idx < sessions; idx++ {
+		var dataChan <-chan models.TableResponse
+
+		if shared {
+			_, dataChan = ch.RetriveSharedData()
+		} else {
+			_, dataChan = ch.RetriveData()
+		}
+
+		go func() {
+			for rsp := range dataChan {
+				var err error
+
+				// encode like session does before writing
+				if shared, ok := rsp.(*SharedResponse); ok {
+					_, err = shared.Bytes()
+				} else {
+					_, err = json.Marshal(rsp)
+				}
+
+				if err != nil {
+					b.Error(err)
+				}
+
+				wg.Done()
+			}
+		}()
+	}
+
+	rsp := newTestTrades(10)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	start := time.Now()
+
+	// publish in batches within destination size, so dispatch never blocks
+	for published := 0; published < b.N; {
+		batch := MinInt(b.N-published, destinationSize/2)
+
+		wg.Add(sessions * batch)
+
+		for idx := 0; idx < batch; idx++ {
+			ch.PublishData(rsp)
+		}
+
+		wg.Wait()
+
+		published += batch
+	}
+
+	b.ReportMetric(float64(sessions*b.N)/time.Since(start).Seconds(), "msgs/s")
+}
+
+func BenchmarkFanout1kSessions(b *testing.B) {
+	b.Run("PerSession", func(b *testing.B) { benchmarkFanout(b, 1000, false) })
+	b.Run("Shared", func(b *testing.B) { benchmarkFanout(b, 1000, true) })
+}
+
+func BenchmarkFanout5kSessions(b *testing.B) {
+	b.Run("PerSession", func(b *testing.B) { benchmarkFanout(b, 5000, false) })
+	b.Run("Shared", func(b *testing.B) { benchmarkFanout(b, 5000, true) })
+}